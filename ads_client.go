@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------
+// ADS-Kommandos
+// ------------------------------------------------------------
+//
+// Command-IDs laut Beckhoff ADS-Spezifikation.
+// ReadState (0x0004) ist in ads_readstate_raw.go definiert.
+const (
+	adsCommandReadDeviceInfo = uint16(0x0001)
+	adsCommandRead           = uint16(0x0002)
+	adsCommandWrite          = uint16(0x0003)
+	adsCommandWriteControl   = uint16(0x0005)
+	adsCommandReadWrite      = uint16(0x0009)
+
+	// amsHeaderLen = Länge des AMS-Headers (ohne 6 Byte AMS/TCP-Header)
+	amsHeaderLen = 32
+
+	// adsMaxPayload begrenzt die Größe einer Antwort,
+	// damit ein fehlerhaftes Längenfeld keinen riesigen Puffer anlegt.
+	adsMaxPayload = 16 * 1024 * 1024
+)
+
+// errAdsClientClosed wird zurückgegeben, wenn die Verbindung
+// bereits geschlossen wurde (lokal oder durch die Gegenstelle).
+var errAdsClientClosed = errors.New("ads client closed")
+
+// ------------------------------------------------------------
+// Fehlertypen
+// ------------------------------------------------------------
+
+// AdsError ist ein ADS-Fehlercode aus dem Result-Feld einer Antwort.
+//
+// Die Textform "ADS error: 0x00000006" wird an anderer Stelle
+// ausgewertet (z. B. "Runtime-Port existiert nicht") und bleibt deshalb stabil.
+type AdsError struct {
+	Code uint32
+}
+
+func (e AdsError) Error() string {
+	return fmt.Sprintf("ADS error: 0x%08X", e.Code)
+}
+
+// AmsError ist ein Fehlercode aus dem AMS-Header einer Antwort.
+//
+// Ihn setzt der AMS-Router des Ziels, wenn er die Anfrage nicht
+// zustellen kann (z. B. 0x6 "Port nicht gefunden" oder fehlende Route).
+// Die Verbindung selbst bleibt dabei bestehen.
+type AmsError struct {
+	Code uint32
+}
+
+func (e AmsError) Error() string {
+	return fmt.Sprintf("AMS error: 0x%08X", e.Code)
+}
+
+// ------------------------------------------------------------
+// Antwortstrukturen
+// ------------------------------------------------------------
+
+// AdsDeviceInfo ist das Ergebnis von ReadDeviceInfo.
+//
+// Name:
+//
+//	z. B. "TwinCAT System" (Port 10000) oder "Plc30 App" (Port 851)
+//
+// Version:
+//
+//	Major.Minor.Build, z. B. 3.1.4024
+type AdsDeviceInfo struct {
+	Name         string
+	MajorVersion uint8
+	MinorVersion uint8
+	BuildVersion uint16
+}
+
+// Version liefert die Version als "Major.Minor.Build".
+func (i AdsDeviceInfo) Version() string {
+	return fmt.Sprintf("%d.%d.%d", i.MajorVersion, i.MinorVersion, i.BuildVersion)
+}
+
+// amsResponse ist eine empfangene AMS-Antwort (Header-Felder + ADS-Daten).
+type amsResponse struct {
+	command    uint16
+	stateFlags uint16
+	amsErr     uint32
+	data       []byte
+}
+
+// ------------------------------------------------------------
+// ADS-Client
+// ------------------------------------------------------------
+//
+// AdsClient hält eine dauerhafte AMS/TCP-Verbindung zu einem Gerät.
+//
+// Über eine Verbindung können mehrere Anfragen gleichzeitig laufen:
+// jede Anfrage bekommt eine eigene InvokeID, eine Lese-Goroutine
+// ordnet die Antworten anhand der InvokeID wieder zu.
+//
+// Der Ziel-AMS-Port wird pro Aufruf angegeben, damit über dieselbe
+// Verbindung z. B. System Service (10000) und PLC-Runtimes (851, ...)
+// abgefragt werden können.
+//
+// Typische Verwendung:
+//
+//	c, err := dialAdsClient(remoteIP, netID, localIP, timeout)
+//	if err != nil { ... }
+//	defer c.Close()
+//
+//	info, err := c.ReadDeviceInfo(851)
+type AdsClient struct {
+	conn       net.Conn
+	targetNet  [6]byte
+	sourceNet  [6]byte
+	sourcePort uint16
+
+	// timeout gilt pro Anfrage (Senden + Warten auf Antwort).
+	timeout time.Duration
+
+	// writeMu verhindert, dass sich zwei Requests auf dem Socket vermischen.
+	writeMu sync.Mutex
+
+	// mu schützt pending, closed und err.
+	mu      sync.Mutex
+	pending map[uint32]chan amsResponse
+	closed  bool
+	err     error
+}
+
+// dialAdsClient baut die TCP-Verbindung zu Port 48898 auf
+// und startet die Lese-Goroutine.
+//
+// Die lokale AMS Net ID wird wie bisher aus der lokalen IPv4 gebildet
+// (<lokale IP>.1.1), damit sie zur per UDP angelegten Route passt.
+func dialAdsClient(remoteIP net.IP, remoteAmsNetID string, localIP net.IP, timeout time.Duration) (*AdsClient, error) {
+	targetNetID, err := parseAmsNetID(remoteAmsNetID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceNetID, err := localAmsNetIDFromIP(localIP)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout(
+		"tcp4",
+		net.JoinHostPort(remoteIP.String(), strconv.Itoa(adsTCPPort)),
+		timeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dial tcp %s:%d: %w", remoteIP.String(), adsTCPPort, err)
+	}
+
+	c := &AdsClient{
+		conn:       conn,
+		targetNet:  targetNetID,
+		sourceNet:  sourceNetID,
+		sourcePort: adsClientAmsPort,
+		timeout:    timeout,
+		pending:    make(map[uint32]chan amsResponse),
+	}
+
+	go c.readLoop()
+
+	return c, nil
+}
+
+// Close schließt die Verbindung. Offene Anfragen kehren mit Fehler zurück.
+func (c *AdsClient) Close() error {
+	c.shutdown(errAdsClientClosed)
+	return c.conn.Close()
+}
+
+// shutdown markiert den Client als geschlossen
+// und weckt alle noch wartenden Anfragen auf.
+func (c *AdsClient) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.err = err
+
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// readLoop liest fortlaufend AMS/TCP-Pakete
+// und verteilt sie anhand der InvokeID an die wartenden Anfragen.
+//
+// Antworten ohne passende Anfrage (z. B. nach Timeout) werden verworfen.
+func (c *AdsClient) readLoop() {
+	tcpHdr := make([]byte, 6)
+
+	for {
+		if _, err := io.ReadFull(c.conn, tcpHdr); err != nil {
+			c.shutdown(fmt.Errorf("read ams/tcp header: %w", err))
+			return
+		}
+
+		payloadLen := binary.LittleEndian.Uint32(tcpHdr[2:6])
+		if payloadLen < amsHeaderLen || payloadLen > adsMaxPayload {
+			c.shutdown(fmt.Errorf("invalid AMS/TCP payload length: %d", payloadLen))
+			return
+		}
+
+		payload := make([]byte, payloadLen)
+		if _, err := io.ReadFull(c.conn, payload); err != nil {
+			c.shutdown(fmt.Errorf("read AMS payload: %w", err))
+			return
+		}
+
+		dataLen := binary.LittleEndian.Uint32(payload[20:24])
+		if uint64(amsHeaderLen)+uint64(dataLen) > uint64(len(payload)) {
+			c.shutdown(fmt.Errorf("AMS data length %d exceeds payload %d", dataLen, len(payload)))
+			return
+		}
+
+		resp := amsResponse{
+			command:    binary.LittleEndian.Uint16(payload[16:18]),
+			stateFlags: binary.LittleEndian.Uint16(payload[18:20]),
+			amsErr:     binary.LittleEndian.Uint32(payload[24:28]),
+			data:       payload[amsHeaderLen : amsHeaderLen+dataLen],
+		}
+		invokeID := binary.LittleEndian.Uint32(payload[28:32])
+
+		c.mu.Lock()
+		ch, ok := c.pending[invokeID]
+		if ok {
+			delete(c.pending, invokeID)
+		}
+		c.mu.Unlock()
+
+		if ok {
+			ch <- resp // gepuffert, blockiert nie
+		}
+	}
+}
+
+// buildAmsPacket erzeugt ein vollständiges AMS/TCP-Request-Paket
+// für ein beliebiges ADS-Kommando.
+//
+// Paketaufbau:
+//
+//	6 Byte  AMS/TCP Header
+//	32 Byte AMS Header
+//	n Byte  ADS-Daten
+func buildAmsPacket(
+	targetNetID [6]byte,
+	targetPort uint16,
+	sourceNetID [6]byte,
+	sourcePort uint16,
+	command uint16,
+	invokeID uint32,
+	data []byte,
+) []byte {
+	pkt := make([]byte, 6+amsHeaderLen+len(data))
+
+	// AMS/TCP Header: 2 Byte reserviert, 4 Byte Länge
+	binary.LittleEndian.PutUint32(pkt[2:6], uint32(amsHeaderLen+len(data)))
+
+	// AMS Header
+	copy(pkt[6:12], targetNetID[:])
+	binary.LittleEndian.PutUint16(pkt[12:14], targetPort)
+	copy(pkt[14:20], sourceNetID[:])
+	binary.LittleEndian.PutUint16(pkt[20:22], sourcePort)
+	binary.LittleEndian.PutUint16(pkt[22:24], command)
+	binary.LittleEndian.PutUint16(pkt[24:26], adsStateFlagRequest)
+	binary.LittleEndian.PutUint32(pkt[26:30], uint32(len(data)))
+	binary.LittleEndian.PutUint32(pkt[30:34], 0) // AMS Error = 0 bei Request
+	binary.LittleEndian.PutUint32(pkt[34:38], invokeID)
+
+	// ADS-Daten
+	copy(pkt[38:], data)
+
+	return pkt
+}
+
+// request sendet ein ADS-Kommando und wartet auf die passende Antwort.
+//
+// Geprüft werden Command-ID, State Flags und der AMS-Fehler im Header.
+// Zurückgegeben wird der ADS-Datenblock der Antwort (inkl. ADS-Result).
+func (c *AdsClient) request(port uint16, command uint16, data []byte) ([]byte, error) {
+	invokeID := atomic.AddUint32(&adsInvokeID, 1)
+	ch := make(chan amsResponse, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.pending[invokeID] = ch
+	c.mu.Unlock()
+
+	// Eintrag bei Timeout/Schreibfehler wieder entfernen
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, invokeID)
+		c.mu.Unlock()
+	}()
+
+	pkt := buildAmsPacket(c.targetNet, port, c.sourceNet, c.sourcePort, command, invokeID, data)
+
+	c.writeMu.Lock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(pkt)
+	c.writeMu.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("write request: %w", err)
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	var resp amsResponse
+
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			err := c.err
+			c.mu.Unlock()
+			return nil, err
+		}
+		resp = r
+
+	case <-timer.C:
+		return nil, fmt.Errorf("timeout after %s (port %d, command 0x%04X)", c.timeout, port, command)
+	}
+
+	if resp.command != command {
+		return nil, fmt.Errorf("unexpected ADS command: 0x%04X", resp.command)
+	}
+
+	if resp.stateFlags != adsStateFlagResponse {
+		return nil, fmt.Errorf("unexpected state flags: 0x%04X", resp.stateFlags)
+	}
+
+	if resp.amsErr != 0 {
+		return nil, AmsError{Code: resp.amsErr}
+	}
+
+	return resp.data, nil
+}
+
+// requestResult führt request aus und prüft das 4 Byte lange ADS-Result
+// am Anfang jeder Antwort. minLen ist die erwartete Mindestlänge inkl. Result.
+func (c *AdsClient) requestResult(port uint16, command uint16, data []byte, minLen int) ([]byte, error) {
+	resp, err := c.request(port, command, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) < 4 {
+		return nil, fmt.Errorf("ADS response too short: %d", len(resp))
+	}
+
+	if code := binary.LittleEndian.Uint32(resp[0:4]); code != 0 {
+		return nil, AdsError{Code: code}
+	}
+
+	if len(resp) < minLen {
+		return nil, fmt.Errorf("unexpected ADS data length: got %d want %d", len(resp), minLen)
+	}
+
+	return resp, nil
+}
+
+// ------------------------------------------------------------
+// ADS-Kommandos
+// ------------------------------------------------------------
+
+// ReadDeviceInfo (0x0001) liest Name und Version eines ADS-Geräts.
+//
+// Antwort: Result(4) Major(1) Minor(1) Build(2) Name(16, nullterminiert)
+func (c *AdsClient) ReadDeviceInfo(port uint16) (AdsDeviceInfo, error) {
+	resp, err := c.requestResult(port, adsCommandReadDeviceInfo, nil, 4+4+16)
+	if err != nil {
+		return AdsDeviceInfo{}, err
+	}
+
+	name := resp[8:24]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+
+	return AdsDeviceInfo{
+		Name:         string(name),
+		MajorVersion: resp[4],
+		MinorVersion: resp[5],
+		BuildVersion: binary.LittleEndian.Uint16(resp[6:8]),
+	}, nil
+}
+
+// Read (0x0002) liest length Bytes aus IndexGroup/IndexOffset.
+//
+// Antwort: Result(4) Length(4) Data(Length)
+func (c *AdsClient) Read(port uint16, indexGroup, indexOffset, length uint32) ([]byte, error) {
+	req := make([]byte, 12)
+	binary.LittleEndian.PutUint32(req[0:4], indexGroup)
+	binary.LittleEndian.PutUint32(req[4:8], indexOffset)
+	binary.LittleEndian.PutUint32(req[8:12], length)
+
+	resp, err := c.requestResult(port, adsCommandRead, req, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	return adsLengthPrefixed(resp)
+}
+
+// Write (0x0003) schreibt data nach IndexGroup/IndexOffset.
+//
+// Antwort: Result(4)
+func (c *AdsClient) Write(port uint16, indexGroup, indexOffset uint32, data []byte) error {
+	req := make([]byte, 12+len(data))
+	binary.LittleEndian.PutUint32(req[0:4], indexGroup)
+	binary.LittleEndian.PutUint32(req[4:8], indexOffset)
+	binary.LittleEndian.PutUint32(req[8:12], uint32(len(data)))
+	copy(req[12:], data)
+
+	_, err := c.requestResult(port, adsCommandWrite, req, 4)
+	return err
+}
+
+// ReadState (0x0004) liest AdsState und DeviceState.
+//
+// Antwort: Result(4) AdsState(2) DeviceState(2)
+func (c *AdsClient) ReadState(port uint16) (AdsReadStateResponse, error) {
+	resp, err := c.requestResult(port, adsCommandReadState, nil, 8)
+	if err != nil {
+		return AdsReadStateResponse{}, err
+	}
+
+	return AdsReadStateResponse{
+		AdsState:    binary.LittleEndian.Uint16(resp[4:6]),
+		DeviceState: binary.LittleEndian.Uint16(resp[6:8]),
+	}, nil
+}
+
+// WriteControl (0x0005) setzt AdsState/DeviceState (z. B. RUN, STOP, RESET).
+//
+// Anfrage: AdsState(2) DeviceState(2) Length(4) Data
+// Antwort: Result(4)
+func (c *AdsClient) WriteControl(port uint16, adsState, deviceState uint16, data []byte) error {
+	req := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint16(req[0:2], adsState)
+	binary.LittleEndian.PutUint16(req[2:4], deviceState)
+	binary.LittleEndian.PutUint32(req[4:8], uint32(len(data)))
+	copy(req[8:], data)
+
+	_, err := c.requestResult(port, adsCommandWriteControl, req, 4)
+	return err
+}
+
+// ReadWrite (0x0009) schreibt data und liest in derselben Anfrage
+// bis zu readLength Bytes zurück (z. B. Symbol-Handles, Routen-Abfragen).
+//
+// Anfrage: IndexGroup(4) IndexOffset(4) ReadLength(4) WriteLength(4) Data
+// Antwort: Result(4) Length(4) Data(Length)
+func (c *AdsClient) ReadWrite(port uint16, indexGroup, indexOffset, readLength uint32, data []byte) ([]byte, error) {
+	req := make([]byte, 16+len(data))
+	binary.LittleEndian.PutUint32(req[0:4], indexGroup)
+	binary.LittleEndian.PutUint32(req[4:8], indexOffset)
+	binary.LittleEndian.PutUint32(req[8:12], readLength)
+	binary.LittleEndian.PutUint32(req[12:16], uint32(len(data)))
+	copy(req[16:], data)
+
+	resp, err := c.requestResult(port, adsCommandReadWrite, req, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	return adsLengthPrefixed(resp)
+}
+
+// adsLengthPrefixed liefert den Datenblock hinter Result(4) + Length(4).
+func adsLengthPrefixed(resp []byte) ([]byte, error) {
+	n := binary.LittleEndian.Uint32(resp[4:8])
+	if uint64(8)+uint64(n) > uint64(len(resp)) {
+		return nil, fmt.Errorf("ADS data length %d exceeds response %d", n, len(resp)-8)
+	}
+
+	return resp[8 : 8+n], nil
+}
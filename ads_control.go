@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// TwinCAT-State per ADS WriteControl ändern
+// ------------------------------------------------------------
+//
+// ADS-States für WriteControl:
+//
+//	2  = RESET    (System: TwinCAT neu starten → RUN; PLC: Reset kalt)
+//	5  = RUN      (PLC starten)
+//	6  = STOP     (PLC stoppen)
+//	16 = RECONFIG (System: in CONFIG-Modus wechseln)
+const (
+	adsStateReset    = uint16(2)
+	adsStateRun      = uint16(5)
+	adsStateStop     = uint16(6)
+	adsStateReconfig = uint16(16)
+)
+
+// Aktionen für POST /api/devices/{id}/state
+//
+//	run      System (Port 10000): TwinCAT in RUN starten
+//	         PLC-Port:            PLC starten
+//	config   System (Port 10000): TwinCAT in CONFIG schalten
+//	stop     PLC-Port:            PLC stoppen
+//	restart  PLC-Port:            PLC zurücksetzen und neu starten
+const (
+	stateActionRun     = "run"
+	stateActionConfig  = "config"
+	stateActionStop    = "stop"
+	stateActionRestart = "restart"
+)
+
+// deviceStateRequest ist der JSON-Body für POST /api/devices/{id}/state.
+//
+// Port ist optional:
+// run/config → 10000 (System Service)
+// stop/restart → erste bekannte PLC-Runtime des Geräts (sonst 851)
+type deviceStateRequest struct {
+	Action string `json:"action"`
+	Port   uint16 `json:"port,omitempty"`
+}
+
+// deviceStateResponse liefert den State nach der Aktion zurück.
+// State kann leer sein, wenn das Gerät gerade neu startet.
+type deviceStateResponse struct {
+	Action string `json:"action"`
+	Port   uint16 `json:"port"`
+	State  string `json:"state,omitempty"`
+}
+
+// isPlcRuntimePort prüft, ob port ein bekannter PLC-Runtime-Port ist.
+func isPlcRuntimePort(port uint16) bool {
+	for _, p := range candidateRuntimePorts("", 0) {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveStatePort bestimmt den Ziel-Port und prüft,
+// ob Aktion und Port zusammenpassen.
+func resolveStatePort(dev IPC, req deviceStateRequest) (uint16, error) {
+	port := req.Port
+
+	switch req.Action {
+	case stateActionRun:
+		if port == 0 {
+			port = routeTcSystemService
+		}
+
+	case stateActionConfig:
+		if port == 0 {
+			port = routeTcSystemService
+		}
+		if port != routeTcSystemService {
+			return 0, fmt.Errorf("config is only possible on port %d", routeTcSystemService)
+		}
+
+	case stateActionStop, stateActionRestart:
+		if port == 0 {
+			port = routeTc3Runtime851
+			if len(dev.Runtimes) > 0 {
+				port = dev.Runtimes[0].Port
+			}
+		}
+		if port == routeTcSystemService {
+			return 0, fmt.Errorf("%s requires a PLC runtime port", req.Action)
+		}
+
+	default:
+		return 0, fmt.Errorf("unknown action %q", req.Action)
+	}
+
+	if port != routeTcSystemService && !isPlcRuntimePort(port) {
+		return 0, fmt.Errorf("invalid port %d", port)
+	}
+
+	return port, nil
+}
+
+// executeStateAction führt die WriteControl-Aufrufe für eine Aktion aus.
+func executeStateAction(c *AdsClient, action string, port uint16) error {
+	switch action {
+	case stateActionRun:
+		if port == routeTcSystemService {
+			return c.WriteControl(port, adsStateReset, 0, nil)
+		}
+		return c.WriteControl(port, adsStateRun, 0, nil)
+
+	case stateActionConfig:
+		return c.WriteControl(port, adsStateReconfig, 0, nil)
+
+	case stateActionStop:
+		return c.WriteControl(port, adsStateStop, 0, nil)
+
+	case stateActionRestart:
+		if err := c.WriteControl(port, adsStateReset, 0, nil); err != nil {
+			return err
+		}
+		return c.WriteControl(port, adsStateRun, 0, nil)
+	}
+
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// errNoAdsRoute bedeutet: zum Gerät ist keine funktionierende Route bekannt.
+var errNoAdsRoute = errors.New("no working ADS route")
+
+// deviceAdsAddrs liefert Geräte-IP und passende lokale IP.
+func deviceAdsAddrs(dev IPC) (remoteIP, localIP net.IP, err error) {
+	remoteIP = net.ParseIP(dev.IP).To4()
+	if remoteIP == nil {
+		return nil, nil, fmt.Errorf("invalid device ip %q", dev.IP)
+	}
+
+	localIP, err = localIPForRemote(remoteIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return remoteIP, localIP, nil
+}
+
+// adsTimeout liefert den Timeout für Verbindungsaufbau und Anfragen:
+// cfg.ReadStateTimeout, höchstens aber die Restzeit bis zur Deadline von ctx.
+func adsTimeout(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	timeout := time.Duration(cfg.ReadStateTimeout)
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return 0, context.DeadlineExceeded
+		}
+		timeout = min(timeout, left)
+	}
+	return timeout, nil
+}
+
+// dialDeviceAds öffnet eine ADS-Verbindung über eine bereits bestehende
+// Route (auch eine von Hand angelegte). Es wird nie eine Route angelegt;
+// fehlt sie, kommt errNoAdsRoute.
+//
+// Verbindungsaufbau und ReadState enden spätestens mit der Deadline von ctx.
+func dialDeviceAds(ctx context.Context, dev IPC) (*AdsClient, error) {
+	remoteIP, localIP, err := deviceAdsAddrs(dev)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := adsTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dialAdsClient(remoteIP, dev.AmsNetID, localIP, timeout)
+	if err != nil {
+		// Gerät nicht erreichbar – eine neue Route hilft hier nicht
+		return nil, err
+	}
+
+	_, err = client.ReadState(routeTcSystemService)
+	recordAdsError(err)
+	if isRouteError(err) {
+		client.Close()
+		return nil, errNoAdsRoute
+	}
+
+	return client, nil
+}
+
+// connectDeviceAds öffnet eine ADS-Verbindung zu einem Gerät aus dem Inventar.
+//
+// Wie beim Scan (siehe TryReadTCState) wird zuerst direkt verbunden.
+// Nur bei einem routenbedingten Fehler wird die Route per UDP neu
+// angelegt. Die Funktion ist deshalb Aktionen vorbehalten, die nur
+// Admins ausführen dürfen; lesende Zugriffe verwenden dialDeviceAds.
+//
+// Alle Schritte enden spätestens mit der Deadline von ctx.
+func connectDeviceAds(ctx context.Context, dev IPC) (*AdsClient, error) {
+	client, err := dialDeviceAds(ctx, dev)
+	if !errors.Is(err, errNoAdsRoute) {
+		return client, err
+	}
+
+	// Route wurde von einem Admin gelöscht: nicht neu anlegen
+	if dev.RouteRemoved {
+		return nil, fmt.Errorf("%w (removed by admin)", errNoAdsRoute)
+	}
+
+	remoteIP, localIP, err := deviceAdsAddrs(dev)
+	if err != nil {
+		return nil, err
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.RouteTimeout))
+	defer cancel()
+
+	cred, _ := routeCredentialFor(dev.MACAddress, dev.Office)
+
+	if err := addAdsRoute(rctx, localIP, remoteIP, ownRouteName(), cred.User, cred.Password, routeUdpPort48899, cfg.TemporaryRoutes); err != nil {
+		return nil, fmt.Errorf("route: %w", err)
+	}
+
+	timeout, err := adsTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return dialAdsClient(remoteIP, dev.AmsNetID, localIP, timeout)
+}
+
+// ------------------------------------------------------------
+// HTTP-Handler
+// ------------------------------------------------------------
+
+// handleDeviceState verarbeitet POST /api/devices/{id}/state.
+//
+// Beispiel:
+//
+//	curl -X POST -d '{"action":"config"}' http://host:18080/api/devices/172.17.76.23/state
+//	curl -X POST -d '{"action":"restart","port":852}' http://host:18080/api/devices/172.17.76.23/state
+//
+// Jede Aktion (auch fehlgeschlagene) wird im Audit-Log festgehalten.
+func handleDeviceState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dev, ok := findDevice(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "device not found", http.StatusNotFound)
+		return
+	}
+
+	if dev.AmsNetID == "" {
+		http.Error(w, "device has no AMS Net ID", http.StatusConflict)
+		return
+	}
+
+	var req deviceStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	req.Action = strings.ToLower(strings.TrimSpace(req.Action))
+
+	port, err := resolveStatePort(dev, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	target := deviceAuditTarget(dev)
+	details := fmt.Sprintf("%s port %d", req.Action, port)
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(cfg.AdsDeviceTimeout))
+	defer cancel()
+
+	client, err := connectDeviceAds(ctx, dev)
+	if err != nil {
+		appendAudit(r, auditDeviceState, target, details, err)
+		http.Error(w, "ads connect failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer client.Close()
+
+	if err := executeStateAction(client, req.Action, port); err != nil {
+		recordAdsError(err)
+		appendAudit(r, auditDeviceState, target, details, err)
+		http.Error(w, "ads write control failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	appendAudit(r, auditDeviceState, target, details, nil)
+
+	// Neuen State zurücklesen. Nach einem Neustart des Systems
+	// ist das Gerät evtl. kurz nicht erreichbar → State bleibt leer.
+	resp := deviceStateResponse{Action: req.Action, Port: port}
+
+	if st, err := client.ReadState(port); err == nil {
+		resp.State = adsStateName(st)
+		updateDeviceState(dev.IP, port, resp.State)
+		publishDevices(dev.IP)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// updateDeviceState übernimmt einen gelesenen State ins Inventar,
+// damit das Dashboard nicht bis zum nächsten Scan den alten Wert zeigt.
+func updateDeviceState(ip string, port uint16, state string) {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	dev, ok := inventory[ip]
+	if !ok {
+		return
+	}
+
+	if port == routeTcSystemService {
+		dev.RuntimeStatus = state
+		return
+	}
+
+	// Kopie statt Änderung an Ort und Stelle: der Slice kann
+	// noch in Snapshots (z. B. für die Historie) referenziert sein.
+	runtimes := append([]PlcRuntime(nil), dev.Runtimes...)
+	for i := range runtimes {
+		if runtimes[i].Port == port {
+			runtimes[i].State = state
+		}
+	}
+	dev.Runtimes = runtimes
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// PLC-Runtimes und Projektinformationen
+// ------------------------------------------------------------
+//
+// PlcRuntime beschreibt eine PLC-Runtime auf einem Gerät,
+// ihren eigenen ADS-State und das darauf laufende Projekt.
+//
+// Ein Gerät kann mehrere Runtimes haben (TwinCAT 3: 851..854,
+// TwinCAT 2: 801/811/821/831), die unabhängig voneinander
+// in RUN oder STOP sein können.
+//
+// Die Projektfelder werden aus den Systemvariablen
+//
+//	TwinCAT_SystemInfoVarList._AppInfo.*
+//
+// gelesen. Diese Liste gibt es in jedem TwinCAT-3-PLC-Projekt.
+// Bei TwinCAT 2 oder abweichenden Projekten fehlen die Symbole;
+// dann bleiben Projektname/Zeitstempel leer, Port und State
+// werden trotzdem eingetragen.
+type PlcRuntime struct {
+	Port          uint16 `json:"port"`                    // AMS-Port der Runtime (851, 801, ...)
+	State         string `json:"state"`                   // RUN / STOP / ... (ADS ReadState)
+	Err           string `json:"error,omitempty"`         // ADS-Fehler beim Lesen des States
+	DeviceName    string `json:"deviceName,omitempty"`    // ReadDeviceInfo, z. B. "Plc30 App"
+	DeviceVersion string `json:"deviceVersion,omitempty"` // ReadDeviceInfo, z. B. "3.1.4024"
+	ProjectName   string `json:"projectName,omitempty"`   // _AppInfo.ProjectName
+	AppName       string `json:"appName,omitempty"`       // _AppInfo.AppName
+
+	// Boot-Projekt:
+	// BootDataLoaded = Boot-Projekt/Boot-Daten wurden beim Start geladen
+	// OldBootData    = geladene Boot-Daten stammen von einem älteren Stand
+	BootDataLoaded bool `json:"bootDataLoaded"`
+	OldBootData    bool `json:"oldBootData"`
+
+	// CompileTime = _AppInfo.AppTimestamp (Zeitpunkt des Übersetzens/Downloads).
+	// Der Wert ist ein DT in PLC-Ortszeit und wird unverändert als UTC abgelegt.
+	CompileTime time.Time `json:"compileTime,omitempty"`
+}
+
+// ------------------------------------------------------------
+// ADS-Symbolzugriff
+// ------------------------------------------------------------
+//
+// 0xF004 = ADSIGRP_SYM_VALBYNAME
+//
+// Schreibt den Symbolnamen und liest in derselben Anfrage den Wert.
+// Dadurch ist kein Handle nötig, das wieder freigegeben werden müsste.
+const adsIdxGrpSymValByName = uint32(0xF004)
+
+// adsErrTargetPortNotFound = ADS-Fehler 0x6: auf diesem AMS-Port
+// ist kein ADS-Gerät registriert (also keine Runtime vorhanden).
+const adsErrTargetPortNotFound = uint32(0x6)
+
+// adsResultCode liefert den Fehlercode aus ADS-Result (AdsError) oder
+// AMS-Header (AmsError). ok = false: keine Antwort des Geräts
+// (Timeout, Verbindungsabbruch, ...).
+func adsResultCode(err error) (code uint32, ok bool) {
+	var adsErr AdsError
+	if errors.As(err, &adsErr) {
+		return adsErr.Code, true
+	}
+
+	var amsErr AmsError
+	if errors.As(err, &amsErr) {
+		return amsErr.Code, true
+	}
+
+	return 0, false
+}
+
+// Symbolnamen der PLC-Systeminformationen und ihre Größe in Byte.
+//
+// STRING(63) = 64 Byte (inkl. Nullterminierung)
+// DT         = 4 Byte (Sekunden seit 1970)
+// BOOL       = 1 Byte
+const (
+	plcSymAppInfo = "TwinCAT_SystemInfoVarList._AppInfo."
+
+	plcSymProjectName    = plcSymAppInfo + "ProjectName"
+	plcSymAppName        = plcSymAppInfo + "AppName"
+	plcSymAppTimestamp   = plcSymAppInfo + "AppTimestamp"
+	plcSymBootDataLoaded = plcSymAppInfo + "BootDataLoaded"
+	plcSymOldBootData    = plcSymAppInfo + "OldBootData"
+
+	plcString63Len = 64
+)
+
+// readSymbolByName liest size Bytes aus einem PLC-Symbol.
+func (c *AdsClient) readSymbolByName(port uint16, name string, size uint32) ([]byte, error) {
+	return c.ReadWrite(port, adsIdxGrpSymValByName, 0, size, asciiZ(name))
+}
+
+// readPlcString liest eine STRING-Variable und schneidet an '\0' ab.
+func (c *AdsClient) readPlcString(port uint16, name string, size uint32) (string, error) {
+	b, err := c.readSymbolByName(port, name, size)
+	if err != nil {
+		return "", err
+	}
+
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b), nil
+}
+
+// readPlcBool liest eine BOOL-Variable.
+func (c *AdsClient) readPlcBool(port uint16, name string) (bool, error) {
+	b, err := c.readSymbolByName(port, name, 1)
+	if err != nil || len(b) < 1 {
+		return false, err
+	}
+
+	return b[0] != 0, nil
+}
+
+// ------------------------------------------------------------
+// Runtimes abfragen
+// ------------------------------------------------------------
+
+// readPlcRuntimes fragt alle Kandidaten-Ports ab und liefert
+// für jede vorhandene PLC-Runtime State und Projektinformationen.
+//
+// Ports ohne Runtime antworten mit Fehler 0x00000006
+// ("target port not found") – je nach TwinCAT-Version im ADS-Result
+// oder im AMS-Header – und werden übersprungen.
+// Andere ADS-/AMS-Fehler bedeuten: Port antwortet, State ist aber nicht
+// lesbar – die Runtime wird mit Fehlertext aufgenommen.
+//
+// Läuft eine Anfrage dagegen in einen Timeout oder bricht die
+// Verbindung ab, wird die Abfrage beendet: Die restlichen Ports
+// würden sonst jeweils ebenfalls den vollen Timeout kosten.
+func readPlcRuntimes(c *AdsClient, ports []uint16) []PlcRuntime {
+	var out []PlcRuntime
+
+	for _, port := range ports {
+		st, err := c.ReadState(port)
+		if err != nil {
+			recordAdsError(err, adsErrTargetPortNotFound)
+
+			code, ok := adsResultCode(err)
+			if !ok {
+				break
+			}
+
+			if code != adsErrTargetPortNotFound {
+				out = append(out, PlcRuntime{Port: port, State: "no Info", Err: err.Error()})
+			}
+			continue
+		}
+
+		p := PlcRuntime{
+			Port:  port,
+			State: adsStateName(st),
+		}
+
+		if info, err := c.ReadDeviceInfo(port); err == nil {
+			p.DeviceName = info.Name
+			p.DeviceVersion = info.Version()
+		} else {
+			recordAdsError(err)
+		}
+
+		// Projektinformationen – fehlende Symbole sind kein Fehler
+		// (und werden deshalb auch nicht in den Metriken gezählt)
+		p.ProjectName, _ = c.readPlcString(port, plcSymProjectName, plcString63Len)
+		p.AppName, _ = c.readPlcString(port, plcSymAppName, plcString63Len)
+		p.BootDataLoaded, _ = c.readPlcBool(port, plcSymBootDataLoaded)
+		p.OldBootData, _ = c.readPlcBool(port, plcSymOldBootData)
+
+		if b, err := c.readSymbolByName(port, plcSymAppTimestamp, 4); err == nil && len(b) >= 4 {
+			if secs := binary.LittleEndian.Uint32(b); secs != 0 {
+				p.CompileTime = time.Unix(int64(secs), 0).UTC()
+			}
+		}
+
+		out = append(out, p)
+	}
+
+	return out
+}
+
+// plcBootLabel liefert eine kurze Beschreibung des Boot-Projekt-Zustands.
+func plcBootLabel(p PlcRuntime) string {
+	switch {
+	case p.OldBootData:
+		return "Boot-Projekt veraltet"
+	case p.BootDataLoaded:
+		return "Boot-Projekt geladen"
+	default:
+		return "kein Boot-Projekt geladen"
+	}
+}
+
+// runtimesSummary fasst die States aller Runtimes zusammen,
+// z. B. "851 RUN, 852 STOP". Wird für den Geräteverlauf verwendet.
+func runtimesSummary(runtimes []PlcRuntime) string {
+	parts := make([]string, 0, len(runtimes))
+
+	for _, rt := range runtimes {
+		parts = append(parts, fmt.Sprintf("%d %s", rt.Port, rt.State))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// runtimesNotRunning zählt Runtimes, die nicht in RUN sind.
+func runtimesNotRunning(runtimes []PlcRuntime) int {
+	n := 0
+
+	for _, rt := range runtimes {
+		if rt.State != "RUN" {
+			n++
+		}
+	}
+
+	return n
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"os"
 	"time"
 )
 
@@ -26,10 +25,27 @@ import (
 // RuntimePort:
 //
 //	der verwendete AMS-Port, auf dem der Status erfolgreich gelesen wurde
+//
+// DeviceName / DeviceVersion:
+//
+//	Ergebnis von ADS ReadDeviceInfo auf demselben Port
+//	(z. B. "TwinCAT System" / "3.1.4024"), leer falls nicht lesbar
+//
+// Runtimes:
+//
+//	alle gefundenen PLC-Runtimes mit eigenem State und Projektinformationen
+//
+// Route:
+//
+//	aktualisierter Routen-Zustand (bekannt gut, Fehlversuche, Backoff)
 type PlcStateResult struct {
-	Status      string
-	Err         string
-	RuntimePort uint16
+	Status        string
+	Err           string
+	RuntimePort   uint16
+	DeviceName    string
+	DeviceVersion string
+	Runtimes      []PlcRuntime
+	Route         routeCacheEntry
 }
 
 // ------------------------------------------------------------
@@ -59,13 +75,23 @@ var (
 	routeSegPort10000       = []byte{0x10, 0x27}    // Port 10000 little-endian
 	routeSegReqAddRoute     = []byte{6, 0, 0, 0}    // REQUEST_ADDROUTE
 	routeSegRespAddRoute    = []byte{6, 0, 0, 0x80} // RESPONSE_ADDROUTE
-	routeSegRouteTypeStatic = []byte{5, 0, 0, 0}    // ROUTETYPE_STATIC
+	routeSegRouteTypeStatic = []byte{5, 0, 0, 0}    // ROUTETYPE_STATIC (= 5 Tags folgen)
+	routeSegRouteTypeTemp   = []byte{6, 0, 0, 0}    // temporäre Route (= 6 Tags, inkl. Flag-Tag)
 
 	routeSegRouteNameL = []byte{0x0c, 0, 0, 0} // ROUTENAME_L
 	routeSegUserNameL  = []byte{0x0d, 0, 0, 0} // USERNAME_L
 	routeSegPasswordL  = []byte{2, 0, 0, 0}    // PASSWORD_L
 	routeSegLocalHostL = []byte{5, 0, 0, 0}    // LOCALHOST_L
 	routeSegAmsNetIdL  = []byte{7, 0, 6, 0}    // AMSNETID_L
+
+	// Flag-Tag für temporäre Routen (Option "Temporary Route" im
+	// TwinCAT-Routendialog). Wert 1 = Route wird nicht in die
+	// StaticRoutes.xml geschrieben und entfällt beim Neustart des Ziels.
+	// Das Tag ist nicht offiziell dokumentiert und nicht gegen jedes
+	// TC2/TC3-Ziel geprüft; Ziele, die es nicht kennen, legen die Route
+	// ohne Fehlermeldung statisch an. cfg.TemporaryRoutes ist daher nur
+	// "best effort".
+	routeSegTemporaryL = []byte{0x0e, 0, 4, 0} // TEMPORARY_L
 )
 
 // ------------------------------------------------------------
@@ -98,7 +124,9 @@ func asciiZ(s string) []byte {
 // - lokaler Hostname bzw. IP
 //
 // Die *_L-Segmente enthalten jeweils die Länge des nachfolgenden Feldes.
-func buildAddRoutePacket(localAmsNetID [6]byte, routeName, user, pass, localHostNameOrIP string) []byte {
+//
+// temporary = true hängt das Flag-Tag für eine temporäre Route an.
+func buildAddRoutePacket(localAmsNetID [6]byte, routeName, user, pass, localHostNameOrIP string, temporary bool) []byte {
 	routeNameB := asciiZ(routeName)
 	userB := asciiZ(user)
 	passB := asciiZ(pass)
@@ -124,7 +152,11 @@ func buildAddRoutePacket(localAmsNetID [6]byte, routeName, user, pass, localHost
 	out = append(out, routeSegReqAddRoute...)
 	out = append(out, localAmsNetID[:]...)
 	out = append(out, routeSegPort10000...)
-	out = append(out, routeSegRouteTypeStatic...)
+	if temporary {
+		out = append(out, routeSegRouteTypeTemp...)
+	} else {
+		out = append(out, routeSegRouteTypeStatic...)
+	}
 
 	out = append(out, routeNameL...)
 	out = append(out, routeNameB...)
@@ -141,6 +173,11 @@ func buildAddRoutePacket(localAmsNetID [6]byte, routeName, user, pass, localHost
 	out = append(out, hostL...)
 	out = append(out, localHostB...)
 
+	if temporary {
+		out = append(out, routeSegTemporaryL...)
+		out = append(out, 1, 0, 0, 0)
+	}
+
 	return out
 }
 
@@ -178,7 +215,11 @@ func parseAddRouteAck(pkt []byte) bool {
 // ------------------------------------------------------------
 // ADS-Route per UDP sicherstellen
 // ------------------------------------------------------------
-//
+
+// addAdsRoute legt Routen an (TryReadTCState, connectDeviceAds).
+// Tests ersetzen sie, um AddRoute-Aufrufe zu zählen.
+var addAdsRoute = EnsureAdsRouteUDP
+
 // EnsureAdsRouteUDP versucht auf dem Zielgerät eine ADS-Route
 // zum lokalen Rechner anzulegen.
 //
@@ -200,6 +241,7 @@ func EnsureAdsRouteUDP(
 	user string,
 	pass string,
 	udpPort int,
+	temporary bool,
 ) error {
 	ip4 := localIP.To4()
 	if ip4 == nil {
@@ -219,10 +261,7 @@ func EnsureAdsRouteUDP(
 
 	// Default-Werte ergänzen
 	if routeName == "" {
-		routeName, _ = os.Hostname()
-		if routeName == "" {
-			routeName = "GoInventory"
-		}
+		routeName = ownRouteName()
 	}
 
 	if user == "" {
@@ -236,7 +275,7 @@ func EnsureAdsRouteUDP(
 	// statt des Hostnamens, damit das Ziel den Rückweg sauber kennt.
 	localHostNameOrIP := ip4.String()
 
-	req := buildAddRoutePacket(localAms, routeName, user, pass, localHostNameOrIP)
+	req := buildAddRoutePacket(localAms, routeName, user, pass, localHostNameOrIP, temporary)
 
 	// UDP-Socket auf lokaler Testnetz-IP öffnen
 	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: ip4, Port: 0})
@@ -290,10 +329,8 @@ func EnsureAdsRouteUDP(
 // - zuerst bekannten erfolgreichen Port testen
 // - danach je nach TwinCAT-Version typische Runtime-Ports probieren
 //
-// Aktueller Stand:
-// Für den derzeitigen TwinCAT-System-State über Port 10000
-// ist die Funktion nicht aktiv nötig,
-// bleibt aber als vorbereitete Helper-Logik im Projekt.
+// Verwendet von TryReadTCState, um alle Runtimes eines Geräts
+// mit ihrem jeweiligen State einzusammeln.
 func candidateRuntimePorts(tcVersion string, preferred uint16) []uint16 {
 	var ports []uint16
 
@@ -337,137 +374,159 @@ func candidateRuntimePorts(tcVersion string, preferred uint16) []uint16 {
 }
 
 // ------------------------------------------------------------
-// TwinCAT System State lesen
+// TwinCAT-State eines Remote-Geräts lesen
 // ------------------------------------------------------------
 //
-// readTwinCATSystemState liest den TwinCAT-Systemzustand
-// über den Beckhoff System Service Port 10000.
+// TryReadTCState ist die zentrale High-Level-Funktion für scanner.go.
 //
-// Der eigentliche AMS/TCP-ReadState-Aufruf wird in readAdsStateRaw()
-// ausgeführt. Diese Funktion übersetzt nur den numerischen ADS-State
-// in einen lesbaren Status-String.
-func readTwinCATSystemState(remoteIP net.IP, remoteAmsNetID string, localIP net.IP) PlcStateResult {
-	resp, err := readAdsStateRaw(remoteIP, remoteAmsNetID, routeTcSystemService, localIP, 4*time.Second)
-	if err != nil {
-		return PlcStateResult{
-			Status: "no Info",
-			Err:    fmt.Sprintf("ReadState(port %d): %s", routeTcSystemService, err.Error()),
-		}
+// Ablauf:
+//  1. immer zuerst direkt verbinden und State lesen – auch ohne
+//     bekannt gute Route, z. B. wenn die Route von Hand angelegt wurde
+//     (kein AddRoute, die Routentabelle des Ziels bleibt unverändert)
+//  2. nur bei routenbedingtem Fehler (isRouteError):
+//     Admin-Sperre und Backoff prüfen, ADS-Route per UDP anlegen,
+//     erneut lesen
+//  3. Gerätename + Version per ReadDeviceInfo ergänzen
+//  4. alle PLC-Runtime-Ports über dieselbe Verbindung abfragen
+//     (State + Projekt je Runtime)
+//
+// tcVersion (aus der UDP-Discovery) bestimmt nur die Reihenfolge,
+// in der die Runtime-Ports probiert werden.
+//
+// cred sind die Zugangsdaten für AddRoute (siehe routeCredentialFor).
+//
+// route ist der bisherige Routen-Zustand des Geräts; der neue Zustand
+// wird in PlcStateResult.Route zurückgegeben (siehe route_cache.go).
+func TryReadTCState(
+	ctx context.Context,
+	localIP net.IP,
+	remoteIP net.IP,
+	remoteAmsNetID string,
+	tcVersion string,
+	cred RouteCredential,
+	route routeCacheEntry,
+) PlcStateResult {
+	now := time.Now()
+
+	// 1) Ohne AddRoute direkt lesen
+	res, err := readDeviceState(remoteIP, remoteAmsNetID, localIP, tcVersion)
+	if adsReached(err) {
+		res.Route = route.succeeded(now)
+		return res
+	}
+	if !isRouteError(err) {
+		// Gerät nicht erreichbar (Verbindungsaufbau, Timeout):
+		// Routen-Zustand bleibt, kein AddRoute
+		res.Route = route
+		return res
 	}
 
-	switch resp.AdsState {
-	case 5:
-		return PlcStateResult{
-			Status:      "RUN",
-			Err:         "",
-			RuntimePort: routeTcSystemService,
-		}
-	case 6:
-		return PlcStateResult{
-			Status:      "STOP",
-			Err:         "",
-			RuntimePort: routeTcSystemService,
-		}
-	case 15:
-		return PlcStateResult{
-			Status:      "CONFIG",
-			Err:         "",
-			RuntimePort: routeTcSystemService,
-		}
-	default:
-		return PlcStateResult{
-			Status:      fmt.Sprintf("State %d / Dev %d", resp.AdsState, resp.DeviceState),
-			Err:         "",
-			RuntimePort: routeTcSystemService,
-		}
+	if route.KnownGood {
+		fmt.Println("Route lost for", remoteIP.String(), "->", remoteAmsNetID, "err:", err)
+		route.KnownGood = false
 	}
-}
 
-// ------------------------------------------------------------
-// Beliebigen ADS-State über frei wählbaren AMS-Port lesen
-// ------------------------------------------------------------
-//
-// readAdsState ist die allgemeinere Variante zu readTwinCATSystemState().
-//
-// Sie kann prinzipiell auch für PLC-Runtime-Ports wie 801/851
-// oder weitere ADS-Ziele verwendet werden.
-func readAdsState(remoteIP net.IP, remoteAmsNetID string, amsPort uint16, localIP net.IP) PlcStateResult {
-	resp, err := readAdsStateRaw(remoteIP, remoteAmsNetID, amsPort, localIP, 4*time.Second)
-	if err != nil {
+	// 2) Route wurde von einem Admin gelöscht: nicht neu anlegen
+	if route.Removed {
 		return PlcStateResult{
 			Status: "no Info",
-			Err:    fmt.Sprintf("ReadState(port %d): %s", amsPort, err.Error()),
+			Err:    "route: removed by admin",
+			Route:  route,
 		}
 	}
 
-	switch resp.AdsState {
-	case 5:
-		return PlcStateResult{
-			Status:      "RUN",
-			Err:         "",
-			RuntimePort: amsPort,
-		}
-	case 6:
-		return PlcStateResult{
-			Status:      "STOP",
-			Err:         "",
-			RuntimePort: amsPort,
-		}
-	case 15:
+	// Backoff nach fehlgeschlagenen Versuchen
+	if route.inBackoff(now) {
 		return PlcStateResult{
-			Status:      "CONFIG",
-			Err:         "",
-			RuntimePort: amsPort,
-		}
-	default:
-		return PlcStateResult{
-			Status:      fmt.Sprintf("State %d / Dev %d", resp.AdsState, resp.DeviceState),
-			Err:         "",
-			RuntimePort: amsPort,
+			Status: "no Info",
+			Err:    "route: backoff until " + route.NextAttempt.Format("15:04:05"),
+			Route:  route,
 		}
 	}
-}
 
-// ------------------------------------------------------------
-// TwinCAT-State eines Remote-Geräts lesen
-// ------------------------------------------------------------
-//
-// TryReadTCState ist die zentrale High-Level-Funktion für scanner.go.
-//
-// Ablauf:
-// 1. ADS-Route zum Zielgerät sicherstellen
-// 2. TwinCAT System State über Port 10000 lesen
-//
-// Falls die Route nicht gesetzt werden kann,
-// wird direkt ein Fehler zurückgegeben.
-func TryReadTCState(ctx context.Context, localIP net.IP, remoteIP net.IP, remoteAmsNetID string) PlcStateResult {
-	// 1) Route anlegen
+	// Route anlegen
 	{
-		cctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		cctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.RouteTimeout))
 		defer cancel()
 
-		hostName, _ := os.Hostname()
-
-		if err := EnsureAdsRouteUDP(
+		if err := addAdsRoute(
 			cctx,
 			localIP,
 			remoteIP,
-			hostName,
-			"Administrator",
-			"1",
+			ownRouteName(),
+			cred.User,
+			cred.Password,
 			routeUdpPort48899,
+			cfg.TemporaryRoutes,
 		); err != nil {
-			fmt.Println("Route FAILED for", remoteIP.String(), "->", remoteAmsNetID, "err:", err)
+			route = route.failed(now)
+			recordRouteFailure()
+
+			fmt.Println("Route FAILED for", remoteIP.String(), "->", remoteAmsNetID, "user:", cred.User,
+				"err:", err, "retry after:", route.NextAttempt.Format("15:04:05"))
 
 			return PlcStateResult{
 				Status: "no Info",
 				Err:    "route: " + err.Error(),
+				Route:  route,
 			}
 		}
 
 		fmt.Println("Route OK for", remoteIP.String(), "->", remoteAmsNetID)
 	}
 
-	// 2) TwinCAT System State über Port 10000 lesen
-	return readTwinCATSystemState(remoteIP, remoteAmsNetID, localIP)
+	res, err = readDeviceState(remoteIP, remoteAmsNetID, localIP, tcVersion)
+	if !adsReached(err) {
+		// Route angeblich angelegt, Gerät antwortet trotzdem nicht
+		res.Route = route.failed(now)
+		recordRouteFailure()
+		return res
+	}
+
+	res.Route = route.succeeded(now)
+	return res
+}
+
+// readDeviceState öffnet eine ADS-Verbindung und liest
+// System-State, Geräteinfo und alle PLC-Runtimes.
+//
+// Der zurückgegebene Fehler ist der Fehler des System-ReadState
+// (für isRouteError); res enthält in jedem Fall Status/Err für die Anzeige.
+func readDeviceState(remoteIP net.IP, remoteAmsNetID string, localIP net.IP, tcVersion string) (PlcStateResult, error) {
+	client, err := dialAdsClient(remoteIP, remoteAmsNetID, localIP, time.Duration(cfg.ReadStateTimeout))
+	if err != nil {
+		return PlcStateResult{
+			Status: "no Info",
+			Err:    "connect: " + err.Error(),
+		}, err
+	}
+	defer client.Close()
+
+	// TwinCAT System State über Port 10000 lesen
+	st, err := client.ReadState(routeTcSystemService)
+	if err != nil {
+		recordAdsError(err)
+		return PlcStateResult{
+			Status: "no Info",
+			Err:    fmt.Sprintf("ReadState(port %d): %s", routeTcSystemService, err.Error()),
+		}, err
+	}
+
+	res := PlcStateResult{
+		Status:      adsStateName(st),
+		RuntimePort: routeTcSystemService,
+	}
+
+	// Gerätename + Version (Fehler hier sind nicht kritisch)
+	if info, err := client.ReadDeviceInfo(routeTcSystemService); err == nil {
+		res.DeviceName = info.Name
+		res.DeviceVersion = info.Version()
+	} else {
+		recordAdsError(err)
+	}
+
+	// Alle PLC-Runtimes mit eigenem State
+	res.Runtimes = readPlcRuntimes(client, candidateRuntimePorts(tcVersion, 0))
+
+	return res, nil
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuildAddRoutePacket(t *testing.T) {
+	netID := [6]byte{10, 99, 0, 1, 1, 1}
+
+	static := buildAddRoutePacket(netID, "INVENTAR", "Administrator", "1", "10.99.0.1", false)
+	temp := buildAddRoutePacket(netID, "INVENTAR", "Administrator", "1", "10.99.0.1", true)
+
+	// Header, Invoke, Request, Net ID, Port, Anzahl Tags
+	want := []byte{0x03, 0x66, 0x14, 0x71, 0, 0, 0, 0, 6, 0, 0, 0, 10, 99, 0, 1, 1, 1, 0x10, 0x27}
+	if !bytes.HasPrefix(static, want) || !bytes.HasPrefix(temp, want) {
+		t.Fatalf("header = % x, want % x", static[:len(want)], want)
+	}
+	if got := static[20:24]; !bytes.Equal(got, []byte{5, 0, 0, 0}) {
+		t.Errorf("static tag count = % x, want 5", got)
+	}
+	if got := temp[20:24]; !bytes.Equal(got, []byte{6, 0, 0, 0}) {
+		t.Errorf("temporary tag count = % x, want 6", got)
+	}
+
+	// Tags: Name, Net ID, Benutzer, Passwort, Host
+	tags := []byte{0x0c, 0, 9, 0}
+	tags = append(tags, "INVENTAR\x00"...)
+	tags = append(tags, 7, 0, 6, 0, 10, 99, 0, 1, 1, 1)
+	tags = append(tags, 0x0d, 0, 14, 0)
+	tags = append(tags, "Administrator\x00"...)
+	tags = append(tags, 2, 0, 2, 0)
+	tags = append(tags, "1\x00"...)
+	tags = append(tags, 5, 0, 10, 0)
+	tags = append(tags, "10.99.0.1\x00"...)
+
+	if got := static[24:]; !bytes.Equal(got, tags) {
+		t.Errorf("static tags = % x\nwant          % x", got, tags)
+	}
+
+	// temporär: dieselben Tags plus Flag-Tag 0x0e mit Wert 1
+	tempTags := append(append([]byte(nil), tags...), 0x0e, 0, 4, 0, 1, 0, 0, 0)
+	if got := temp[24:]; !bytes.Equal(got, tempTags) {
+		t.Errorf("temporary tags = % x\nwant             % x", got, tempTags)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Routen auf dem Zielsystem auflisten und löschen
+// ------------------------------------------------------------
+//
+// Bisher wurde bei jedem Gerät eine statische Route angelegt
+// und nie wieder entfernt. Über den System Service (Port 10000)
+// kann die Routentabelle des Ziels gelesen und die eigene Route
+// wieder gelöscht werden:
+//
+//	Read  IG 0x323 (ENUMREMOTE), IO = Index  → ein Eintrag je Aufruf
+//	Write IG 0x322 (DELREMOTE),  IO = 0      → Daten = Routenname + '\0'
+//
+// ENUMREMOTE liefert so lange Einträge, bis das Ziel mit einem
+// ADS-Fehler (0x716 "keine weiteren Einträge", adsErrNoMoreEntries)
+// antwortet.
+//
+// Auch mit temporaryRoutes (config.json) kann die eigene Route auf dem
+// Ziel statisch angelegt worden sein, da nicht jedes Ziel das
+// Temporär-Flag beachtet; DELETE entfernt sie in beiden Fällen.
+const (
+	adsIdxGrpSysServEnumRemote = 0x323
+	adsIdxGrpSysServDelRemote  = 0x322
+
+	adsErrNoMoreEntries = uint32(0x716)
+
+	// Puffergröße für einen Routeneintrag
+	adsRouteEntryLen = 0x800
+
+	// Schutz gegen Ziele, die nie einen Fehler liefern
+	adsMaxRouteEntries = 256
+)
+
+// AdsRoute ist ein Eintrag der Routentabelle eines Zielsystems.
+type AdsRoute struct {
+	Name     string `json:"name"`
+	NetID    string `json:"netId"`
+	Address  string `json:"address"`
+	Own      bool   `json:"own"` // Route gehört zu diesem Inventar
+	Resolved bool   `json:"-"`   // Layout sicher erkannt (Name + Adresse)
+}
+
+// ownRouteName liefert den Namen, unter dem dieses Inventar
+// seine Routen auf den Zielsystemen anlegt.
+func ownRouteName() string {
+	name, _ := os.Hostname()
+	if name == "" {
+		name = "GoInventory"
+	}
+	return name
+}
+
+// parseAdsRouteEntry zerlegt einen ENUMREMOTE-Eintrag.
+//
+// Das Layout ist von Beckhoff nicht offiziell dokumentiert.
+// Beobachtet (TwinCAT 2 und 3):
+//
+//	0..5    AMS Net ID
+//	6..35   Transporttyp, Flags, Timeout, ...
+//	36..39  Länge Adresse (inkl. '\0')
+//	40..43  Länge Name    (inkl. '\0')
+//	44..    Adresse, danach Name
+//
+// Passen die Längen nicht, werden als Fallback die ersten
+// beiden druckbaren Strings ab Offset 6 verwendet.
+func parseAdsRouteEntry(b []byte) (AdsRoute, error) {
+	if len(b) < 6 {
+		return AdsRoute{}, fmt.Errorf("route entry too short (%d bytes)", len(b))
+	}
+
+	route := AdsRoute{
+		NetID: fmt.Sprintf("%d.%d.%d.%d.%d.%d", b[0], b[1], b[2], b[3], b[4], b[5]),
+	}
+
+	if len(b) >= 44 {
+		addrLen := int(binary.LittleEndian.Uint32(b[36:40]))
+		nameLen := int(binary.LittleEndian.Uint32(b[40:44]))
+
+		if addrLen > 0 && nameLen > 0 && 44+addrLen+nameLen <= len(b) {
+			route.Address = strings.TrimRight(string(b[44:44+addrLen]), "\x00")
+			route.Name = strings.TrimRight(string(b[44+addrLen:44+addrLen+nameLen]), "\x00")
+			route.Resolved = true
+			return route, nil
+		}
+	}
+
+	strs := printableStrings(b[6:], 2)
+	if len(strs) > 0 {
+		route.Address = strs[0]
+	}
+	if len(strs) > 1 {
+		route.Name = strs[1]
+	}
+
+	return route, nil
+}
+
+// printableStrings sucht bis zu max nullterminierte ASCII-Strings
+// (mindestens 2 Zeichen) in b.
+func printableStrings(b []byte, max int) []string {
+	var out []string
+	start := -1
+
+	for i, c := range b {
+		if c >= 0x20 && c < 0x7f {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 && c == 0 && i-start >= 2 {
+			out = append(out, string(b[start:i]))
+			if len(out) == max {
+				return out
+			}
+		}
+		start = -1
+	}
+
+	return out
+}
+
+// listAdsRoutes liest die komplette Routentabelle des Ziels.
+func listAdsRoutes(c *AdsClient) ([]AdsRoute, error) {
+	var routes []AdsRoute
+
+	for i := uint32(0); i < adsMaxRouteEntries; i++ {
+		data, err := c.Read(routeTcSystemService, adsIdxGrpSysServEnumRemote, i, adsRouteEntryLen)
+		if err != nil {
+			var adsErr AdsError
+			if errors.As(err, &adsErr) {
+				// Ende der Tabelle
+				recordAdsError(err, adsErrNoMoreEntries)
+				return routes, nil
+			}
+			recordAdsError(err)
+			return routes, err
+		}
+
+		route, err := parseAdsRouteEntry(data)
+		if err != nil {
+			return routes, err
+		}
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// deleteAdsRoute löscht die Route mit dem angegebenen Namen auf dem Ziel.
+func deleteAdsRoute(c *AdsClient, name string) error {
+	return c.Write(routeTcSystemService, adsIdxGrpSysServDelRemote, 0, asciiZ(name))
+}
+
+// markOwnRoutes setzt Own für alle Routen, die auf dieses Inventar zeigen
+// (gleiche AMS Net ID oder gleicher Routenname).
+func markOwnRoutes(routes []AdsRoute, ownNetID, ownName string) {
+	for i := range routes {
+		routes[i].Own = routes[i].NetID == ownNetID || strings.EqualFold(routes[i].Name, ownName)
+	}
+}
+
+// ownAmsNetIDFor liefert die eigene AMS Net ID, mit der Routen zu remoteIP
+// angelegt werden (siehe EnsureAdsRouteUDP).
+func ownAmsNetIDFor(ip string) string {
+	remoteIP := net.ParseIP(ip).To4()
+	if remoteIP == nil {
+		return ""
+	}
+
+	localIP, err := localIPForRemote(remoteIP)
+	if err != nil {
+		return ""
+	}
+
+	id, err := localAmsNetIDFromIP(localIP)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d.%d.%d.%d.%d.%d", id[0], id[1], id[2], id[3], id[4], id[5])
+}
+
+// ------------------------------------------------------------
+// HTTP-Handler
+// ------------------------------------------------------------
+
+// deviceRoutesResponse ist die Antwort von GET /api/devices/{id}/routes.
+type deviceRoutesResponse struct {
+	OwnName  string     `json:"ownName"`
+	OwnNetID string     `json:"ownNetId"`
+	Routes   []AdsRoute `json:"routes"`
+}
+
+// handleDeviceRoutes verarbeitet GET/DELETE /api/devices/{id}/routes.
+//
+// Beispiel:
+//
+//	curl http://host:18080/api/devices/172.17.76.23/routes
+//	curl -X DELETE http://host:18080/api/devices/172.17.76.23/routes
+//	curl -X DELETE 'http://host:18080/api/devices/172.17.76.23/routes?name=INVENTAR-2'
+//	curl -X POST http://host:18080/api/devices/172.17.76.23/routes
+//
+// GET und DELETE arbeiten nur über eine bereits funktionierende Route
+// und liefern sonst 409; es wird dabei keine Route angelegt.
+// DELETE entfernt ohne ?name= die eigene Route. Fremde Routen
+// (andere Net ID und anderer Name) werden nicht gelöscht, ebenso keine
+// Einträge, deren Layout nicht sicher erkannt wurde (Resolved).
+// Jedes Löschen wird im Audit-Log festgehalten.
+//
+// Nach dem Löschen legen Scan, Rescan und State-Steuerung die Route
+// nicht wieder an (RouteRemoved), bis ein Admin sie per POST wieder
+// zulässt. POST legt selbst keine Route an, das geschieht beim
+// nächsten Zugriff.
+func handleDeviceRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dev, ok := findDevice(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "device not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		allowDeviceRoute(dev.IP)
+		appendAudit(r, auditRouteAllow, deviceAuditTarget(dev), "", nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if dev.AmsNetID == "" {
+		http.Error(w, "device has no AMS Net ID", http.StatusConflict)
+		return
+	}
+
+	ownName := ownRouteName()
+	ownNetID := ownAmsNetIDFor(dev.IP)
+
+	// Nur über eine bestehende Route: ohne Route gibt es nichts zu lesen
+	// oder zu löschen, und das Anlegen (AddRoute) würde genau die Route
+	// erzeugen, die DELETE entfernen soll
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(cfg.AdsDeviceTimeout))
+	defer cancel()
+
+	client, err := dialDeviceAds(ctx, dev)
+	if err != nil {
+		if r.Method == http.MethodDelete {
+			appendAudit(r, auditRouteDelete, deviceAuditTarget(dev), r.URL.Query().Get("name"), err)
+		}
+		if errors.Is(err, errNoAdsRoute) {
+			http.Error(w, "no working ADS route to device", http.StatusConflict)
+			return
+		}
+		http.Error(w, "ads connect failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer client.Close()
+
+	routes, err := listAdsRoutes(client)
+	if err != nil {
+		http.Error(w, "ads route list failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	markOwnRoutes(routes, ownNetID, ownName)
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, deviceRoutesResponse{
+			OwnName:  ownName,
+			OwnNetID: ownNetID,
+			Routes:   routes,
+		})
+		return
+	}
+
+	// DELETE
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = ownName
+	}
+
+	var target *AdsRoute
+	for i := range routes {
+		if strings.EqualFold(routes[i].Name, name) {
+			target = &routes[i]
+			break
+		}
+	}
+
+	if target == nil {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
+
+	if !target.Own {
+		http.Error(w, "route does not belong to this inventory", http.StatusForbidden)
+		return
+	}
+
+	// Name nur geraten (printableStrings) → nicht auf Verdacht löschen
+	if !target.Resolved {
+		http.Error(w, "route entry layout not recognized, refusing to delete", http.StatusConflict)
+		return
+	}
+
+	err = deleteAdsRoute(client, target.Name)
+	recordAdsError(err)
+	appendAudit(r, auditRouteDelete, deviceAuditTarget(dev), target.Name, err)
+	if err != nil {
+		http.Error(w, "ads route delete failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// Route ist weg und soll es bleiben → kein AddRoute mehr
+	inventoryMutex.Lock()
+	if d, ok := inventory[dev.IP]; ok {
+		d.RouteKnownGood = false
+		d.RouteRemoved = true
+	}
+	inventoryMutex.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowDeviceRoute hebt die Sperre nach einem Löschen auf; der nächste
+// Zugriff darf die Route wieder anlegen (ohne Backoff).
+func allowDeviceRoute(ip string) {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	if d, ok := inventory[ip]; ok {
+		d.RouteRemoved = false
+		d.RouteFailures = 0
+		d.NextRouteAttempt = time.Time{}
+	}
+}
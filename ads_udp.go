@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
@@ -155,26 +156,43 @@ func maxInt(a, b int) int {
 // ------------------------------------------------------------
 //
 
-// isInLabSubnetIP prüft, ob eine IPv4-Adresse im Testnetz 172.17.76.0/24 liegt.
+// isInLabSubnetIP prüft, ob eine IPv4-Adresse in einem der
+// konfigurierten Scan-Bereiche liegt.
 func isInLabSubnetIP(ip net.IP) bool {
 	ip4 := ip.To4()
-	return ip4 != nil && ip4[0] == 172 && ip4[1] == 17 && ip4[2] == 76
+	if ip4 == nil {
+		return false
+	}
+
+	for _, n := range scanNets {
+		if n.Contains(ip4) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localLabAddr beschreibt eine lokale IPv4-Adresse samt Netzmaske,
+// die in einem der konfigurierten Scan-Bereiche liegt.
+type localLabAddr struct {
+	IP   net.IP
+	Mask net.IPMask
 }
 
-// getLocalLabIPv4 sucht auf dem lokalen Rechner die IPv4-Adresse,
-// die im Testnetz 172.17.76.0/24 liegt.
+// getLocalLabAddrs sucht auf dem lokalen Rechner alle IPv4-Adressen,
+// die in einem der konfigurierten Scan-Bereiche liegen.
 //
-// Diese Adresse wird für:
-// - UDP-Broadcast-Discovery
-// - ADS-Route-Aufbau
-// - spätere ADS-Kommunikation
-// verwendet.
-func getLocalLabIPv4() (net.IP, net.IPMask, error) {
+// Bei mehreren VLANs besitzt der Server typischerweise
+// eine Adresse pro Segment.
+func getLocalLabAddrs() ([]localLabAddr, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
+	var out []localLabAddr
+
 	for _, iface := range ifaces {
 		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
 			continue
@@ -196,13 +214,76 @@ func getLocalLabIPv4() (net.IP, net.IPMask, error) {
 				continue
 			}
 
+			mask := ipnet.Mask
+			if len(mask) == net.IPv6len {
+				mask = mask[12:]
+			}
+
 			if isInLabSubnetIP(ip4) {
-				return ip4, ipnet.Mask, nil
+				out = append(out, localLabAddr{IP: ip4, Mask: mask})
 			}
 		}
 	}
 
-	return nil, nil, fmt.Errorf("no local IPv4 found in 172.17.76.0/24")
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no local IPv4 found in %s", scanRangesString())
+	}
+
+	return out, nil
+}
+
+// localIPForRemote wählt die lokale IPv4-Adresse,
+// über die ein bestimmtes Zielgerät angesprochen werden soll.
+//
+// Diese Adresse wird für:
+// - ADS-Route-Aufbau
+// - lokale AMS Net ID (<lokale IPv4>.1.1)
+// - ADS-Kommunikation
+// verwendet.
+//
+// Bevorzugt wird die Adresse, deren Subnetz das Ziel enthält.
+// Liegt das Ziel hinter einem Router, wird die Adresse genommen,
+// die auch das Betriebssystem als Absender wählen würde –
+// nur diese sieht das Zielgerät (wichtig für Route und AMS Net ID).
+// Sonst wird die erste Adresse in einem Scan-Bereich genommen.
+func localIPForRemote(remoteIP net.IP) (net.IP, error) {
+	addrs, err := getLocalLabAddrs()
+
+	for _, a := range addrs {
+		if (&net.IPNet{IP: a.IP.Mask(a.Mask), Mask: a.Mask}).Contains(remoteIP) {
+			return a.IP, nil
+		}
+	}
+
+	if ip, oerr := osSourceIPFor(remoteIP); oerr == nil {
+		return ip, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return addrs[0].IP, nil
+}
+
+// osSourceIPFor fragt das Betriebssystem, mit welcher lokalen Adresse
+// es ein Paket an remoteIP schicken würde.
+//
+// Ein "verbundener" UDP-Socket sendet dabei nichts,
+// er wählt nur Route und Absenderadresse.
+func osSourceIPFor(remoteIP net.IP) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: remoteIP, Port: udpPort48899})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || local.IP.To4() == nil || local.IP.IsUnspecified() {
+		return nil, fmt.Errorf("no source address for %s", remoteIP)
+	}
+
+	return local.IP.To4(), nil
 }
 
 // broadcastAddr berechnet aus IP + Netzmaske die Broadcast-Adresse.
@@ -520,17 +601,81 @@ func utf16LEToASCII(b []byte) string {
 // ------------------------------------------------------------
 //
 // Ablauf:
-// 1. lokale Testnetz-IP bestimmen
-// 2. Broadcast-Adresse berechnen
-// 3. Discovery-Paket senden
-// 4. Antworten bis Timeout einsammeln
-// 5. Antworten parsen und sortieren
+// 1. alle lokalen Adressen in den Scan-Bereichen bestimmen
+// 2. pro Adresse parallel einen Broadcast senden
+// 3. parallel dazu geroutete Bereiche per Unicast abfragen
+// 4. Antworten zusammenführen (pro IP nur ein Eintrag)
+// 5. nach IP sortieren
 func discoverPlcsUDP(ctx context.Context, timeout time.Duration) ([]RemotePlcInfo, error) {
-	localIP, mask, err := getLocalLabIPv4()
-	if err != nil {
+	addrs, err := getLocalLabAddrs()
+
+	// Geroutete Bereiche (bzw. alle, je nach Konfiguration) per Unicast
+	unicast := unicastDiscoveryTargets(addrs)
+
+	if err != nil && len(unicast) == 0 {
 		return nil, err
 	}
 
+	type result struct {
+		devs []RemotePlcInfo
+		err  error
+	}
+
+	jobs := len(addrs)
+	results := make(chan result, jobs+1)
+
+	for _, a := range addrs {
+		go func(a localLabAddr) {
+			devs, err := discoverPlcsOnAddr(ctx, a.IP, a.Mask, timeout)
+			results <- result{devs: devs, err: err}
+		}(a)
+	}
+
+	if len(unicast) > 0 {
+		jobs++
+		go func() {
+			devs, err := discoverPlcsUnicast(ctx, unicast, timeout)
+			results <- result{devs: devs, err: err}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	var out []RemotePlcInfo
+	var firstErr error
+
+	for range jobs {
+		r := <-results
+
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+
+		for _, d := range r.devs {
+			key := d.Address.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, d)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return bytes.Compare(out[i].Address.To4(), out[j].Address.To4()) < 0
+	})
+
+	return out, firstErr
+}
+
+// discoverPlcsOnAddr führt die Broadcast-Discovery
+// über eine einzelne lokale Adresse aus.
+//
+// Ablauf:
+// 1. Broadcast-Adresse berechnen
+// 2. Discovery-Paket senden
+// 3. Antworten bis Timeout einsammeln
+// 4. Antworten parsen
+func discoverPlcsOnAddr(ctx context.Context, localIP net.IP, mask net.IPMask, timeout time.Duration) ([]RemotePlcInfo, error) {
 	bc, err := broadcastAddr(localIP, mask)
 	if err != nil {
 		return nil, err
@@ -559,10 +704,7 @@ func discoverPlcsUDP(ctx context.Context, timeout time.Duration) ([]RemotePlcInf
 	for {
 		select {
 		case <-ctx.Done():
-			// Vorzeitig abbrechen: bereits gesammelte Geräte sortiert zurückgeben
-			sort.Slice(out, func(i, j int) bool {
-				return bytes.Compare(out[i].Address.To4(), out[j].Address.To4()) < 0
-			})
+			// Vorzeitig abbrechen: bereits gesammelte Geräte zurückgeben
 			return out, ctx.Err()
 		default:
 		}
@@ -588,10 +730,64 @@ func discoverPlcsUDP(ctx context.Context, timeout time.Duration) ([]RemotePlcInf
 		out = append(out, dev)
 	}
 
-	// Ergebnis nach IP sortieren
-	sort.Slice(out, func(i, j int) bool {
-		return bytes.Compare(out[i].Address.To4(), out[j].Address.To4()) < 0
-	})
-
 	return out, nil
 }
+
+// errNoDiscoveryResponse meldet, dass ein Gerät nicht auf die
+// Unicast-Discovery geantwortet hat (kein TwinCAT oder Port 48899 gesperrt).
+var errNoDiscoveryResponse = errors.New("no ADS discovery response")
+
+// discoverPlcUnicast schickt den Discovery-Request direkt an ein
+// einzelnes Gerät statt an die Broadcast-Adresse.
+//
+// Antworten anderer Absender werden ignoriert.
+func discoverPlcUnicast(ctx context.Context, remoteIP net.IP, timeout time.Duration) (RemotePlcInfo, error) {
+	localIP, err := localIPForRemote(remoteIP)
+	if err != nil {
+		return RemotePlcInfo{}, err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: localIP, Port: 0})
+	if err != nil {
+		return RemotePlcInfo{}, err
+	}
+	defer conn.Close()
+
+	req := buildDiscoverRequest(localIP)
+
+	_ = conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
+	if _, err := conn.WriteToUDP(req, &net.UDPAddr{IP: remoteIP, Port: udpPort48899}); err != nil {
+		return RemotePlcInfo{}, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	buf := make([]byte, 4096)
+
+	for {
+		_ = conn.SetReadDeadline(deadline)
+
+		n, raddr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return RemotePlcInfo{}, errNoDiscoveryResponse
+			}
+			return RemotePlcInfo{}, err
+		}
+
+		if !raddr.IP.Equal(remoteIP) {
+			continue
+		}
+
+		rr := &udpResponseResult{
+			Buffer:     append([]byte(nil), buf[:n]...),
+			RemoteHost: raddr.IP,
+			Shift:      0,
+		}
+
+		return parseBroadcastSearchResponse(rr), nil
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Unicast-Discovery für geroutete Segmente
+// ------------------------------------------------------------
+//
+// Der Broadcast aus discoverPlcsOnAddr erreicht nur das eigene Subnetz.
+// SPSen hinter einem Router werden so nie erkannt (keine AMS Net ID,
+// kein OS, keine TwinCAT-Version). Für diese Bereiche wird derselbe
+// Discovery-Request (buildDiscoverRequest) an jede einzelne Adresse
+// geschickt.
+//
+// Modi (Config.UnicastDiscovery):
+//
+//	auto  nur Scan-Bereiche ohne eigene lokale Adresse (Standard)
+//	all   alle Adressen aller Scan-Bereiche, zusätzlich zum Broadcast
+//	off   nur Broadcast wie bisher
+//
+// Die Pakete werden mit höchstens Config.DiscoveryRate pro Sekunde
+// verschickt. Pro lokaler Absenderadresse gibt es einen Socket,
+// Senden und Empfangen laufen parallel.
+const (
+	unicastDiscoveryAuto = "auto"
+	unicastDiscoveryAll  = "all"
+	unicastDiscoveryOff  = "off"
+)
+
+// unicastDiscoveryTargets liefert die Adressen, die per Unicast
+// abgefragt werden sollen.
+//
+// local sind die lokalen Adressen in den Scan-Bereichen
+// (siehe getLocalLabAddrs); ein Scan-Bereich gilt als lokal,
+// wenn er vollständig im Subnetz einer dieser Adressen liegt.
+func unicastDiscoveryTargets(local []localLabAddr) []net.IP {
+	var nets []*net.IPNet
+
+	switch cfg.UnicastDiscovery {
+	case unicastDiscoveryAll:
+		nets = scanNets
+
+	case unicastDiscoveryAuto:
+		for _, n := range scanNets {
+			if !isLocalScanNet(n, local) {
+				nets = append(nets, n)
+			}
+		}
+
+	default:
+		return nil
+	}
+
+	var out []net.IP
+	seen := make(map[string]bool)
+
+	for _, n := range nets {
+		for _, ip := range hostsInNet(n) {
+			if seen[ip] {
+				continue
+			}
+			seen[ip] = true
+			out = append(out, net.ParseIP(ip).To4())
+		}
+	}
+
+	return out
+}
+
+// isLocalScanNet prüft, ob ein Scan-Bereich per Broadcast erreichbar ist.
+func isLocalScanNet(n *net.IPNet, local []localLabAddr) bool {
+	scanOnes, _ := n.Mask.Size()
+
+	for _, a := range local {
+		localNet := &net.IPNet{IP: a.IP.Mask(a.Mask), Mask: a.Mask}
+		localOnes, _ := a.Mask.Size()
+
+		if localOnes <= scanOnes && localNet.Contains(n.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unicastSendDuration schätzt, wie lange das Verschicken
+// von n Discovery-Paketen bei der konfigurierten Rate dauert.
+func unicastSendDuration(n int) time.Duration {
+	if n == 0 || cfg.DiscoveryRate <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second / time.Duration(cfg.DiscoveryRate)
+}
+
+// discoveryBudget liefert das Zeitbudget für die komplette
+// Discovery-Phase: DiscoveryTimeout plus die Sendedauer der Unicast-Pakete.
+func discoveryBudget() time.Duration {
+	addrs, _ := getLocalLabAddrs()
+	return time.Duration(cfg.DiscoveryTimeout) + unicastSendDuration(len(unicastDiscoveryTargets(addrs)))
+}
+
+// discoverPlcsUnicast schickt den Discovery-Request an alle targets
+// und sammelt die Antworten.
+//
+// listen ist die Sammeldauer nach dem letzten gesendeten Paket.
+func discoverPlcsUnicast(ctx context.Context, targets []net.IP, listen time.Duration) ([]RemotePlcInfo, error) {
+	// Ziele nach lokaler Absenderadresse gruppieren
+	groups := make(map[string][]net.IP)
+	locals := make(map[string]net.IP)
+
+	for _, ip := range targets {
+		localIP, err := localIPForRemote(ip)
+		if err != nil {
+			return nil, err
+		}
+		key := localIP.String()
+		groups[key] = append(groups[key], ip)
+		locals[key] = localIP
+	}
+
+	// Gemeinsamer Takt für alle Sockets → Rate gilt insgesamt
+	rate := max(cfg.DiscoveryRate, 1)
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	var (
+		mu       sync.Mutex
+		out      []RemotePlcInfo
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	for key, ips := range groups {
+		wg.Add(1)
+
+		go func(localIP net.IP, ips []net.IP) {
+			defer wg.Done()
+
+			devs, err := discoverUnicastFrom(ctx, localIP, ips, listen, ticker.C)
+
+			mu.Lock()
+			out = append(out, devs...)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+		}(locals[key], ips)
+	}
+
+	wg.Wait()
+
+	fmt.Printf("ADS unicast discovery: %d addresses, %d answers\n", len(targets), len(out))
+
+	return out, firstErr
+}
+
+// discoverUnicastFrom verschickt die Requests über einen Socket
+// auf localIP und liest parallel die Antworten.
+//
+// tick begrenzt die Senderate (ein Paket pro Tick).
+func discoverUnicastFrom(
+	ctx context.Context,
+	localIP net.IP,
+	targets []net.IP,
+	listen time.Duration,
+	tick <-chan time.Time,
+) ([]RemotePlcInfo, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: localIP, Port: 0})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	wanted := make(map[string]bool, len(targets))
+	for _, ip := range targets {
+		wanted[ip.String()] = true
+	}
+
+	// Bis zum Ende des Sendens nicht abbrechen; danach setzt
+	// der Sender die Frist auf "jetzt + listen".
+	if d, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(d)
+	}
+
+	// Empfänger
+	type recvResult struct {
+		devs []RemotePlcInfo
+		err  error
+	}
+	recvDone := make(chan recvResult, 1)
+
+	go func() {
+		buf := make([]byte, 4096)
+		seen := make(map[string]bool)
+		var devs []RemotePlcInfo
+
+		for {
+			n, raddr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					recvDone <- recvResult{devs: devs}
+					return
+				}
+				if errors.Is(err, net.ErrClosed) {
+					recvDone <- recvResult{devs: devs, err: err}
+					return
+				}
+
+				// Windows meldet ICMP "port unreachable" einzelner Ziele
+				// als Lesefehler (WSAECONNRESET) – weiterlesen.
+				continue
+			}
+
+			key := raddr.IP.String()
+			if !wanted[key] || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			rr := &udpResponseResult{
+				Buffer:     append([]byte(nil), buf[:n]...),
+				RemoteHost: raddr.IP,
+				Shift:      0,
+			}
+
+			devs = append(devs, parseBroadcastSearchResponse(rr))
+		}
+	}()
+
+	// Sender
+	req := buildDiscoverRequest(localIP)
+	var sendErr error
+
+send:
+	for _, ip := range targets {
+		select {
+		case <-ctx.Done():
+			sendErr = ctx.Err()
+			break send
+		case <-tick:
+		}
+
+		// Sendefehler einzelner Ziele (z. B. "host unreachable") ignorieren
+		_ = conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
+		_, _ = conn.WriteToUDP(req, &net.UDPAddr{IP: ip, Port: udpPort48899})
+	}
+
+	// Sammelphase nach dem letzten Paket, höchstens bis zum Kontext-Ende
+	deadline := time.Now().Add(listen)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	r := <-recvDone
+	if r.err == nil {
+		r.err = sendErr
+	}
+
+	return r.devs, r.err
+}
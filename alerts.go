@@ -0,0 +1,1249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Alarme bei Zustandsänderungen
+// ------------------------------------------------------------
+//
+// Nach jedem Scan (runDiscovery) werden alle Regeln gegen den
+// Stand vor und nach dem Scan geprüft. Regeltypen:
+//
+//	state_left  Runtime verlässt einen State (Standard: RUN),
+//	            z. B. "PLC in Büro T4020 verlässt RUN"
+//	offline     Gerät länger als "for" nicht erreichbar
+//	new_mac     unbekannte MAC-Adresse taucht zum ersten Mal auf
+//	            (auch beim Einzel-Rescan; ohne Büro-Filter, weil
+//	            eine neue MAC noch keinem Büro zugeordnet ist)
+//
+// Entprellung:
+//
+//   - "for":    die Bedingung muss so lange ununterbrochen anstehen,
+//     bevor gemeldet wird (bei offline: ab LastSeenOnline)
+//   - pro Regel und Gerät wird nur einmal gemeldet, bis die Bedingung
+//     wieder weg ist; "repeat" > 0 meldet in diesem Abstand erneut
+//   - "notifyResolved" meldet zusätzlich das Ende der Bedingung
+//
+// Kanäle (beliebig kombinierbar):
+//
+//	webhook  POST mit AlertNotification als JSON
+//	email    über den Mailserver aus Config.SMTPAddr
+//	command  lokaler Befehl, nur mit Config.AlertCommands
+//
+// Regeln liegen im Dokument "alert_rules", der Entprell-Zustand in
+// "alert_state" (damit ein Neustart nicht alle Alarme erneut auslöst).
+// Jede Benachrichtigung wird im Event-Stream "alerts" protokolliert.
+//
+// Beispiel-Regel:
+//
+//	{"name":"T4020 nicht in RUN","enabled":true,"kind":"state_left",
+//	 "office":"T4020","state":"RUN","port":851,"for":"2m",
+//	 "webhook":"https://chat.example/hooks/plc","email":["labor@example.com"]}
+type AlertRule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Kind    string `json:"kind"` // siehe alertKind*-Konstanten
+
+	// Filter / Bedingung
+	Office string `json:"office,omitempty"` // nur Geräte dieses Büros ("" = alle)
+	State  string `json:"state,omitempty"`  // state_left: verlassener State
+	Port   int    `json:"port,omitempty"`   // state_left: 0 = TwinCAT-System, sonst PLC-Runtime-Port
+
+	// Entprellung
+	For            Duration `json:"for,omitempty"`    // Mindestdauer der Bedingung
+	Repeat         Duration `json:"repeat,omitempty"` // erneut melden, solange aktiv (0 = nie)
+	NotifyResolved bool     `json:"notifyResolved,omitempty"`
+
+	// Kanäle
+	Webhook string   `json:"webhook,omitempty"`
+	Email   []string `json:"email,omitempty"`
+	Command string   `json:"command,omitempty"`
+}
+
+// Regeltypen
+const (
+	alertKindStateLeft = "state_left"
+	alertKindOffline   = "offline"
+	alertKindNewMAC    = "new_mac"
+)
+
+// Status einer Benachrichtigung
+const (
+	alertStatusFiring   = "firing"
+	alertStatusResolved = "resolved"
+	alertStatusTest     = "test"
+)
+
+const (
+	alertRulesDocument = "alert_rules"
+	alertStateDocument = "alert_state"
+	alertLogStream     = "alerts"
+
+	// Zeitlimit pro Kanal und Benachrichtigung
+	alertDeliveryTimeout = 30 * time.Second
+)
+
+// AlertDevice ist die Gerätesicht in einer Benachrichtigung.
+type AlertDevice struct {
+	IP            string `json:"ip"`
+	MAC           string `json:"mac"`
+	Hostname      string `json:"hostname,omitempty"`
+	Office        string `json:"office,omitempty"`
+	AmsNetID      string `json:"amsNetId,omitempty"`
+	Online        bool   `json:"online"`
+	RuntimeStatus string `json:"runtimeStatus,omitempty"`
+}
+
+// AlertNotification wird an alle Kanäle einer Regel verschickt.
+//
+// Beispiel:
+//
+//	{"ruleId":"3f2a...","rule":"T4020 nicht in RUN","kind":"state_left",
+//	 "status":"firing","time":"...","since":"...",
+//	 "message":"172.17.76.23 (T4020): Port 851 hat RUN verlassen, jetzt STOP",
+//	 "device":{"ip":"172.17.76.23","mac":"00:01:05:12:34:56",...}}
+type AlertNotification struct {
+	RuleID  string      `json:"ruleId"`
+	Rule    string      `json:"rule"`
+	Kind    string      `json:"kind"`
+	Status  string      `json:"status"` // firing, resolved oder test
+	Time    time.Time   `json:"time"`
+	Since   time.Time   `json:"since"` // seit wann die Bedingung ansteht
+	Message string      `json:"message"`
+	Device  AlertDevice `json:"device"`
+}
+
+// alertLogEntry ist ein Eintrag im Stream "alerts".
+type alertLogEntry struct {
+	AlertNotification
+	Errors []string `json:"errors,omitempty"` // fehlgeschlagene Kanäle
+}
+
+// alertState ist der Entprell-Zustand einer Regel für ein Gerät.
+type alertState struct {
+	Since    time.Time `json:"since"`              // Bedingung steht seit
+	Fired    bool      `json:"fired"`              // bereits gemeldet
+	LastSent time.Time `json:"lastSent,omitempty"` // letzte Meldung (für repeat)
+}
+
+var (
+	// alertRules sind alle Regeln in Anzeigereihenfolge.
+	alertRules []AlertRule
+
+	// alertStates: Key = Regel-ID + "|" + MAC.
+	alertStates = make(map[string]*alertState)
+
+	// alertMutex schützt alertRules und alertStates.
+	alertMutex sync.Mutex
+
+	// alertDeliveries zählt laufende Hintergrund-Versände
+	// einschließlich des Eintrags ins Alarm-Protokoll.
+	alertDeliveries sync.WaitGroup
+)
+
+// ------------------------------------------------------------
+// Laden / Speichern
+// ------------------------------------------------------------
+
+// loadAlerts lädt Regeln und Entprell-Zustand.
+func loadAlerts() error {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	var rules []AlertRule
+	if _, err := store.LoadDocument(alertRulesDocument, &rules); err != nil {
+		return err
+	}
+
+	states := make(map[string]*alertState)
+	if _, err := store.LoadDocument(alertStateDocument, &states); err != nil {
+		return err
+	}
+
+	alertRules = rules
+	alertStates = states
+	return nil
+}
+
+// saveAlertRulesLocked speichert alle Regeln.
+// Aufrufer muss alertMutex halten.
+func saveAlertRulesLocked() error {
+	return store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(alertRulesDocument, alertRules)
+	})
+}
+
+// renameAlertRuleOfficeLocked stellt den Büro-Filter aller Regeln um
+// (Umbenennen im Büro-Katalog).
+//
+// Der neue Stand wird in tx geschrieben; apply übernimmt ihn erst nach
+// erfolgreichem Speichern der Transaktion in den Speicher.
+//
+// Aufrufer muss alertMutex halten.
+func renameAlertRuleOfficeLocked(tx StorageTx, oldName, newName string) (apply func(), err error) {
+	rules := append([]AlertRule{}, alertRules...)
+
+	changed := false
+	for i := range rules {
+		if rules[i].Office == oldName {
+			rules[i].Office = newName
+			changed = true
+		}
+	}
+	if !changed {
+		return func() {}, nil
+	}
+
+	if err := tx.PutDocument(alertRulesDocument, rules); err != nil {
+		return nil, err
+	}
+	return func() { alertRules = rules }, nil
+}
+
+// saveAlertStatesLocked speichert den Entprell-Zustand.
+// Aufrufer muss alertMutex halten.
+func saveAlertStatesLocked() error {
+	return store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(alertStateDocument, alertStates)
+	})
+}
+
+// ------------------------------------------------------------
+// Auswertung
+// ------------------------------------------------------------
+
+// alertDeviceState liefert den State, den eine state_left-Regel prüft
+// ("" = unbekannt, z. B. kein ADS-Gerät oder Runtime nicht gefunden).
+func alertDeviceState(dev IPC, port int) string {
+	if port == 0 || port == int(routeTcSystemService) {
+		return runtimeStatusBase(dev.RuntimeStatus)
+	}
+
+	for _, rt := range dev.Runtimes {
+		if int(rt.Port) == port {
+			return rt.State
+		}
+	}
+
+	return ""
+}
+
+// alertDeviceName liefert eine kurze Gerätebezeichnung für Meldungstexte.
+func alertDeviceName(dev IPC) string {
+	name := dev.IP
+	if dev.Hostname != "" {
+		name = dev.Hostname + " / " + dev.IP
+	}
+	if dev.Office != "" {
+		name += " (" + dev.Office + ")"
+	}
+	return name
+}
+
+// newAlertNotification baut die Benachrichtigung für ein Gerät.
+func newAlertNotification(rule AlertRule, dev IPC, status string, since, now time.Time) AlertNotification {
+	n := AlertNotification{
+		RuleID: rule.ID,
+		Rule:   rule.Name,
+		Kind:   rule.Kind,
+		Status: status,
+		Time:   now,
+		Since:  since,
+		Device: AlertDevice{
+			IP:            dev.IP,
+			MAC:           normalizeMAC(dev.MACAddress),
+			Hostname:      dev.Hostname,
+			Office:        dev.Office,
+			AmsNetID:      dev.AmsNetID,
+			Online:        dev.IsReachable,
+			RuntimeStatus: dev.RuntimeStatus,
+		},
+	}
+
+	name := alertDeviceName(dev)
+
+	switch rule.Kind {
+	case alertKindStateLeft:
+		target := "TwinCAT"
+		if rule.Port != 0 && rule.Port != int(routeTcSystemService) {
+			target = "Port " + strconv.Itoa(rule.Port)
+		}
+		cur := alertDeviceState(dev, rule.Port)
+		if status == alertStatusResolved {
+			n.Message = fmt.Sprintf("%s: %s wieder in %s", name, target, rule.State)
+		} else {
+			n.Message = fmt.Sprintf("%s: %s hat %s verlassen, jetzt %s", name, target, rule.State, cur)
+		}
+
+	case alertKindOffline:
+		if status == alertStatusResolved {
+			n.Message = fmt.Sprintf("%s: wieder online", name)
+		} else {
+			n.Message = fmt.Sprintf("%s: offline seit %s", name, since.Format("02.01.2006 15:04"))
+		}
+
+	case alertKindNewMAC:
+		n.Message = fmt.Sprintf("%s: neue MAC-Adresse %s", name, n.Device.MAC)
+	}
+
+	if status == alertStatusTest {
+		n.Message = "Test: " + n.Message
+	}
+
+	return n
+}
+
+// alertCondition prüft eine Regel für ein Gerät.
+//
+// active = Bedingung steht an, since = seit wann.
+// known = false heißt: keine Aussage möglich, Zustand unverändert lassen.
+func alertCondition(rule AlertRule, prev IPC, hadPrev bool, cur IPC, st *alertState, now time.Time) (active bool, since time.Time, known bool) {
+	switch rule.Kind {
+	case alertKindStateLeft:
+		state := alertDeviceState(cur, rule.Port)
+		if state == "" {
+			return false, time.Time{}, false
+		}
+
+		if st != nil {
+			// Bereits verfolgt: aktiv, bis der State zurück ist
+			return state != rule.State, st.Since, true
+		}
+
+		// Neu nur beim Übergang State -> anderer State
+		left := hadPrev && alertDeviceState(prev, rule.Port) == rule.State && state != rule.State
+		return left, now, true
+
+	case alertKindOffline:
+		if cur.IsReachable || cur.LastSeenOnline.IsZero() {
+			return false, time.Time{}, true
+		}
+		return true, cur.LastSeenOnline, true
+	}
+
+	return false, time.Time{}, true
+}
+
+// pendingAlert ist eine Benachrichtigung, die nach dem Prüfen
+// (außerhalb von alertMutex) verschickt wird.
+type pendingAlert struct {
+	rule AlertRule
+	n    AlertNotification
+}
+
+// sendPendingAlerts verschickt die Benachrichtigungen im Hintergrund.
+func sendPendingAlerts(out []pendingAlert) {
+	for _, p := range out {
+		fmt.Println("Alarm:", p.n.Rule, "-", p.n.Message)
+		alertDeliveries.Add(1)
+		go func(p pendingAlert) {
+			defer alertDeliveries.Done()
+			deliverAlert(p.rule, p.n)
+		}(p)
+	}
+}
+
+// evaluateNewMACAlerts prüft nur die new_mac-Regeln für ein Gerät,
+// das außerhalb eines Vollscans (Einzel-Rescan) gefunden wurde.
+//
+// before ist der Stand vor dem Rescan (snapshotByMACLocked).
+func evaluateNewMACAlerts(before map[string]IPC, cur IPC, now time.Time) {
+	mac := normalizeMAC(cur.MACAddress)
+	if mac == "" || len(before) == 0 {
+		return
+	}
+	if _, hadPrev := before[mac]; hadPrev {
+		return
+	}
+
+	var out []pendingAlert
+
+	alertMutex.Lock()
+	for _, rule := range alertRules {
+		if !rule.Enabled || rule.Kind != alertKindNewMAC {
+			continue
+		}
+		out = append(out, pendingAlert{rule, newAlertNotification(rule, cur, alertStatusFiring, now, now)})
+	}
+	alertMutex.Unlock()
+
+	sendPendingAlerts(out)
+}
+
+// evaluateAlerts prüft alle Regeln nach einem Scan.
+//
+// before/after sind die Stände vor und nach dem Scan (snapshotByMACLocked).
+// Benachrichtigungen werden im Hintergrund verschickt.
+func evaluateAlerts(before, after map[string]IPC, now time.Time) {
+	var out []pendingAlert
+
+	alertMutex.Lock()
+
+	activeRules := make(map[string]bool, len(alertRules))
+
+	macs := make([]string, 0, len(after))
+	for mac := range after {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+
+	for _, rule := range alertRules {
+		if !rule.Enabled {
+			continue
+		}
+		activeRules[rule.ID] = true
+
+		for _, mac := range macs {
+			cur := after[mac]
+			prev, hadPrev := before[mac]
+
+			// new_mac ist ein einmaliges Ereignis ohne Zustand und ohne
+			// Büro-Filter (ein neues Gerät hat noch keine Zuordnung).
+			// Beim allerersten Scan (leeres Inventory) wäre jedes Gerät "neu".
+			if rule.Kind == alertKindNewMAC {
+				if !hadPrev && len(before) > 0 {
+					out = append(out, pendingAlert{rule, newAlertNotification(rule, cur, alertStatusFiring, now, now)})
+				}
+				continue
+			}
+
+			if rule.Office != "" && cur.Office != rule.Office {
+				continue
+			}
+
+			key := rule.ID + "|" + mac
+			st := alertStates[key]
+
+			active, since, known := alertCondition(rule, prev, hadPrev, cur, st, now)
+			if !known {
+				continue
+			}
+
+			if !active {
+				if st != nil {
+					if st.Fired && rule.NotifyResolved {
+						out = append(out, pendingAlert{rule, newAlertNotification(rule, cur, alertStatusResolved, st.Since, now)})
+					}
+					delete(alertStates, key)
+				}
+				continue
+			}
+
+			if st == nil {
+				st = &alertState{Since: since}
+				alertStates[key] = st
+			}
+
+			switch {
+			case !st.Fired && now.Sub(st.Since) >= time.Duration(rule.For):
+				st.Fired = true
+				st.LastSent = now
+				out = append(out, pendingAlert{rule, newAlertNotification(rule, cur, alertStatusFiring, st.Since, now)})
+
+			case st.Fired && rule.Repeat > 0 && now.Sub(st.LastSent) >= time.Duration(rule.Repeat):
+				st.LastSent = now
+				out = append(out, pendingAlert{rule, newAlertNotification(rule, cur, alertStatusFiring, st.Since, now)})
+			}
+		}
+	}
+
+	// Zustände gelöschter/deaktivierter Regeln und verschwundener Geräte aufräumen
+	for key := range alertStates {
+		id, mac, _ := strings.Cut(key, "|")
+		if _, ok := after[mac]; !activeRules[id] || !ok {
+			delete(alertStates, key)
+		}
+	}
+
+	if err := saveAlertStatesLocked(); err != nil {
+		fmt.Println("Alert state save error:", err)
+	}
+
+	alertMutex.Unlock()
+
+	sendPendingAlerts(out)
+}
+
+// ------------------------------------------------------------
+// Versand
+// ------------------------------------------------------------
+
+// deliverAlert verschickt eine Benachrichtigung über alle Kanäle
+// der Regel und protokolliert das Ergebnis.
+func deliverAlert(rule AlertRule, n AlertNotification) []string {
+	var errs []string
+
+	if rule.Webhook != "" {
+		if err := sendAlertWebhook(rule.Webhook, n); err != nil {
+			errs = append(errs, "webhook: "+err.Error())
+		}
+	}
+
+	if len(rule.Email) > 0 {
+		if err := sendAlertEmail(rule.Email, n); err != nil {
+			errs = append(errs, "email: "+err.Error())
+		}
+	}
+
+	if rule.Command != "" {
+		if err := runAlertCommand(rule.Command, n); err != nil {
+			errs = append(errs, "command: "+err.Error())
+		}
+	}
+
+	for _, e := range errs {
+		fmt.Println("Alarm-Versand fehlgeschlagen:", n.Rule, e)
+	}
+
+	entry := alertLogEntry{AlertNotification: n, Errors: errs}
+	err := store.Update(func(tx StorageTx) error {
+		return tx.AppendEvent(alertLogStream, "", entry)
+	})
+	if err != nil {
+		fmt.Println("Alert log save error:", err)
+	}
+
+	return errs
+}
+
+// sendAlertWebhook schickt die Benachrichtigung als JSON per POST.
+func sendAlertWebhook(target string, n AlertNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: alertDeliveryTimeout}
+
+	resp, err := client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	return nil
+}
+
+// sendAlertEmail verschickt die Benachrichtigung als Text-Mail.
+//
+// Wie smtp.SendMail, aber mit Zeitlimit: STARTTLS, falls der Server
+// es anbietet, AUTH PLAIN nur, wenn ein Benutzer konfiguriert ist.
+func sendAlertEmail(to []string, n AlertNotification) error {
+	if cfg.SMTPAddr == "" {
+		return errors.New("smtpAddr is not configured")
+	}
+
+	host, _, err := net.SplitHostPort(cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", cfg.SMTPAddr, alertDeliveryTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(alertDeliveryTimeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if cfg.SMTPUser != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(cfg.SMTPFrom); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return fmt.Errorf("%s: %w", addr, err)
+		}
+	}
+
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	subject := "[Labor-Inventar] " + n.Rule
+	if n.Status == alertStatusResolved {
+		subject += " (behoben)"
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprint(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprint(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", n.Message)
+	fmt.Fprintf(&msg, "Regel:    %s (%s)\r\n", n.Rule, n.Kind)
+	fmt.Fprintf(&msg, "Status:   %s\r\n", n.Status)
+	fmt.Fprintf(&msg, "Seit:     %s\r\n", n.Since.Format("02.01.2006 15:04:05"))
+	fmt.Fprintf(&msg, "IP:       %s\r\n", n.Device.IP)
+	fmt.Fprintf(&msg, "MAC:      %s\r\n", n.Device.MAC)
+	fmt.Fprintf(&msg, "Hostname: %s\r\n", n.Device.Hostname)
+	fmt.Fprintf(&msg, "Büro:     %s\r\n", n.Device.Office)
+
+	if _, err := wc.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// runAlertCommand startet einen lokalen Befehl.
+//
+// Der Befehl wird ohne Shell an Leerzeichen zerlegt. Die Benachrichtigung
+// kommt als JSON auf stdin und zusätzlich als Umgebungsvariablen:
+//
+//	INVENTAR_ALERT_RULE, INVENTAR_ALERT_STATUS, INVENTAR_ALERT_MESSAGE,
+//	INVENTAR_ALERT_IP, INVENTAR_ALERT_MAC, INVENTAR_ALERT_OFFICE
+func runAlertCommand(command string, n AlertNotification) error {
+	if !cfg.AlertCommands {
+		return errors.New("alert commands are disabled (alertCommands)")
+	}
+
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return errors.New("empty command")
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertDeliveryTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"INVENTAR_ALERT_RULE="+n.Rule,
+		"INVENTAR_ALERT_STATUS="+n.Status,
+		"INVENTAR_ALERT_MESSAGE="+n.Message,
+		"INVENTAR_ALERT_IP="+n.Device.IP,
+		"INVENTAR_ALERT_MAC="+n.Device.MAC,
+		"INVENTAR_ALERT_OFFICE="+n.Device.Office,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------
+// Prüfung von Regeln
+// ------------------------------------------------------------
+
+// newAlertRuleID erzeugt eine zufällige Regel-ID.
+func newAlertRuleID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// normalizeAlertRule prüft eine Regel und setzt Standardwerte.
+//
+// Aufrufer muss officeCatalogMutex halten.
+func normalizeAlertRule(rule *AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Office = strings.TrimSpace(rule.Office)
+	rule.State = strings.ToUpper(strings.TrimSpace(rule.State))
+	rule.Webhook = strings.TrimSpace(rule.Webhook)
+	rule.Command = strings.TrimSpace(rule.Command)
+
+	if rule.Name == "" {
+		return errors.New("missing name")
+	}
+
+	switch rule.Kind {
+	case alertKindStateLeft:
+		if rule.State == "" {
+			rule.State = "RUN"
+		}
+		if rule.Port < 0 || rule.Port > 65535 {
+			return fmt.Errorf("port %d out of range", rule.Port)
+		}
+
+	case alertKindOffline, alertKindNewMAC:
+		rule.State = ""
+		rule.Port = 0
+
+	default:
+		return fmt.Errorf("invalid kind %q (%s, %s, %s)", rule.Kind, alertKindStateLeft, alertKindOffline, alertKindNewMAC)
+	}
+
+	if rule.For < 0 || rule.Repeat < 0 {
+		return errors.New("durations must not be negative")
+	}
+
+	if !isValidOfficeLocked(rule.Office) {
+		return errors.New("invalid office")
+	}
+
+	// Eine neue MAC hat noch keine Büro-Zuordnung, der Filter
+	// würde die Regel nie auslösen lassen
+	if rule.Kind == alertKindNewMAC && rule.Office != "" {
+		return errors.New("office filter is not supported for new_mac")
+	}
+
+	var emails []string
+	for _, addr := range rule.Email {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "@") {
+			return fmt.Errorf("invalid email %q", addr)
+		}
+		emails = append(emails, addr)
+	}
+	rule.Email = emails
+
+	if rule.Webhook == "" && len(rule.Email) == 0 && rule.Command == "" {
+		return errors.New("at least one of webhook, email or command is required")
+	}
+
+	if rule.Webhook != "" {
+		u, err := url.Parse(rule.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("webhook must be an http(s) URL")
+		}
+	}
+
+	if len(rule.Email) > 0 && cfg.SMTPAddr == "" {
+		return errors.New("email requires smtpAddr in the server config")
+	}
+
+	if rule.Command != "" && !cfg.AlertCommands {
+		return errors.New("commands are disabled (alertCommands in the server config)")
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------
+// HTTP-API
+// ------------------------------------------------------------
+
+type alertRulesResponse struct {
+	Rules           []AlertRule `json:"rules"`
+	SMTPConfigured  bool        `json:"smtpConfigured"`
+	CommandsEnabled bool        `json:"commandsEnabled"`
+}
+
+// listAlertRules liefert eine Kopie aller Regeln.
+func listAlertRules() alertRulesResponse {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	return alertRulesResponse{
+		Rules:           append([]AlertRule{}, alertRules...),
+		SMTPConfigured:  cfg.SMTPAddr != "",
+		CommandsEnabled: cfg.AlertCommands,
+	}
+}
+
+// handleAlertRules verwaltet die Alarm-Regeln.
+//
+//	GET    /api/alerts/rules           Liste
+//	POST   /api/alerts/rules           Regel anlegen bzw. ändern (mit "id")
+//	DELETE /api/alerts/rules?id=...    Regel löschen
+func handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, listAlertRules())
+
+	case http.MethodPost:
+		var rule AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		// Prüfen und Speichern unter officeCatalogMutex,
+		// damit deleteOffice das Büro nicht dazwischen entfernt.
+		officeCatalogMutex.Lock()
+		defer officeCatalogMutex.Unlock()
+
+		if err := normalizeAlertRule(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		alertMutex.Lock()
+		defer alertMutex.Unlock()
+
+		if rule.ID == "" {
+			rule.ID = newAlertRuleID()
+			alertRules = append(alertRules, rule)
+		} else {
+			idx := alertRuleIndexLocked(rule.ID)
+			if idx < 0 {
+				http.Error(w, "rule not found", http.StatusNotFound)
+				return
+			}
+			alertRules[idx] = rule
+			clearAlertStatesLocked(rule.ID)
+		}
+
+		err := saveAlertRulesLocked()
+		appendAudit(r, auditAlertRuleSave, rule.ID, rule.Name, err)
+		if err != nil {
+			http.Error(w, "failed to save rules", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, rule)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+
+		alertMutex.Lock()
+		defer alertMutex.Unlock()
+
+		idx := alertRuleIndexLocked(id)
+		if idx < 0 {
+			http.Error(w, "rule not found", http.StatusNotFound)
+			return
+		}
+
+		name := alertRules[idx].Name
+		alertRules = append(alertRules[:idx], alertRules[idx+1:]...)
+		clearAlertStatesLocked(id)
+
+		err := saveAlertRulesLocked()
+		appendAudit(r, auditAlertRuleDel, id, name, err)
+		if err != nil {
+			http.Error(w, "failed to save rules", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// alertRuleIndexLocked sucht eine Regel (-1 = nicht gefunden).
+func alertRuleIndexLocked(id string) int {
+	for i, rule := range alertRules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// clearAlertStatesLocked verwirft den Entprell-Zustand einer Regel,
+// z. B. nach einer Änderung der Bedingung.
+func clearAlertStatesLocked(id string) {
+	for key := range alertStates {
+		if strings.HasPrefix(key, id+"|") {
+			delete(alertStates, key)
+		}
+	}
+}
+
+// handleAlertRuleTest verschickt POST /api/alerts/rules/{id}/test
+// sofort eine Test-Benachrichtigung über alle Kanäle der Regel.
+//
+// Antwort: {"errors":[...]} – leer, wenn alle Kanäle funktioniert haben.
+func handleAlertRuleTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	alertMutex.Lock()
+	idx := alertRuleIndexLocked(id)
+	var rule AlertRule
+	if idx >= 0 {
+		rule = alertRules[idx]
+	}
+	alertMutex.Unlock()
+
+	if idx < 0 {
+		http.Error(w, "rule not found", http.StatusNotFound)
+		return
+	}
+
+	// Beispielgerät, damit Platzhalter im Empfängersystem gefüllt sind
+	now := time.Now()
+	dev := IPC{
+		IP:             "192.0.2.1",
+		MACAddress:     "00:01:05:00:00:00",
+		Hostname:       "test-device",
+		Office:         rule.Office,
+		RuntimeStatus:  "STOP",
+		LastSeenOnline: now,
+	}
+	if rule.Port != 0 {
+		dev.Runtimes = []PlcRuntime{{Port: uint16(rule.Port), State: "STOP"}}
+	}
+
+	errs := deliverAlert(rule, newAlertNotification(rule, dev, alertStatusTest, now, now))
+
+	writeJSON(w, http.StatusOK, struct {
+		Errors []string `json:"errors"`
+	}{Errors: append([]string{}, errs...)})
+}
+
+// readAlertLog liest die letzten Benachrichtigungen, neueste zuerst.
+func readAlertLog(limit int) ([]alertLogEntry, error) {
+	var out []alertLogEntry
+
+	err := store.ReadEvents(alertLogStream, "", func(raw []byte) error {
+		var e alertLogEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil
+		}
+		out = append(out, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.After(out[j].Time)
+	})
+
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out, nil
+}
+
+// handleAlertLog liefert GET /api/alerts/log?limit=100.
+func handleAlertLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := readAlertLog(limit)
+	if err != nil {
+		http.Error(w, "failed to read alert log", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, append([]alertLogEntry{}, entries...))
+}
+
+// ------------------------------------------------------------
+// Regel-Editor
+// ------------------------------------------------------------
+
+// alertKindLabels sind die Anzeigenamen der Regeltypen.
+var alertKindLabels = map[string]string{
+	alertKindStateLeft: "verlässt State",
+	alertKindOffline:   "offline länger als",
+	alertKindNewMAC:    "neue MAC",
+}
+
+// handleAlertsPage zeigt /alerts: Regeln, Formular und letzte Meldungen.
+// Gespeichert wird per fetch() gegen /api/alerts/rules.
+func handleAlertsPage(w http.ResponseWriter, r *http.Request) {
+	esc := template.HTMLEscapeString
+	list := listAlertRules()
+
+	logEntries, err := readAlertLog(50)
+	if err != nil {
+		fmt.Println("Alert log read error:", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 1100px; }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            h2 { font-size: 1.05em; font-weight: 600; margin: 24px 0 8px; }
+            table { border-collapse: collapse; width: 100%; margin-bottom: 20px; }
+            th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #eee; font-size: 0.9em; vertical-align: top; }
+            th { background: #fafafa; }
+            .warn { background: #fff3cd; border: 1px solid #ffe08a; padding: 10px; border-radius: 4px; margin-bottom: 16px; }
+            .hint { color: #888; font-size: 0.85em; }
+            .off { color: #aaa; }
+            .err { color: #ce1126; }
+            form label { display: inline-block; margin: 0 12px 8px 0; font-size: 0.9em; }
+            input, select { padding: 4px 6px; border: 1px solid #ddd; border-radius: 4px; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Alarme</h1>
+            </div>
+`)
+
+	if !list.SMTPConfigured {
+		fmt.Fprint(w, `<div class="warn">Kein Mailserver konfiguriert (smtpAddr) – E-Mail-Benachrichtigungen sind nicht möglich.</div>`)
+	}
+
+	fmt.Fprint(w, `
+            <table>
+                <tr><th>Name</th><th>Bedingung</th><th>Büro</th><th>Entprellung</th><th>Kanäle</th><th></th></tr>
+`)
+
+	if len(list.Rules) == 0 {
+		fmt.Fprint(w, `<tr><td colspan="6" class="hint">Keine Regeln.</td></tr>`)
+	}
+
+	for _, rule := range list.Rules {
+		cls := ""
+		if !rule.Enabled {
+			cls = ` class="off"`
+		}
+
+		cond := alertKindLabels[rule.Kind]
+		switch rule.Kind {
+		case alertKindStateLeft:
+			port := "TwinCAT"
+			if rule.Port != 0 {
+				port = "Port " + strconv.Itoa(rule.Port)
+			}
+			cond = port + " " + cond + " " + rule.State
+		case alertKindOffline:
+			cond += " " + rule.For.String()
+		}
+
+		debounce := []string{}
+		if rule.For > 0 && rule.Kind != alertKindOffline {
+			debounce = append(debounce, "nach "+rule.For.String())
+		}
+		if rule.Repeat > 0 {
+			debounce = append(debounce, "alle "+rule.Repeat.String())
+		}
+		if rule.NotifyResolved {
+			debounce = append(debounce, "mit Entwarnung")
+		}
+
+		channels := []string{}
+		if rule.Webhook != "" {
+			channels = append(channels, "Webhook")
+		}
+		if len(rule.Email) > 0 {
+			channels = append(channels, "E-Mail: "+strings.Join(rule.Email, ", "))
+		}
+		if rule.Command != "" {
+			channels = append(channels, "Befehl")
+		}
+
+		data, _ := json.Marshal(rule)
+
+		fmt.Fprintf(w, `<tr%s><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td>
+            <td><button data-edit="%s">Bearbeiten</button> <button data-test="%s">Test</button> <button data-del="%s">Löschen</button></td></tr>`,
+			cls, esc(rule.Name), esc(cond), esc(rule.Office), esc(strings.Join(debounce, ", ")),
+			esc(strings.Join(channels, "; ")), esc(string(data)), esc(rule.ID), esc(rule.ID))
+	}
+
+	fmt.Fprint(w, `
+            </table>
+
+            <h2 id="formTitle">Neue Regel</h2>
+            <form id="ruleForm">
+                <input type="hidden" name="id">
+                <label>Name <input name="name" size="28" required></label>
+                <label><input type="checkbox" name="enabled" checked> aktiv</label><br>
+                <label>Typ
+                    <select name="kind">
+                        <option value="state_left">Runtime verlässt State</option>
+                        <option value="offline">Gerät offline</option>
+                        <option value="new_mac">Neue MAC-Adresse</option>
+                    </select>
+                </label>
+                <label>State <input name="state" size="8" placeholder="RUN"></label>
+                <label>Port <input name="port" size="6" placeholder="0 = System"></label>
+                <label>Büro <input name="office" size="10" placeholder="alle"></label><br>
+                <label>Mindestdauer <input name="for" size="6" placeholder="30m"></label>
+                <label>Wiederholen <input name="repeat" size="6" placeholder="nie"></label>
+                <label><input type="checkbox" name="notifyResolved"> Entwarnung melden</label><br>
+                <label>Webhook <input name="webhook" size="40" placeholder="https://..."></label>
+                <label>E-Mail <input name="email" size="30" placeholder="a@example.com, b@example.com"></label>
+                <label>Befehl <input name="command" size="30"`)
+
+	if !list.CommandsEnabled {
+		fmt.Fprint(w, ` disabled placeholder="deaktiviert (alertCommands)"`)
+	}
+
+	fmt.Fprint(w, `></label><br>
+                <button type="submit">Speichern</button>
+                <button type="button" id="resetForm">Neu</button>
+            </form>
+            <p class="hint">Dauern wie 90s, 30m, 2h. Bei "offline" zählt die Mindestdauer ab dem letzten Online-Zeitpunkt.
+                Pro Gerät wird nur einmal gemeldet, bis die Bedingung wieder weg ist (außer mit "Wiederholen").</p>
+
+            <h2>Letzte Meldungen</h2>
+            <table>
+                <tr><th>Zeit</th><th>Regel</th><th>Status</th><th>Meldung</th><th>Fehler</th></tr>
+`)
+
+	if len(logEntries) == 0 {
+		fmt.Fprint(w, `<tr><td colspan="5" class="hint">Noch keine Meldungen.</td></tr>`)
+	}
+
+	for _, e := range logEntries {
+		fmt.Fprintf(w, `<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td class="err">%s</td></tr>`,
+			e.Time.Format("02.01.2006 15:04:05"), esc(e.Rule), esc(e.Status), esc(e.Message), esc(strings.Join(e.Errors, "; ")))
+	}
+
+	fmt.Fprint(w, `
+            </table>
+        </div>
+
+        <script>
+            async function send(method, url, body) {
+                const res = await fetch(url, {
+                    method: method,
+                    headers: { "Content-Type": "application/json" },
+                    body: body ? JSON.stringify(body) : undefined
+                });
+                if (!res.ok) {
+                    alert("Fehler: " + (await res.text()));
+                    return null;
+                }
+                return res;
+            }
+
+            const form = document.getElementById("ruleForm");
+
+            function fillForm(rule) {
+                form.id.value = rule.id || "";
+                form.name.value = rule.name || "";
+                form.enabled.checked = rule.enabled !== false;
+                form.kind.value = rule.kind || "state_left";
+                form.state.value = rule.state || "";
+                form.port.value = rule.port || "";
+                form.office.value = rule.office || "";
+                form.for.value = rule.for || "";
+                form.repeat.value = rule.repeat || "";
+                form.notifyResolved.checked = !!rule.notifyResolved;
+                form.webhook.value = rule.webhook || "";
+                form.email.value = (rule.email || []).join(", ");
+                form.command.value = rule.command || "";
+                document.getElementById("formTitle").textContent = rule.id ? "Regel bearbeiten" : "Neue Regel";
+            }
+
+            form.addEventListener("submit", async (e) => {
+                e.preventDefault();
+                const rule = {
+                    id: form.id.value,
+                    name: form.name.value,
+                    enabled: form.enabled.checked,
+                    kind: form.kind.value,
+                    state: form.state.value,
+                    port: parseInt(form.port.value, 10) || 0,
+                    office: form.office.value,
+                    notifyResolved: form.notifyResolved.checked,
+                    webhook: form.webhook.value,
+                    email: form.email.value.split(",").map(s => s.trim()).filter(s => s),
+                    command: form.command.value
+                };
+                if (form.for.value.trim()) rule.for = form.for.value.trim();
+                if (form.repeat.value.trim()) rule.repeat = form.repeat.value.trim();
+
+                if (await send("POST", "/api/alerts/rules", rule)) location.reload();
+            });
+
+            document.getElementById("resetForm").addEventListener("click", () => fillForm({}));
+
+            document.querySelectorAll("button[data-edit]").forEach(btn => {
+                btn.addEventListener("click", () => {
+                    fillForm(JSON.parse(btn.dataset.edit));
+                    form.scrollIntoView();
+                });
+            });
+
+            document.querySelectorAll("button[data-test]").forEach(btn => {
+                btn.addEventListener("click", async () => {
+                    const res = await send("POST", "/api/alerts/rules/" + encodeURIComponent(btn.dataset.test) + "/test");
+                    if (!res) return;
+                    const data = await res.json();
+                    alert(data.errors.length ? "Fehler:\n" + data.errors.join("\n") : "Test-Meldung verschickt.");
+                    location.reload();
+                });
+            });
+
+            document.querySelectorAll("button[data-del]").forEach(btn => {
+                btn.addEventListener("click", async () => {
+                    if (!confirm("Regel löschen?")) return;
+                    if (await send("DELETE", "/api/alerts/rules?id=" + encodeURIComponent(btn.dataset.del))) location.reload();
+                });
+            });
+        </script>
+    </body>
+    </html>
+`)
+}
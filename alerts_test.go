@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNormalizeAlertRuleNewMACOffice(t *testing.T) {
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}}
+	defer officeCatalogMutex.Unlock()
+
+	rule := AlertRule{Name: "neu", Kind: alertKindNewMAC, Office: "T4015", Webhook: "http://example/hook"}
+	if err := normalizeAlertRule(&rule); err == nil {
+		t.Error("new_mac with office accepted")
+	}
+
+	rule = AlertRule{Name: "neu", Kind: alertKindNewMAC, Webhook: "http://example/hook"}
+	if err := normalizeAlertRule(&rule); err != nil {
+		t.Errorf("new_mac without office: %v", err)
+	}
+
+	rule = AlertRule{Name: "aus", Kind: alertKindOffline, Office: "T4015", Webhook: "http://example/hook"}
+	if err := normalizeAlertRule(&rule); err != nil {
+		t.Errorf("offline with office: %v", err)
+	}
+}
+
+func TestEvaluateNewMACAlerts(t *testing.T) {
+	oldStore, oldCfg := store, cfg
+	t.Cleanup(func() {
+		store, cfg = oldStore, oldCfg
+	})
+	// vor dem Zurücksetzen auf laufende Versände warten (Cleanups laufen rückwärts)
+	t.Cleanup(alertDeliveries.Wait)
+
+	cfg = defaultConfig()
+	store = newJSONStorage(t.TempDir())
+
+	got := make(chan AlertNotification, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n AlertNotification
+		json.NewDecoder(r.Body).Decode(&n)
+		got <- n
+	}))
+	defer srv.Close()
+
+	alertMutex.Lock()
+	alertRules = []AlertRule{{ID: "r1", Name: "neu", Enabled: true, Kind: alertKindNewMAC, Webhook: srv.URL}}
+	alertMutex.Unlock()
+	defer func() {
+		alertMutex.Lock()
+		alertRules = nil
+		alertMutex.Unlock()
+	}()
+
+	before := map[string]IPC{"00:01:05:00:00:01": {IP: "10.99.0.1", MACAddress: "00:01:05:00:00:01"}}
+
+	// bekannte MAC: keine Meldung
+	evaluateNewMACAlerts(before, IPC{IP: "10.99.0.1", MACAddress: "00:01:05:00:00:01"}, time.Now())
+
+	// neue MAC: genau eine Meldung
+	evaluateNewMACAlerts(before, IPC{IP: "10.99.0.2", MACAddress: "00:01:05:00:00:02"}, time.Now())
+
+	select {
+	case n := <-got:
+		if n.Device.MAC != "00:01:05:00:00:02" {
+			t.Errorf("alert for %q, want 00:01:05:00:00:02", n.Device.MAC)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert for new mac")
+	}
+
+	select {
+	case n := <-got:
+		t.Errorf("unexpected alert for %q", n.Device.MAC)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// JSON-Darstellung eines Geräts
+// ------------------------------------------------------------
+//
+// apiDevice ist die JSON-Form eines IPC für die REST-API.
+//
+// Bewusst getrennt von IPC, damit die API stabile, kleingeschriebene
+// Feldnamen hat und interne Felder sich ändern können,
+// ohne Skripte der Nutzer zu brechen.
+type apiDevice struct {
+	IP             string       `json:"ip"`
+	Online         bool         `json:"online"`
+	MAC            string       `json:"mac,omitempty"`
+	Hostname       string       `json:"hostname,omitempty"`
+	Office         string       `json:"office,omitempty"`
+	Comment        string       `json:"comment,omitempty"`
+	OSVersion      string       `json:"osVersion,omitempty"`
+	AmsNetID       string       `json:"amsNetId,omitempty"`
+	TwinCATVersion string       `json:"twincatVersion,omitempty"`
+	RuntimeStatus  string       `json:"runtimeStatus,omitempty"`
+	DeviceName     string       `json:"deviceName,omitempty"`
+	DeviceVersion  string       `json:"deviceVersion,omitempty"`
+	Runtimes       []PlcRuntime `json:"runtimes,omitempty"`
+	RuntimePort    uint16       `json:"runtimePort,omitempty"`
+	RouteKnownGood bool         `json:"routeKnownGood"`
+	LastRouteOK    *time.Time   `json:"lastRouteOk,omitempty"`
+	RouteFailures  int          `json:"routeFailures,omitempty"`
+	NextRouteTry   *time.Time   `json:"nextRouteAttempt,omitempty"`
+	RouteRemoved   bool         `json:"routeRemoved,omitempty"`
+	LastUpdate     *time.Time   `json:"lastUpdate,omitempty"`
+	LastScan       *time.Time   `json:"lastScan,omitempty"`
+	LastSeenOnline *time.Time   `json:"lastSeenOnline,omitempty"`
+}
+
+// optTime liefert nil für leere Zeitstempel,
+// damit diese im JSON weggelassen werden statt "0001-01-01..." zu liefern.
+func optTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// toAPIDevice wandelt ein Gerät in seine JSON-Darstellung um.
+func toAPIDevice(d *IPC) apiDevice {
+	return apiDevice{
+		IP:             d.IP,
+		Online:         d.IsReachable,
+		MAC:            d.MACAddress,
+		Hostname:       d.Hostname,
+		Office:         d.Office,
+		Comment:        d.Comment,
+		OSVersion:      d.OSVersion,
+		AmsNetID:       d.AmsNetID,
+		TwinCATVersion: d.TwinCATVersion,
+		RuntimeStatus:  d.RuntimeStatus,
+		DeviceName:     d.DeviceName,
+		DeviceVersion:  d.DeviceVersion,
+		Runtimes:       d.Runtimes,
+		RuntimePort:    d.RuntimePort,
+		RouteKnownGood: d.RouteKnownGood,
+		LastRouteOK:    optTime(d.LastRouteOK),
+		RouteFailures:  d.RouteFailures,
+		NextRouteTry:   optTime(d.NextRouteAttempt),
+		RouteRemoved:   d.RouteRemoved,
+		LastUpdate:     optTime(d.LastUpdate),
+		LastScan:       optTime(d.LastScan),
+		LastSeenOnline: optTime(d.LastSeenOnline),
+	}
+}
+
+// writeJSON schreibt v als JSON mit passendem Content-Type.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ------------------------------------------------------------
+// Filter
+// ------------------------------------------------------------
+
+// deviceFilter beschreibt die Filter aus den Query-Parametern.
+//
+// Unterstützt:
+//
+//	?online=true|false
+//	?office=T4020        (leer = alle, "-" = ohne Büro)
+//	?twincat=3.1         (Präfix der TwinCAT-Version)
+//	?runtime=RUN         (exakter Status des Geräts oder einer seiner PLC-Runtimes,
+//	                      ohne Groß-/Kleinschreibung)
+//	?q=text              (Volltextsuche wie im Dashboard-Suchfeld)
+type deviceFilter struct {
+	Online  *bool
+	Office  string
+	TwinCAT string
+	Runtime string
+	Query   string
+}
+
+// parseDeviceFilter liest die Filter aus der Anfrage.
+func parseDeviceFilter(r *http.Request) (deviceFilter, error) {
+	q := r.URL.Query()
+
+	f := deviceFilter{
+		Office:  strings.TrimSpace(q.Get("office")),
+		TwinCAT: strings.TrimSpace(q.Get("twincat")),
+		Runtime: strings.TrimSpace(q.Get("runtime")),
+		Query:   strings.ToUpper(strings.TrimSpace(q.Get("q"))),
+	}
+
+	if v := strings.TrimSpace(q.Get("online")); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, err
+		}
+		f.Online = &b
+	}
+
+	return f, nil
+}
+
+// match prüft, ob ein Gerät alle gesetzten Filter erfüllt.
+func (f deviceFilter) match(d *IPC) bool {
+	if f.Online != nil && d.IsReachable != *f.Online {
+		return false
+	}
+
+	if f.Office == "-" && d.Office != "" {
+		return false
+	}
+	if f.Office != "" && f.Office != "-" && !strings.EqualFold(d.Office, f.Office) {
+		return false
+	}
+
+	if f.TwinCAT != "" && !strings.HasPrefix(d.TwinCATVersion, f.TwinCAT) {
+		return false
+	}
+
+	if f.Runtime != "" && !matchRuntimeState(d, f.Runtime) {
+		return false
+	}
+
+	if f.Query != "" {
+		fields := []string{
+			d.IP, d.Hostname, d.Office, d.Comment, d.MACAddress,
+			d.OSVersion, d.AmsNetID, d.TwinCATVersion, d.RuntimeStatus,
+		}
+		for _, p := range d.Runtimes {
+			fields = append(fields, p.ProjectName, p.AppName)
+		}
+
+		text := strings.ToUpper(strings.Join(fields, " "))
+
+		if !strings.Contains(text, f.Query) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchRuntimeState prüft den System-State und alle PLC-Runtimes.
+//
+// ?runtime=STOP findet damit auch ein Gerät, dessen System in RUN ist,
+// auf dem aber eine von mehreren PLC-Instanzen gestoppt ist.
+func matchRuntimeState(d *IPC, state string) bool {
+	if strings.EqualFold(d.RuntimeStatus, state) {
+		return true
+	}
+
+	for _, rt := range d.Runtimes {
+		if strings.EqualFold(rt.State, state) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ------------------------------------------------------------
+// Gerät per MAC oder IP finden
+// ------------------------------------------------------------
+
+// findDevice sucht ein Gerät anhand einer MAC- oder IP-Adresse.
+//
+// Zurückgegeben wird eine Kopie, damit der Aufrufer
+// ohne Lock weiterarbeiten kann.
+func findDevice(id string) (IPC, bool) {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	dev := findDeviceLocked(id)
+	if dev == nil {
+		return IPC{}, false
+	}
+
+	return *dev, true
+}
+
+// findDeviceLocked ist die Variante von findDevice ohne eigenes Locking.
+//
+// WICHTIG:
+// Nur unter inventoryMutex.Lock() aufrufen.
+func findDeviceLocked(id string) *IPC {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil
+	}
+
+	if ip := net.ParseIP(id); ip != nil {
+		return inventory[ip.String()]
+	}
+
+	mac := normalizeMAC(id)
+
+	for _, dev := range inventory {
+		if dev != nil && normalizeMAC(dev.MACAddress) == mac {
+			return dev
+		}
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------
+// HTTP-Handler
+// ------------------------------------------------------------
+
+// deviceListResponse ist die Antwort von GET /api/devices.
+type deviceListResponse struct {
+	Total    int            `json:"total"`    // Anzahl Geräte nach Filterung
+	Page     int            `json:"page"`     // aktuelle Seite (1-basiert)
+	PageSize int            `json:"pageSize"` // Geräte pro Seite
+	Stats    DashboardStats `json:"stats"`    // Kennzahlen über das gesamte Inventory
+	Devices  []apiDevice    `json:"devices"`
+}
+
+// handleDevices liefert die gefilterte, seitenweise Geräteliste.
+//
+// Sortierung und Statistik entsprechen dem Dashboard,
+// da beides aus buildDashboardModel() stammt.
+//
+// Pagination:
+//
+//	?page=1&pageSize=100   (pageSize max. 1000)
+func handleDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseDeviceFilter(r)
+	if err != nil {
+		http.Error(w, "invalid online filter", http.StatusBadRequest)
+		return
+	}
+
+	page := 1
+	pageSize := 100
+
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+
+	if v := r.URL.Query().Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "invalid pageSize (1..1000)", http.StatusBadRequest)
+			return
+		}
+		pageSize = n
+	}
+
+	model := buildDashboardModel()
+
+	var matched []apiDevice
+	for _, dev := range model.Devices {
+		if filter.match(dev) {
+			matched = append(matched, toAPIDevice(dev))
+		}
+	}
+
+	start, end := pageBounds(page, pageSize, len(matched))
+
+	resp := deviceListResponse{
+		Total:    len(matched),
+		Page:     page,
+		PageSize: pageSize,
+		Stats:    model.Stats,
+		Devices:  matched[start:end],
+	}
+
+	if resp.Devices == nil {
+		resp.Devices = []apiDevice{}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// pageBounds liefert den Ausschnitt [start:end] einer Seite bei n Einträgen.
+//
+// page wird vor dem Multiplizieren begrenzt, damit sehr große Werte
+// (z. B. page=9223372036854775807) nicht überlaufen und negativ werden.
+func pageBounds(page, pageSize, n int) (start, end int) {
+	if page-1 > n/pageSize {
+		return n, n
+	}
+
+	start = min((page-1)*pageSize, n)
+	end = min(start+pageSize, n)
+	return start, end
+}
+
+// handleDevice liefert ein einzelnes Gerät:
+//
+//	GET /api/devices/{id}
+//
+// id ist eine MAC-Adresse (beliebige Schreibweise) oder eine IPv4-Adresse.
+func handleDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dev, ok := findDevice(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "device not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toAPIDevice(&dev))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		page, pageSize, n int
+		start, end        int
+	}{
+		{1, 100, 0, 0, 0},
+		{1, 100, 250, 0, 100},
+		{3, 100, 250, 200, 250},
+		{4, 100, 250, 250, 250},
+		{1000, 1000, 5, 5, 5},
+		{math.MaxInt, 100, 250, 250, 250},
+		{math.MaxInt / 100, 1000, 250, 250, 250},
+	}
+
+	for _, tt := range tests {
+		start, end := pageBounds(tt.page, tt.pageSize, tt.n)
+		if start != tt.start || end != tt.end {
+			t.Errorf("pageBounds(%d, %d, %d) = %d, %d, want %d, %d",
+				tt.page, tt.pageSize, tt.n, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestHandleDevicesOutOfRangePage(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	inventoryMutex.Lock()
+	for _, ip := range []string{"10.99.0.1", "10.99.0.2", "10.99.0.3"} {
+		inventory[ip] = &IPC{IP: ip}
+	}
+	inventoryMutex.Unlock()
+
+	for _, page := range []int{2, math.MaxInt} {
+		req := httptest.NewRequest(http.MethodGet, "/api/devices?pageSize=3&page="+strconv.Itoa(page), nil)
+		rec := httptest.NewRecorder()
+
+		handleDevices(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page=%d: status %d: %s", page, rec.Code, rec.Body.String())
+		}
+
+		var resp deviceListResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Total != 3 || len(resp.Devices) != 0 {
+			t.Errorf("page=%d: total=%d devices=%d, want 3 / 0", page, resp.Total, len(resp.Devices))
+		}
+	}
+}
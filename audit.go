@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Audit-Log
+// ------------------------------------------------------------
+//
+// AuditEntry protokolliert eine Aktion, die ein Benutzer über die
+// Weboberfläche oder die API ausgelöst hat (z. B. TwinCAT-State ändern).
+//
+// Die Einträge landen im Event-Stream "audit" des Storage
+// und werden nie verändert, nur angehängt.
+//
+// Bei Änderungen an Werten (Büro, Kommentar, Rolle, ...) stehen
+// alter und neuer Wert in Old/New.
+//
+// Beispiele:
+//
+//	{"time":"...","remote":"172.17.76.5","user":"mueller","action":"device.state",
+//	 "target":"00:01:05:12:34:56","details":"config port 10000","result":"ok"}
+//
+//	{"time":"...","remote":"172.17.76.5","user":"mueller","action":"device.office",
+//	 "target":"00:01:05:12:34:56","old":"T4015","new":"T4020","result":"ok"}
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Remote  string    `json:"remote"`            // IP des aufrufenden Clients
+	User    string    `json:"user,omitempty"`    // angemeldeter Benutzer (siehe auth.go)
+	Action  string    `json:"action"`            // siehe audit*-Konstanten
+	Target  string    `json:"target"`            // betroffenes Objekt (MAC, IP, ...)
+	Details string    `json:"details,omitempty"` // Parameter der Aktion
+	Old     string    `json:"old,omitempty"`     // bisheriger Wert
+	New     string    `json:"new,omitempty"`     // neuer Wert
+	Result  string    `json:"result"`            // "ok" oder Fehlertext
+}
+
+const auditStream = "audit"
+
+// Aktionen im Audit-Log
+const (
+	auditDeviceState      = "device.state"        // TwinCAT-/PLC-State geändert
+	auditRouteDelete      = "device.route.delete" // ADS-Route auf dem Ziel gelöscht
+	auditRouteAllow       = "device.route.allow"  // gelöschte ADS-Route wieder zugelassen
+	auditDeviceOffice     = "device.office"       // Büro-Zuordnung geändert
+	auditDeviceComment    = "device.comment"      // Kommentar geändert
+	auditDeviceImport     = "device.import"       // Sammel-Import von Büros/Kommentaren
+	auditDeviceRescan     = "device.rescan"       // Einzel-Rescan ausgelöst
+	auditScanTrigger      = "scan.trigger"        // Vollscan manuell ausgelöst
+	auditOfficeSave       = "office.save"         // Büro im Katalog angelegt/geändert
+	auditOfficeRename     = "office.rename"       // Büro umbenannt (inkl. Zuordnungen)
+	auditOfficeDelete     = "office.delete"       // Büro aus dem Katalog gelöscht
+	auditFloorPlanSave    = "floorplan.save"      // Lageplan hochgeladen/ersetzt
+	auditFloorPlanRegions = "floorplan.regions"   // Büro-Bereiche eines Lageplans gespeichert
+	auditFloorPlanDelete  = "floorplan.delete"    // Lageplan gelöscht
+	auditCredentialSet    = "credential.save"     // Routen-Zugangsdaten gespeichert
+	auditCredentialDel    = "credential.delete"   // Routen-Zugangsdaten gelöscht
+	auditAlertRuleSave    = "alert.rule.save"     // Alarm-Regel angelegt/geändert
+	auditAlertRuleDel     = "alert.rule.delete"   // Alarm-Regel gelöscht
+	auditUserSave         = "user.save"           // Benutzer angelegt/geändert
+	auditUserDelete       = "user.delete"         // Benutzer gelöscht
+	auditLogin            = "user.login"          // Anmeldung über /login
+)
+
+// deviceAuditTarget liefert die Kennung eines Geräts fürs Audit-Log
+// (MAC-Adresse, sonst IP).
+func deviceAuditTarget(dev IPC) string {
+	if dev.MACAddress != "" {
+		return dev.MACAddress
+	}
+	return dev.IP
+}
+
+// remoteHost liefert die Client-IP ohne Port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// appendAudit schreibt einen Audit-Eintrag.
+//
+// actionErr ist das Ergebnis der protokollierten Aktion (nil = ok).
+// Fehler beim Schreiben des Logs selbst werden nur ausgegeben,
+// damit die eigentliche Aktion nicht nachträglich als fehlgeschlagen gilt.
+func appendAudit(r *http.Request, action, target, details string, actionErr error) {
+	writeAudit(r, AuditEntry{Action: action, Target: target, Details: details}, actionErr)
+}
+
+// appendAuditChange schreibt einen Audit-Eintrag für die Änderung
+// eines Werts von oldV auf newV.
+func appendAuditChange(r *http.Request, action, target, oldV, newV string, actionErr error) {
+	writeAudit(r, AuditEntry{Action: action, Target: target, Old: oldV, New: newV}, actionErr)
+}
+
+// writeAudit ergänzt Zeit, Client und Benutzer und hängt den Eintrag an.
+func writeAudit(r *http.Request, entry AuditEntry, actionErr error) {
+	entry.Time = time.Now()
+	entry.Remote = remoteHost(r)
+	entry.Result = "ok"
+
+	// Beim Login steht der Benutzer noch nicht im Kontext
+	if entry.User == "" {
+		entry.User = requestUser(r).Name
+	}
+
+	if actionErr != nil {
+		entry.Result = actionErr.Error()
+	}
+
+	who := entry.Remote
+	if entry.User != "" {
+		who = entry.User + " (" + entry.Remote + ")"
+	}
+
+	what := entry.Details
+	if entry.Old != "" || entry.New != "" {
+		what = fmt.Sprintf("%q -> %q", entry.Old, entry.New)
+	}
+
+	fmt.Printf("Audit: %s %s %s (%s) von %s\n", entry.Action, entry.Target, what, entry.Result, who)
+
+	err := store.Update(func(tx StorageTx) error {
+		return tx.AppendEvent(auditStream, "", entry)
+	})
+	if err != nil {
+		fmt.Println("Fehler beim Speichern des Audit-Logs:", err)
+	}
+}
+
+// ------------------------------------------------------------
+// Abfrage
+// ------------------------------------------------------------
+
+// auditFilter schränkt die Abfrage von /api/audit ein.
+// Leere Felder filtern nicht.
+type auditFilter struct {
+	Action string    // Präfix, z. B. "device." für alle Geräte-Aktionen
+	Target string    // Teilstring, ohne Groß-/Kleinschreibung
+	User   string    // exakter Benutzername, ohne Groß-/Kleinschreibung
+	Since  time.Time // ab (inklusive)
+	Until  time.Time // bis (exklusive)
+	Limit  int       // höchstens so viele Einträge, <= 0 = alle
+}
+
+// parseAuditFilter liest den Filter aus der Query.
+//
+//	?action=device.&target=00:01:05&user=mueller&since=2026-10-01&until=2026-10-16T12:00:00Z&limit=200
+//
+// since/until akzeptieren ein Datum (YYYY-MM-DD, lokale Zeit) oder RFC 3339.
+func parseAuditFilter(r *http.Request) (auditFilter, error) {
+	q := r.URL.Query()
+
+	f := auditFilter{
+		Action: strings.TrimSpace(q.Get("action")),
+		Target: strings.TrimSpace(q.Get("target")),
+		User:   strings.TrimSpace(q.Get("user")),
+		Limit:  200,
+	}
+
+	parseTime := func(name string) (time.Time, error) {
+		v := strings.TrimSpace(q.Get(name))
+		if v == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s (YYYY-MM-DD or RFC 3339)", name)
+		}
+		return t, nil
+	}
+
+	var err error
+	if f.Since, err = parseTime("since"); err != nil {
+		return f, err
+	}
+	if f.Until, err = parseTime("until"); err != nil {
+		return f, err
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = n
+	}
+
+	return f, nil
+}
+
+// match prüft einen Eintrag gegen den Filter.
+func (f auditFilter) match(e AuditEntry) bool {
+	if f.Action != "" && !strings.HasPrefix(e.Action, f.Action) {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(strings.ToLower(e.Target), strings.ToLower(f.Target)) {
+		return false
+	}
+	if f.User != "" && !strings.EqualFold(e.User, f.User) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// readAuditEntries liest die passenden Einträge, neueste zuerst.
+func readAuditEntries(f auditFilter) ([]AuditEntry, error) {
+	var out []AuditEntry
+
+	err := store.ReadEvents(auditStream, "", func(raw []byte) error {
+		var e AuditEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			// Beschädigte Zeile überspringen
+			return nil
+		}
+		if f.match(e) {
+			out = append(out, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.After(out[j].Time)
+	})
+
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+
+	return out, nil
+}
+
+// handleAudit liefert GET /api/audit (Filter siehe parseAuditFilter).
+// Das Log enthält alte und neue Werte aller Änderungen, daher nur für Admins.
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := readAuditEntries(f)
+	if err != nil {
+		http.Error(w, "failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, append([]AuditEntry{}, entries...))
+}
+
+// ------------------------------------------------------------
+// Audit-Seite
+// ------------------------------------------------------------
+
+// handleAuditPage zeigt /audit: Filterformular und Tabelle.
+// Die Filter sind dieselben Query-Parameter wie bei /api/audit.
+func handleAuditPage(w http.ResponseWriter, r *http.Request) {
+	esc := template.HTMLEscapeString
+	q := r.URL.Query()
+
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := readAuditEntries(f)
+	if err != nil {
+		http.Error(w, "failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 1200px; }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            table { border-collapse: collapse; width: 100%; }
+            th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #eee; font-size: 0.9em; vertical-align: top; }
+            th { background: #fafafa; }
+            .hint { color: #888; font-size: 0.85em; }
+            .old { color: #999; text-decoration: line-through; }
+            .err { color: #ce1126; }
+            form { margin-bottom: 16px; }
+            input { padding: 4px 6px; border: 1px solid #ddd; border-radius: 4px; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Audit-Log</h1>
+            </div>
+`)
+
+	fmt.Fprintf(w, `
+            <form method="get" action="/audit">
+                <input name="action" value="%s" placeholder="Aktion (z. B. device.)" size="16">
+                <input name="target" value="%s" placeholder="MAC / IP / Ziel" size="20">
+                <input name="user" value="%s" placeholder="Benutzer" size="12">
+                <input name="since" value="%s" placeholder="von (YYYY-MM-DD)" size="12">
+                <input name="until" value="%s" placeholder="bis (YYYY-MM-DD)" size="12">
+                <input name="limit" value="%d" size="5">
+                <button type="submit">Filtern</button>
+            </form>
+
+            <table>
+                <tr><th>Zeit</th><th>Benutzer</th><th>Client</th><th>Aktion</th><th>Ziel</th><th>Änderung</th><th>Ergebnis</th></tr>
+`, esc(q.Get("action")), esc(q.Get("target")), esc(q.Get("user")), esc(q.Get("since")), esc(q.Get("until")), f.Limit)
+
+	if len(entries) == 0 {
+		fmt.Fprint(w, `<tr><td colspan="7" class="hint">Keine Einträge.</td></tr>`)
+	}
+
+	for _, e := range entries {
+		change := esc(e.Details)
+		if e.Old != "" || e.New != "" {
+			change = `<span class="old">` + esc(e.Old) + `</span> &rarr; ` + esc(e.New)
+		}
+
+		result := esc(e.Result)
+		if e.Result != "ok" {
+			result = `<span class="err">` + result + `</span>`
+		}
+
+		user := e.User
+		if user == "" {
+			user = "-"
+		}
+
+		fmt.Fprintf(w, `<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>`,
+			e.Time.Format("02.01.2006 15:04:05"), esc(user), esc(e.Remote), esc(e.Action), esc(e.Target), change, result)
+	}
+
+	fmt.Fprint(w, `
+            </table>
+            <p class="hint">Das Audit-Log wird nur angehängt, Einträge werden nie geändert oder gelöscht.</p>
+        </div>
+    </body>
+    </html>
+`)
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ------------------------------------------------------------
+// Anmeldung und Rollen
+// ------------------------------------------------------------
+//
+// Jeder Handler wird in main.go mit requireRole registriert.
+// Rollen (jede höhere Rolle darf alles der niedrigeren):
+//
+//	viewer  Dashboard, Geräte, Historie, Metriken lesen
+//	editor  zusätzlich Büro/Kommentar ändern, Scans und Rescans auslösen
+//	admin   zusätzlich TwinCAT-State, Routen, Zugangsdaten, Alarme,
+//	        Konfiguration und Benutzerverwaltung
+//
+// Anmeldung:
+//
+//   - lokale Benutzer im Dokument "users", Passwort als bcrypt-Hash
+//   - optional LDAP: Bind mit Config.LDAPBindDN (%s = Benutzername);
+//     die Rolle kommt aus einem lokalen Eintrag ohne Passwort,
+//     sonst aus Config.LDAPDefaultRole
+//
+// Browser bekommen nach /login ein Sitzungs-Cookie (HttpOnly,
+// SameSite=Lax, damit fremde Seiten keine POSTs auslösen können).
+// Skripte und Prometheus können stattdessen HTTP Basic Auth schicken.
+//
+// Gibt es beim Start noch keinen Benutzer, wird "admin" angelegt.
+// Das Passwort kommt aus INVENTAR_ADMIN_PASSWORD oder wird zufällig
+// erzeugt und einmalig auf der Konsole ausgegeben.
+//
+// Mit Config.Auth = false gilt jeder Aufruf als admin (bisheriges Verhalten).
+
+// Rollen
+const (
+	roleViewer = "viewer"
+	roleEditor = "editor"
+	roleAdmin  = "admin"
+)
+
+// roleLevels ordnet die Rollen für Vergleiche.
+var roleLevels = map[string]int{
+	roleViewer: 1,
+	roleEditor: 2,
+	roleAdmin:  3,
+}
+
+const (
+	usersDocument     = "users"
+	sessionCookieName = "inventar_session"
+	adminPasswordEnv  = "INVENTAR_ADMIN_PASSWORD"
+
+	// Wartezeit nach einer fehlgeschlagenen Anmeldung (bremst Raten)
+	loginFailureDelay = 500 * time.Millisecond
+
+	// Zeitlimit für Verbindung und Bind am LDAP-Server
+	ldapTimeout = 5 * time.Second
+)
+
+// User ist ein lokaler Benutzer.
+//
+// Ohne PasswordHash kann sich der Benutzer nur per LDAP anmelden;
+// der Eintrag legt dann nur seine Rolle fest.
+type User struct {
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// authUser ist der angemeldete Benutzer eines Requests.
+type authUser struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// session ist eine Browser-Sitzung.
+type session struct {
+	user    authUser
+	expires time.Time
+}
+
+type authContextKey struct{}
+
+var (
+	// users: Key = Benutzername in Kleinbuchstaben.
+	users = make(map[string]User)
+
+	// sessions: Key = Cookie-Wert.
+	sessions = make(map[string]*session)
+
+	// authMutex schützt users und sessions.
+	authMutex sync.Mutex
+)
+
+var errInvalidLogin = errors.New("invalid username or password")
+
+// isValidRole prüft einen Rollennamen.
+func isValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// userKey normalisiert einen Benutzernamen für die Ablage.
+func userKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// ------------------------------------------------------------
+// Laden / Speichern
+// ------------------------------------------------------------
+
+// loadUsers lädt die lokalen Benutzer und legt bei Bedarf
+// den ersten Administrator an.
+func loadUsers() error {
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	loaded := make(map[string]User)
+	if _, err := store.LoadDocument(usersDocument, &loaded); err != nil {
+		return err
+	}
+	users = loaded
+
+	if len(users) > 0 || !cfg.Auth {
+		return nil
+	}
+
+	password := os.Getenv(adminPasswordEnv)
+	generated := password == ""
+	if generated {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	users["admin"] = User{Name: "admin", Role: roleAdmin, PasswordHash: string(hash)}
+	if err := saveUsersLocked(); err != nil {
+		return err
+	}
+
+	fmt.Println("-----------------------------------------------")
+	fmt.Println("Benutzer \"admin\" angelegt.")
+	if generated {
+		fmt.Println("Passwort:", password)
+		fmt.Println("Bitte nach der ersten Anmeldung unter /users ändern.")
+	}
+	fmt.Println("-----------------------------------------------")
+
+	return nil
+}
+
+// saveUsersLocked speichert alle Benutzer.
+// Aufrufer muss authMutex halten.
+func saveUsersLocked() error {
+	return store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(usersDocument, users)
+	})
+}
+
+// ------------------------------------------------------------
+// Anmeldung prüfen
+// ------------------------------------------------------------
+
+// authenticate prüft Benutzername und Passwort (lokal, dann LDAP).
+func authenticate(name, password string) (authUser, error) {
+	key := userKey(name)
+	if key == "" || password == "" {
+		return authUser{}, errInvalidLogin
+	}
+
+	authMutex.Lock()
+	u, known := users[key]
+	authMutex.Unlock()
+
+	if known && u.PasswordHash != "" {
+		if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+			return authUser{}, errInvalidLogin
+		}
+		return authUser{Name: u.Name, Role: u.Role}, nil
+	}
+
+	if cfg.LDAPURL == "" {
+		return authUser{}, errInvalidLogin
+	}
+
+	role := cfg.LDAPDefaultRole
+	if known {
+		role = u.Role
+	}
+	if role == "" {
+		return authUser{}, errInvalidLogin
+	}
+
+	if err := ldapBind(strings.TrimSpace(name), password); err != nil {
+		fmt.Println("LDAP-Anmeldung fehlgeschlagen:", name, err)
+		return authUser{}, errInvalidLogin
+	}
+
+	return authUser{Name: strings.TrimSpace(name), Role: role}, nil
+}
+
+// ldapBind meldet sich mit den Zugangsdaten am LDAP-Server an.
+//
+// Ein leeres Passwort wird vorher abgewiesen, da viele Server
+// einen Bind ohne Passwort als anonyme Anmeldung akzeptieren.
+func ldapBind(name, password string) error {
+	conn, err := ldap.DialURL(cfg.LDAPURL, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetTimeout(ldapTimeout)
+
+	if cfg.LDAPStartTLS {
+		u, err := url.Parse(cfg.LDAPURL)
+		if err != nil {
+			return err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return err
+		}
+	}
+
+	dn := strings.ReplaceAll(cfg.LDAPBindDN, "%s", ldap.EscapeDN(name))
+	return conn.Bind(dn, password)
+}
+
+// ------------------------------------------------------------
+// Sitzungen
+// ------------------------------------------------------------
+
+// newSession legt eine Sitzung an und liefert den Cookie-Wert.
+func newSession(u authUser) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	// Bei der Gelegenheit abgelaufene Sitzungen entfernen
+	now := time.Now()
+	for t, s := range sessions {
+		if now.After(s.expires) {
+			delete(sessions, t)
+		}
+	}
+
+	sessions[token] = &session{user: u, expires: now.Add(time.Duration(cfg.SessionTimeout))}
+	return token, nil
+}
+
+// sessionUser liefert den Benutzer zu einem Cookie-Wert
+// und verlängert die Sitzung.
+func sessionUser(token string) (authUser, bool) {
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	s, ok := sessions[token]
+	if !ok {
+		return authUser{}, false
+	}
+
+	now := time.Now()
+	if now.After(s.expires) {
+		delete(sessions, token)
+		return authUser{}, false
+	}
+
+	s.expires = now.Add(time.Duration(cfg.SessionTimeout))
+	return s.user, true
+}
+
+// endUserSessionsLocked beendet alle Sitzungen eines Benutzers,
+// z. B. nach Löschen oder Rollenwechsel.
+// Aufrufer muss authMutex halten.
+func endUserSessionsLocked(name string) {
+	for t, s := range sessions {
+		if userKey(s.user.Name) == userKey(name) {
+			delete(sessions, t)
+		}
+	}
+}
+
+// requestUserFromCredentials bestimmt den Benutzer aus Cookie oder Basic Auth.
+func requestUserFromCredentials(r *http.Request) (authUser, bool) {
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		if u, ok := sessionUser(c.Value); ok {
+			return u, true
+		}
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		u, err := authenticate(name, password)
+		if err == nil {
+			return u, true
+		}
+		time.Sleep(loginFailureDelay)
+	}
+
+	return authUser{}, false
+}
+
+// requestUser liefert den Benutzer, den requireRole im Kontext abgelegt hat.
+// Ohne Anmeldung (Config.Auth = false) ist der Name leer.
+func requestUser(r *http.Request) authUser {
+	if u, ok := r.Context().Value(authContextKey{}).(authUser); ok {
+		return u
+	}
+	return authUser{}
+}
+
+// ------------------------------------------------------------
+// Durchsetzung
+// ------------------------------------------------------------
+
+// requireRole schützt einen Handler.
+//
+// readRole gilt für GET/HEAD, writeRole für alle anderen Methoden.
+// Nicht angemeldete Browser werden auf /login umgeleitet,
+// andere Clients bekommen 401, zu niedrige Rollen 403.
+func requireRole(readRole, writeRole string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.Auth {
+			h(w, r.WithContext(context.WithValue(r.Context(), authContextKey{}, authUser{Role: roleAdmin})))
+			return
+		}
+
+		need := writeRole
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			need = readRole
+		}
+
+		u, ok := requestUserFromCredentials(r)
+		if !ok && cfg.AnonymousRole != "" {
+			u, ok = authUser{Role: cfg.AnonymousRole}, true
+		}
+
+		if !ok || (u.Name == "" && roleLevels[u.Role] < roleLevels[need]) {
+			if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "login required", http.StatusUnauthorized)
+			return
+		}
+
+		if roleLevels[u.Role] < roleLevels[need] {
+			http.Error(w, "forbidden: role "+need+" required", http.StatusForbidden)
+			return
+		}
+
+		h(w, r.WithContext(context.WithValue(r.Context(), authContextKey{}, u)))
+	}
+}
+
+// ------------------------------------------------------------
+// Login / Logout
+// ------------------------------------------------------------
+
+// safeRedirectTarget lässt nur lokale Pfade als Ziel nach dem Login zu.
+func safeRedirectTarget(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// handleLogin zeigt das Anmeldeformular (GET) bzw. meldet an (POST).
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		renderLoginPage(w, r.URL.Query().Get("next"), "")
+
+	case http.MethodPost:
+		name := r.FormValue("username")
+		next := r.FormValue("next")
+
+		u, err := authenticate(name, r.FormValue("password"))
+		writeAudit(r, AuditEntry{Action: auditLogin, Target: name, User: u.Name}, err)
+		if err != nil {
+			time.Sleep(loginFailureDelay)
+			w.WriteHeader(http.StatusUnauthorized)
+			renderLoginPage(w, next, "Benutzername oder Passwort falsch.")
+			return
+		}
+
+		token, err := newSession(u)
+		if err != nil {
+			http.Error(w, "failed to create session", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			Secure:   r.TLS != nil,
+		})
+
+		http.Redirect(w, r, safeRedirectTarget(next), http.StatusSeeOther)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLogout beendet die Sitzung.
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		authMutex.Lock()
+		delete(sessions, c.Value)
+		authMutex.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// renderLoginPage schreibt das Anmeldeformular.
+func renderLoginPage(w http.ResponseWriter, next, message string) {
+	esc := template.HTMLEscapeString
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	msg := ""
+	if message != "" {
+		msg = `<div class="err">` + esc(message) + `</div>`
+	}
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 24px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 320px; margin: 80px auto; }
+            label { display: block; margin-bottom: 10px; font-size: 0.9em; }
+            input { width: 100%; padding: 6px 8px; border: 1px solid #ddd; border-radius: 4px; margin-top: 4px; }
+            button { padding: 6px 14px; border: 1px solid #ce1126; background: #ce1126; color: white; border-radius: 4px; cursor: pointer; }
+            .err { background: #fdecea; border: 1px solid #f5c2c0; color: #ce1126; padding: 8px; border-radius: 4px; margin-bottom: 12px; font-size: 0.9em; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <img src="/static/logo.png" alt="Beckhoff" style="height: 32px;">
+            <h1 style="font-size: 1.2em; font-weight: 300;">Testnetz – Anmeldung</h1>
+            `+msg+`
+            <form method="post" action="/login">
+                <input type="hidden" name="next" value="`+esc(next)+`">
+                <label>Benutzer <input name="username" autofocus required></label>
+                <label>Passwort <input name="password" type="password" required></label>
+                <button type="submit">Anmelden</button>
+            </form>
+        </div>
+    </body>
+    </html>
+`)
+}
+
+// handleMe liefert GET /api/me: den angemeldeten Benutzer.
+func handleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, requestUser(r))
+}
+
+// ------------------------------------------------------------
+// Benutzerverwaltung
+// ------------------------------------------------------------
+
+// userEntry ist die API-Sicht eines Benutzers (ohne Hash).
+type userEntry struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	HasPassword bool   `json:"hasPassword"` // false = nur LDAP
+}
+
+type userRequest struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password"` // leer = bisheriges Passwort behalten
+}
+
+// listUsers liefert alle Benutzer sortiert nach Name.
+func listUsers() []userEntry {
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	out := make([]userEntry, 0, len(users))
+	for _, u := range users {
+		out = append(out, userEntry{Name: u.Name, Role: u.Role, HasPassword: u.PasswordHash != ""})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return userKey(out[i].Name) < userKey(out[j].Name) })
+	return out
+}
+
+// adminCountLocked zählt die Administratoren mit lokalem Passwort.
+func adminCountLocked() int {
+	n := 0
+	for _, u := range users {
+		if u.Role == roleAdmin && u.PasswordHash != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// handleUsers verwaltet die lokalen Benutzer.
+//
+//	GET    /api/users                  Liste (ohne Passwörter)
+//	POST   /api/users                  {"name":"mueller","role":"editor","password":"..."}
+//	DELETE /api/users?name=mueller     Benutzer löschen
+//
+// Der letzte Administrator mit Passwort kann weder gelöscht
+// noch herabgestuft werden.
+func handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, listUsers())
+
+	case http.MethodPost:
+		var req userRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		key := userKey(req.Name)
+		if key == "" || strings.ContainsAny(key, " :") {
+			http.Error(w, "invalid name", http.StatusBadRequest)
+			return
+		}
+		if !isValidRole(req.Role) {
+			http.Error(w, "invalid role", http.StatusBadRequest)
+			return
+		}
+
+		var hash string
+		if req.Password != "" {
+			h, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				http.Error(w, "invalid password", http.StatusBadRequest)
+				return
+			}
+			hash = string(h)
+		}
+
+		authMutex.Lock()
+		defer authMutex.Unlock()
+
+		prev, exists := users[key]
+		if hash == "" && exists {
+			hash = prev.PasswordHash
+		}
+
+		updated := User{Name: req.Name, Role: req.Role, PasswordHash: hash}
+		users[key] = updated
+
+		if exists && prev.Role == roleAdmin && prev.PasswordHash != "" && adminCountLocked() == 0 {
+			users[key] = prev
+			http.Error(w, "cannot remove the last admin", http.StatusConflict)
+			return
+		}
+
+		err := saveUsersLocked()
+
+		details := ""
+		if req.Password != "" {
+			details = "password set"
+		}
+		writeAudit(r, AuditEntry{Action: auditUserSave, Target: req.Name, Details: details, Old: prev.Role, New: updated.Role}, err)
+
+		if err != nil {
+			http.Error(w, "failed to save users", http.StatusInternalServerError)
+			return
+		}
+
+		// Neue Rolle bzw. neues Passwort gilt ab der nächsten Anmeldung
+		if exists && (prev.Role != updated.Role || prev.PasswordHash != updated.PasswordHash) {
+			endUserSessionsLocked(req.Name)
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodDelete:
+		key := userKey(r.URL.Query().Get("name"))
+
+		authMutex.Lock()
+		defer authMutex.Unlock()
+
+		prev, exists := users[key]
+		if !exists {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		delete(users, key)
+		if prev.Role == roleAdmin && prev.PasswordHash != "" && adminCountLocked() == 0 {
+			users[key] = prev
+			http.Error(w, "cannot remove the last admin", http.StatusConflict)
+			return
+		}
+
+		err := saveUsersLocked()
+		appendAuditChange(r, auditUserDelete, prev.Name, prev.Role, "", err)
+		if err != nil {
+			http.Error(w, "failed to save users", http.StatusInternalServerError)
+			return
+		}
+
+		endUserSessionsLocked(prev.Name)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUsersPage zeigt /users: Liste + Formular.
+// Gespeichert wird per fetch() gegen /api/users.
+func handleUsersPage(w http.ResponseWriter, r *http.Request) {
+	esc := template.HTMLEscapeString
+	list := listUsers()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 900px; }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            table { border-collapse: collapse; width: 100%; margin-bottom: 20px; }
+            th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #eee; font-size: 0.9em; }
+            th { background: #fafafa; }
+            .hint { color: #888; font-size: 0.85em; }
+            input, select { padding: 4px 6px; border: 1px solid #ddd; border-radius: 4px; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Benutzer</h1>
+            </div>
+
+            <table>
+                <tr><th>Name</th><th>Rolle</th><th>Anmeldung</th><th></th></tr>
+`)
+
+	for _, u := range list {
+		login := "LDAP"
+		if u.HasPassword {
+			login = "lokal"
+		}
+
+		fmt.Fprintf(w, `<tr><td>%s</td><td>%s</td><td>%s</td><td><button data-del="%s">Löschen</button></td></tr>`,
+			esc(u.Name), esc(u.Role), login, esc(u.Name))
+	}
+
+	ldapHint := "Kein LDAP-Server konfiguriert – Benutzer ohne Passwort können sich nicht anmelden."
+	if cfg.LDAPURL != "" {
+		ldapHint = "Benutzer ohne Passwort melden sich per LDAP an (" + cfg.LDAPURL + "); der Eintrag legt nur die Rolle fest."
+		if cfg.LDAPDefaultRole != "" {
+			ldapHint += " LDAP-Benutzer ohne Eintrag erhalten die Rolle " + cfg.LDAPDefaultRole + "."
+		}
+	}
+
+	fmt.Fprint(w, `
+            </table>
+
+            <form id="userForm">
+                <input name="name" placeholder="Benutzername" required>
+                <select name="role">
+                    <option value="viewer">viewer – nur lesen</option>
+                    <option value="editor">editor – Büro/Kommentar, Scans</option>
+                    <option value="admin">admin – alles</option>
+                </select>
+                <input name="password" type="password" placeholder="Passwort (leer = unverändert)">
+                <button type="submit">Speichern</button>
+            </form>
+            <p class="hint">Vorhandene Benutzer werden beim Speichern überschrieben. `+esc(ldapHint)+`</p>
+        </div>
+
+        <script>
+            async function send(method, url, body) {
+                const res = await fetch(url, {
+                    method: method,
+                    headers: { "Content-Type": "application/json" },
+                    body: body ? JSON.stringify(body) : undefined
+                });
+                if (!res.ok) {
+                    alert("Fehler: " + (await res.text()));
+                    return;
+                }
+                location.reload();
+            }
+
+            document.getElementById("userForm").addEventListener("submit", (e) => {
+                e.preventDefault();
+                const f = e.target;
+                send("POST", "/api/users", {
+                    name: f.name.value,
+                    role: f.role.value,
+                    password: f.password.value
+                });
+            });
+
+            document.querySelectorAll("button[data-del]").forEach(btn => {
+                btn.addEventListener("click", () => {
+                    if (!confirm("Benutzer " + btn.dataset.del + " löschen?")) return;
+                    send("DELETE", "/api/users?name=" + encodeURIComponent(btn.dataset.del));
+                });
+            });
+        </script>
+    </body>
+    </html>
+`)
+}
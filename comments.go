@@ -1,9 +1,6 @@
 package main
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -30,58 +27,23 @@ var (
 	commentMutex   sync.Mutex
 )
 
-//
-// ------------------------------------------------------------
-// Dateipfad für comments.json bestimmen
-// ------------------------------------------------------------
-//
-
-// commentFilePath liefert den absoluten Speicherort für comments.json.
-//
-// Ziel:
-// <exe-dir>/data/comments.json
-//
-// Dadurch bleibt die Datei immer neben der EXE
-// und funktioniert unabhängig vom aktuellen Startverzeichnis.
-func commentFilePath() string {
-	exe, err := os.Executable()
-	if err != nil {
-		return filepath.Join("data", "comments.json")
-	}
-
-	baseDir := filepath.Dir(exe)
-	return filepath.Join(baseDir, "data", "comments.json")
-}
-
 //
 // ------------------------------------------------------------
 // Kommentare laden
 // ------------------------------------------------------------
 //
 
-// loadComments lädt vorhandene Kommentar-Zuordnungen aus comments.json.
+// loadComments lädt vorhandene Kommentar-Zuordnungen aus dem Persistenz-Backend.
 //
 // Verhalten:
-// - wenn Datei fehlt → leer starten
-// - wenn Datei vorhanden → JSON einlesen
+// - wenn noch nichts gespeichert ist → leer starten
+// - sonst gespeicherten Stand übernehmen
 func loadComments() error {
 	commentMutex.Lock()
 	defer commentMutex.Unlock()
 
-	path := commentFilePath()
-
-	data, err := os.ReadFile(path)
+	loaded, err := store.LoadComments()
 	if err != nil {
-		if os.IsNotExist(err) {
-			deviceComments = make(map[string]string)
-			return nil
-		}
-		return err
-	}
-
-	var loaded map[string]string
-
-	if err := json.Unmarshal(data, &loaded); err != nil {
 		return err
 	}
 
@@ -100,37 +62,18 @@ func loadComments() error {
 // ------------------------------------------------------------
 //
 
-// saveComments schreibt alle Kommentare atomar nach comments.json.
-//
-// Ablauf:
-// 1. JSON erzeugen
-// 2. tmp-Datei schreiben
-// 3. tmp-Datei umbenennen
+// saveComments schreibt alle Kommentare atomar ins Persistenz-Backend.
 //
 // Vorteil:
-// keine beschädigte Datei bei Abbruch während des Schreibens.
+// keine beschädigten Daten bei Abbruch während des Schreibens
+// (bbolt-Transaktion bzw. temp-Datei + Rename).
 func saveComments() error {
 	commentMutex.Lock()
 	defer commentMutex.Unlock()
 
-	path := commentFilePath()
-
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
-	}
-
-	data, err := json.MarshalIndent(deviceComments, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	tmpPath := path + ".tmp"
-
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return err
-	}
-
-	return os.Rename(tmpPath, path)
+	return store.Update(func(tx StorageTx) error {
+		return tx.PutComments(deviceComments)
+	})
 }
 
 //
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Server-Konfiguration
+// ------------------------------------------------------------
+//
+// Config beschreibt alle Einstellungen, die beim Start aus
+// Konfigurationsdatei, Umgebungsvariablen und Flags gelesen werden.
+//
+// Beispiel config.json:
+//
+//	{
+//	  "port": 18080,
+//	  "dataDir": "D:\\Inventar\\data",
+//	  "scanRanges": ["172.17.76.0/24", "172.17.80.0/23"],
+//	  "scanInterval": "10m",
+//	  "adsWorkers": 8,
+//	  "pingWorkers": 20,
+//	  "pingTimeout": "800ms",
+//	  "unicastDiscovery": "auto",
+//	  "discoveryRate": 200
+//	}
+type Config struct {
+	ListenAddr string `json:"listenAddr"` // Adresse, an die der Webserver bindet
+	Port       int    `json:"port"`       // HTTP-Port
+	DataDir    string `json:"dataDir"`    // Verzeichnis für Datenbank bzw. JSON-Dateien
+	Storage    string `json:"storage"`    // Persistenz-Backend: "bolt" (Standard) oder "json"
+
+	ScanRanges   []string `json:"scanRanges"`   // zu scannende IPv4-Bereiche in CIDR-Schreibweise
+	ScanInterval Duration `json:"scanInterval"` // Abstand der automatischen Scans
+
+	AdsWorkers  int `json:"adsWorkers"`  // parallele ADS-State-Abfragen
+	PingWorkers int `json:"pingWorkers"` // parallele Ping-/ARP-/DNS-Abfragen
+
+	DiscoveryTimeout Duration `json:"discoveryTimeout"` // Gesamtdauer der UDP-Discovery-Phase
+	DiscoveryListen  Duration `json:"discoveryListen"`  // Sammeldauer für UDP-Antworten
+	AdsDeviceTimeout Duration `json:"adsDeviceTimeout"` // Gesamtbudget pro Gerät (Route + ReadState)
+	RouteTimeout     Duration `json:"routeTimeout"`     // Timeout für UDP AddRoute
+	ReadStateTimeout Duration `json:"readStateTimeout"` // Timeout für ADS ReadState über TCP
+	PingTimeout      Duration `json:"pingTimeout"`      // Timeout für einen einzelnen Ping
+
+	// Unicast-Discovery für geroutete Segmente (siehe ads_udp_unicast.go)
+	UnicastDiscovery string `json:"unicastDiscovery"` // "auto", "all" oder "off"
+	DiscoveryRate    int    `json:"discoveryRate"`    // Unicast-Discovery-Pakete pro Sekunde
+
+	// ADS-Routen temporär statt statisch anlegen – nur "best effort":
+	// das Flag-Tag im AddRoute-Paket ist nicht offiziell dokumentiert,
+	// Ziele, die es ignorieren, legen die Route trotzdem statisch an
+	// (siehe routeSegTemporaryL in ads_route.go). Eigene Routen lassen
+	// sich in jedem Fall per DELETE /api/devices/{id}/routes entfernen.
+	TemporaryRoutes bool `json:"temporaryRoutes"`
+
+	// Benachrichtigungen (siehe alerts.go)
+	SMTPAddr      string `json:"smtpAddr"`      // Mailserver "host:port"; leer = kein E-Mail-Versand
+	SMTPFrom      string `json:"smtpFrom"`      // Absenderadresse
+	SMTPUser      string `json:"smtpUser"`      // Benutzer für SMTP AUTH (optional)
+	SMTPPassword  string `json:"-"`             // nur über INVENTAR_SMTP_PASSWORD bzw. -smtp-password
+	AlertCommands bool   `json:"alertCommands"` // lokale Befehle als Benachrichtigung erlauben
+
+	// Anmeldung und Rollen (siehe auth.go)
+	Auth            bool     `json:"auth"`            // Anmeldung erzwingen (Standard: true)
+	AnonymousRole   string   `json:"anonymousRole"`   // Rolle ohne Anmeldung: "" (keine) oder "viewer"
+	SessionTimeout  Duration `json:"sessionTimeout"`  // Sitzungsdauer ohne Aktivität
+	LDAPURL         string   `json:"ldapUrl"`         // z. B. "ldaps://dc.example.com"; leer = nur lokale Benutzer
+	LDAPBindDN      string   `json:"ldapBindDn"`      // Vorlage mit %s, z. B. "%s@corp.example.com"
+	LDAPStartTLS    bool     `json:"ldapStartTls"`    // StartTLS bei ldap://
+	LDAPDefaultRole string   `json:"ldapDefaultRole"` // Rolle für LDAP-Benutzer ohne lokalen Eintrag ("" = abweisen)
+}
+
+// Duration ist eine time.Duration, die in JSON als lesbarer String
+// ("10m", "800ms") statt als Nanosekunden-Zahl gespeichert wird.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10m\": %w", err)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// defaultScanRanges entspricht dem ursprünglichen, festen Testnetz.
+var defaultScanRanges = []string{"172.17.76.0/24"}
+
+var (
+	// cfg ist die beim Start geladene, effektive Konfiguration.
+	// Sie wird nur in main() geschrieben und danach nur noch gelesen.
+	cfg = defaultConfig()
+
+	// cfgPath merkt sich, aus welcher Datei die Konfiguration stammt.
+	cfgPath string
+)
+
+// defaultConfig liefert die Standardwerte, falls keine Datei vorhanden ist.
+//
+// Die Werte entsprechen den bisher fest einkompilierten Konstanten.
+func defaultConfig() Config {
+	return Config{
+		ListenAddr: "0.0.0.0",
+		Port:       18080,
+		DataDir:    defaultDataDir(),
+		Storage:    storageBolt,
+
+		ScanRanges:   append([]string(nil), defaultScanRanges...),
+		ScanInterval: Duration(10 * time.Minute),
+
+		AdsWorkers:  8,
+		PingWorkers: 20,
+
+		DiscoveryTimeout: Duration(4 * time.Second),
+		DiscoveryListen:  Duration(2500 * time.Millisecond),
+		AdsDeviceTimeout: Duration(6 * time.Second),
+		RouteTimeout:     Duration(3 * time.Second),
+		ReadStateTimeout: Duration(4 * time.Second),
+		PingTimeout:      Duration(800 * time.Millisecond),
+
+		UnicastDiscovery: unicastDiscoveryAuto,
+		DiscoveryRate:    200,
+
+		Auth:            true,
+		SessionTimeout:  Duration(12 * time.Hour),
+		LDAPDefaultRole: roleViewer,
+	}
+}
+
+// ------------------------------------------------------------
+// Standardpfade
+// ------------------------------------------------------------
+
+// defaultDataDir liefert das Standard-Datenverzeichnis:
+//
+//	<exe-dir>/data
+//
+// Falls der Pfad zur EXE nicht bestimmt werden kann,
+// wird auf einen relativen Pfad zurückgegriffen.
+func defaultDataDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "data"
+	}
+
+	return filepath.Join(filepath.Dir(exe), "data")
+}
+
+// defaultConfigPath liefert den Standardpfad der Konfigurationsdatei:
+//
+//	<exe-dir>/config.json
+func defaultConfigPath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "config.json"
+	}
+
+	return filepath.Join(filepath.Dir(exe), "config.json")
+}
+
+// ------------------------------------------------------------
+// Konfiguration laden
+// ------------------------------------------------------------
+
+// loadConfigFile liest die Konfiguration aus einer JSON-Datei.
+//
+// Fehlt die Datei, werden die Standardwerte verwendet.
+// Nicht gesetzte Felder behalten ebenfalls ihren Standardwert.
+func loadConfigFile(path string) (Config, error) {
+	c := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, fmt.Errorf("read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// configSetting beschreibt eine einzelne Einstellung,
+// die per Umgebungsvariable und Flag überschrieben werden kann.
+type configSetting struct {
+	flag  string                      // Flag-Name, z. B. "port"
+	env   string                      // Umgebungsvariable, z. B. "INVENTAR_PORT"
+	usage string                      // Hilfetext
+	set   func(*Config, string) error // übernimmt den String-Wert
+	list  bool                        // Flag darf mehrfach angegeben werden
+}
+
+// stringListFlag sammelt alle Werte eines mehrfach angegebenen Flags, z. B.
+//
+//	-subnet 172.17.76.0/24 -subnet 172.17.80.0/23
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringListFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// value liefert den effektiven Flag-Wert:
+// bei Listen alle Angaben kommasepariert, sonst die letzte Angabe.
+func (s stringListFlag) value(list bool) string {
+	if len(s) == 0 {
+		return ""
+	}
+	if list {
+		return strings.Join(s, ",")
+	}
+	return s[len(s)-1]
+}
+
+func setInt(dst *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func setBool(dst *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		*dst = b
+		return nil
+	}
+}
+
+func setDuration(dst *Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		*dst = Duration(d)
+		return nil
+	}
+}
+
+// splitList zerlegt kommaseparierte Listen und entfernt leere Einträge.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// configSettings listet alle überschreibbaren Einstellungen.
+var configSettings = []configSetting{
+	{"listen", "INVENTAR_LISTEN", "Adresse, an die der Webserver bindet",
+		func(c *Config, v string) error { c.ListenAddr = strings.TrimSpace(v); return nil }, false},
+	{"port", "INVENTAR_PORT", "HTTP-Port",
+		func(c *Config, v string) error { return setInt(&c.Port)(v) }, false},
+	{"data-dir", "INVENTAR_DATA_DIR", "Datenverzeichnis",
+		func(c *Config, v string) error { c.DataDir = strings.TrimSpace(v); return nil }, false},
+	{"storage", "INVENTAR_STORAGE", "Persistenz-Backend: bolt oder json",
+		func(c *Config, v string) error { c.Storage = strings.ToLower(strings.TrimSpace(v)); return nil }, false},
+	{"subnet", "INVENTAR_SCAN_RANGES", "zu scannende IPv4-Bereiche in CIDR-Schreibweise (mehrfach oder kommasepariert)",
+		func(c *Config, v string) error { c.ScanRanges = splitList(v); return nil }, true},
+	{"scan-interval", "INVENTAR_SCAN_INTERVAL", "Abstand der automatischen Scans (z. B. 10m)",
+		func(c *Config, v string) error { return setDuration(&c.ScanInterval)(v) }, false},
+	{"ads-workers", "INVENTAR_ADS_WORKERS", "parallele ADS-State-Abfragen",
+		func(c *Config, v string) error { return setInt(&c.AdsWorkers)(v) }, false},
+	{"ping-workers", "INVENTAR_PING_WORKERS", "parallele Ping-Abfragen",
+		func(c *Config, v string) error { return setInt(&c.PingWorkers)(v) }, false},
+	{"discovery-timeout", "INVENTAR_DISCOVERY_TIMEOUT", "Gesamtdauer der UDP-Discovery",
+		func(c *Config, v string) error { return setDuration(&c.DiscoveryTimeout)(v) }, false},
+	{"discovery-listen", "INVENTAR_DISCOVERY_LISTEN", "Sammeldauer für UDP-Antworten",
+		func(c *Config, v string) error { return setDuration(&c.DiscoveryListen)(v) }, false},
+	{"ads-device-timeout", "INVENTAR_ADS_DEVICE_TIMEOUT", "Zeitbudget pro Gerät für Route + ReadState",
+		func(c *Config, v string) error { return setDuration(&c.AdsDeviceTimeout)(v) }, false},
+	{"route-timeout", "INVENTAR_ROUTE_TIMEOUT", "Timeout für UDP AddRoute",
+		func(c *Config, v string) error { return setDuration(&c.RouteTimeout)(v) }, false},
+	{"readstate-timeout", "INVENTAR_READSTATE_TIMEOUT", "Timeout für ADS ReadState",
+		func(c *Config, v string) error { return setDuration(&c.ReadStateTimeout)(v) }, false},
+	{"ping-timeout", "INVENTAR_PING_TIMEOUT", "Timeout für einen Ping",
+		func(c *Config, v string) error { return setDuration(&c.PingTimeout)(v) }, false},
+	{"unicast-discovery", "INVENTAR_UNICAST_DISCOVERY", "Unicast-Discovery: auto (nur geroutete Bereiche), all oder off",
+		func(c *Config, v string) error {
+			c.UnicastDiscovery = strings.ToLower(strings.TrimSpace(v))
+			return nil
+		}, false},
+	{"discovery-rate", "INVENTAR_DISCOVERY_RATE", "Unicast-Discovery-Pakete pro Sekunde",
+		func(c *Config, v string) error { return setInt(&c.DiscoveryRate)(v) }, false},
+	{"temporary-routes", "INVENTAR_TEMPORARY_ROUTES", "ADS-Routen temporär anlegen (true/false, best effort: nicht jedes Ziel beachtet das)",
+		func(c *Config, v string) error { return setBool(&c.TemporaryRoutes)(v) }, false},
+	{"smtp-addr", "INVENTAR_SMTP_ADDR", "Mailserver für Alarme (host:port)",
+		func(c *Config, v string) error { c.SMTPAddr = strings.TrimSpace(v); return nil }, false},
+	{"smtp-from", "INVENTAR_SMTP_FROM", "Absenderadresse für Alarm-Mails",
+		func(c *Config, v string) error { c.SMTPFrom = strings.TrimSpace(v); return nil }, false},
+	{"smtp-user", "INVENTAR_SMTP_USER", "Benutzer für SMTP AUTH",
+		func(c *Config, v string) error { c.SMTPUser = strings.TrimSpace(v); return nil }, false},
+	{"smtp-password", "INVENTAR_SMTP_PASSWORD", "Passwort für SMTP AUTH (besser per Umgebungsvariable)",
+		func(c *Config, v string) error { c.SMTPPassword = v; return nil }, false},
+	{"alert-commands", "INVENTAR_ALERT_COMMANDS", "lokale Befehle als Alarm-Benachrichtigung erlauben (true/false)",
+		func(c *Config, v string) error { return setBool(&c.AlertCommands)(v) }, false},
+	{"auth", "INVENTAR_AUTH", "Anmeldung erzwingen (true/false)",
+		func(c *Config, v string) error { return setBool(&c.Auth)(v) }, false},
+	{"anonymous-role", "INVENTAR_ANONYMOUS_ROLE", "Rolle ohne Anmeldung (leer oder viewer)",
+		func(c *Config, v string) error { c.AnonymousRole = strings.ToLower(strings.TrimSpace(v)); return nil }, false},
+	{"session-timeout", "INVENTAR_SESSION_TIMEOUT", "Sitzungsdauer ohne Aktivität (z. B. 12h)",
+		func(c *Config, v string) error { return setDuration(&c.SessionTimeout)(v) }, false},
+	{"ldap-url", "INVENTAR_LDAP_URL", "LDAP-Server für die Anmeldung (ldap:// oder ldaps://)",
+		func(c *Config, v string) error { c.LDAPURL = strings.TrimSpace(v); return nil }, false},
+	{"ldap-bind-dn", "INVENTAR_LDAP_BIND_DN", "Bind-DN-Vorlage mit %s für den Benutzernamen",
+		func(c *Config, v string) error { c.LDAPBindDN = strings.TrimSpace(v); return nil }, false},
+	{"ldap-starttls", "INVENTAR_LDAP_STARTTLS", "StartTLS für ldap:// verwenden (true/false)",
+		func(c *Config, v string) error { return setBool(&c.LDAPStartTLS)(v) }, false},
+	{"ldap-default-role", "INVENTAR_LDAP_DEFAULT_ROLE", "Rolle für LDAP-Benutzer ohne lokalen Eintrag (leer = abweisen)",
+		func(c *Config, v string) error { c.LDAPDefaultRole = strings.ToLower(strings.TrimSpace(v)); return nil }, false},
+}
+
+// loadConfig bestimmt die effektive Konfiguration.
+//
+// Reihenfolge (spätere Quellen überschreiben frühere):
+// 1. Standardwerte
+// 2. Konfigurationsdatei (-config bzw. INVENTAR_CONFIG)
+// 3. Umgebungsvariablen (INVENTAR_*)
+// 4. Kommandozeilen-Flags
+//
+// Anschließend wird die Konfiguration validiert
+// und die Scan-Bereiche werden global gesetzt.
+func loadConfig(args []string) (Config, error) {
+	fs := flag.NewFlagSet("labor-inventar", flag.ContinueOnError)
+
+	defPath := defaultConfigPath()
+	if v := os.Getenv("INVENTAR_CONFIG"); v != "" {
+		defPath = v
+	}
+
+	configPath := fs.String("config", defPath, "Pfad zur Konfigurationsdatei (JSON)")
+
+	flagValues := make(map[string]*stringListFlag, len(configSettings))
+	for _, s := range configSettings {
+		v := &stringListFlag{}
+		flagValues[s.flag] = v
+		fs.Var(v, s.flag, s.usage+" (env "+s.env+")")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	c, err := loadConfigFile(*configPath)
+	if err != nil {
+		return c, err
+	}
+
+	cfgPath = *configPath
+
+	// Umgebungsvariablen
+	for _, s := range configSettings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.set(&c, v); err != nil {
+				return c, fmt.Errorf("env %s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Nur explizit gesetzte Flags übernehmen
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range configSettings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(&c, flagValues[s.flag].value(s.list)); err != nil {
+					flagErr = fmt.Errorf("flag -%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return c, flagErr
+	}
+
+	if err := validateConfig(&c); err != nil {
+		return c, err
+	}
+
+	nets, err := parseScanRanges(c.ScanRanges)
+	if err != nil {
+		return c, err
+	}
+
+	scanNets = nets
+
+	return c, nil
+}
+
+// ------------------------------------------------------------
+// Validierung
+// ------------------------------------------------------------
+
+// validateConfig prüft die Konfiguration auf unplausible Werte.
+//
+// Relative Datenverzeichnisse werden dabei in absolute Pfade umgewandelt,
+// damit das Tool unabhängig vom Startverzeichnis arbeitet.
+func validateConfig(c *Config) error {
+	var problems []string
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %d out of range 1..65535", c.Port))
+	}
+
+	if c.ListenAddr == "" {
+		problems = append(problems, "listenAddr must not be empty")
+	}
+
+	if strings.TrimSpace(c.DataDir) == "" {
+		problems = append(problems, "dataDir must not be empty")
+	} else if abs, err := filepath.Abs(c.DataDir); err == nil {
+		c.DataDir = abs
+	}
+
+	if c.Storage != storageBolt && c.Storage != storageJSON {
+		problems = append(problems, fmt.Sprintf("storage %q must be %q or %q", c.Storage, storageBolt, storageJSON))
+	}
+
+	if time.Duration(c.ScanInterval) < time.Minute {
+		problems = append(problems, fmt.Sprintf("scanInterval %s is shorter than 1m", c.ScanInterval))
+	}
+
+	if c.AdsWorkers < 1 || c.AdsWorkers > 256 {
+		problems = append(problems, fmt.Sprintf("adsWorkers %d out of range 1..256", c.AdsWorkers))
+	}
+
+	if c.PingWorkers < 1 || c.PingWorkers > 512 {
+		problems = append(problems, fmt.Sprintf("pingWorkers %d out of range 1..512", c.PingWorkers))
+	}
+
+	switch c.UnicastDiscovery {
+	case unicastDiscoveryAuto, unicastDiscoveryAll, unicastDiscoveryOff:
+	default:
+		problems = append(problems, fmt.Sprintf("unicastDiscovery %q must be %q, %q or %q",
+			c.UnicastDiscovery, unicastDiscoveryAuto, unicastDiscoveryAll, unicastDiscoveryOff))
+	}
+
+	if c.DiscoveryRate < 1 || c.DiscoveryRate > 10000 {
+		problems = append(problems, fmt.Sprintf("discoveryRate %d out of range 1..10000", c.DiscoveryRate))
+	}
+
+	if c.AnonymousRole != "" && c.AnonymousRole != roleViewer {
+		problems = append(problems, fmt.Sprintf("anonymousRole %q must be empty or %q", c.AnonymousRole, roleViewer))
+	}
+
+	if c.LDAPDefaultRole != "" && !isValidRole(c.LDAPDefaultRole) {
+		problems = append(problems, fmt.Sprintf("ldapDefaultRole %q must be empty, %q, %q or %q",
+			c.LDAPDefaultRole, roleViewer, roleEditor, roleAdmin))
+	}
+
+	if c.LDAPURL != "" && !strings.Contains(c.LDAPBindDN, "%s") {
+		problems = append(problems, "ldapBindDn must contain %s when ldapUrl is set")
+	}
+
+	if c.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.SMTPAddr); err != nil {
+			problems = append(problems, fmt.Sprintf("smtpAddr %q must be host:port", c.SMTPAddr))
+		}
+		if c.SMTPFrom == "" {
+			problems = append(problems, "smtpFrom must be set when smtpAddr is set")
+		}
+	}
+
+	durations := []struct {
+		name string
+		d    Duration
+	}{
+		{"discoveryTimeout", c.DiscoveryTimeout},
+		{"discoveryListen", c.DiscoveryListen},
+		{"adsDeviceTimeout", c.AdsDeviceTimeout},
+		{"routeTimeout", c.RouteTimeout},
+		{"readStateTimeout", c.ReadStateTimeout},
+		{"pingTimeout", c.PingTimeout},
+		{"sessionTimeout", c.SessionTimeout},
+	}
+	for _, d := range durations {
+		if d.d <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be > 0", d.name))
+		}
+	}
+
+	if c.DiscoveryListen > c.DiscoveryTimeout {
+		problems = append(problems, "discoveryListen must not exceed discoveryTimeout")
+	}
+
+	if c.RouteTimeout > c.AdsDeviceTimeout {
+		problems = append(problems, "routeTimeout must not exceed adsDeviceTimeout")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
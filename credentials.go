@@ -0,0 +1,557 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ------------------------------------------------------------
+// Zugangsdaten für ADS-Routen
+// ------------------------------------------------------------
+//
+// Zum Anlegen einer ADS-Route (UDP AddRoute) braucht das Zielgerät
+// Benutzername und Passwort eines Windows-/TwinCAT-Benutzers.
+//
+// Die Zugangsdaten werden pro Geltungsbereich (Scope) hinterlegt:
+//
+//	mac:<MAC>        genau ein Gerät
+//	office:<Büro>    alle Geräte eines Büros
+//	default          alle übrigen Geräte
+//
+// Auswahl für ein Gerät: MAC vor Büro vor Default.
+// Ist nichts hinterlegt, gilt der Beckhoff-Auslieferungszustand
+// (Administrator / 1).
+//
+// Gespeichert wird im Dokument "route_credentials", jeder Eintrag
+// mit AES-256-GCM verschlüsselt. Der Schlüssel kommt aus der
+// Umgebungsvariable INVENTAR_CREDENTIAL_KEY:
+//
+//   - 32 Byte base64-kodiert → direkt als Schlüssel
+//   - sonst beliebige Passphrase → SHA-256 der Passphrase
+//
+// Ohne Schlüssel können keine Zugangsdaten gespeichert werden,
+// der Scanner verwendet dann nur den Auslieferungszustand.
+// Sind bereits Zugangsdaten gespeichert, startet das Programm
+// ohne Schlüssel nicht.
+type RouteCredential struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+const (
+	credentialDocument = "route_credentials"
+	credentialKeyEnv   = "INVENTAR_CREDENTIAL_KEY"
+
+	credentialScopeDefault = "default"
+	credentialScopeMAC     = "mac:"
+	credentialScopeOffice  = "office:"
+)
+
+// defaultRouteCredential ist der Beckhoff-Auslieferungszustand.
+var defaultRouteCredential = RouteCredential{User: "Administrator", Password: "1"}
+
+var (
+	// routeCredentials enthält die entschlüsselten Zugangsdaten, Key = Scope.
+	routeCredentials = make(map[string]RouteCredential)
+
+	// credentialAEAD ist nil, wenn kein Schlüssel gesetzt ist.
+	credentialAEAD cipher.AEAD
+
+	// credentialMutex schützt routeCredentials.
+	credentialMutex sync.Mutex
+)
+
+// ------------------------------------------------------------
+// Verschlüsselung
+// ------------------------------------------------------------
+
+// credentialKeyFromEnv leitet den AES-Schlüssel aus der Umgebung ab.
+func credentialKeyFromEnv() []byte {
+	raw := strings.TrimSpace(os.Getenv(credentialKeyEnv))
+	if raw == "" {
+		return nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(raw); err == nil && len(key) == 32 {
+		return key
+	}
+
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
+
+// sealCredential verschlüsselt einen Eintrag: base64(nonce | ciphertext).
+func sealCredential(aead cipher.AEAD, c RouteCredential) (string, error) {
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plain, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openCredential entschlüsselt einen mit sealCredential erzeugten Eintrag.
+func openCredential(aead cipher.AEAD, s string) (RouteCredential, error) {
+	var c RouteCredential
+
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return c, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(plain, &c)
+	return c, err
+}
+
+// ------------------------------------------------------------
+// Laden / Speichern
+// ------------------------------------------------------------
+
+// loadRouteCredentials initialisiert den Schlüssel und lädt
+// alle gespeicherten Zugangsdaten.
+//
+// Einträge, die sich nicht entschlüsseln lassen (falscher Schlüssel),
+// führen zu einem Fehler, damit sie nicht beim nächsten Speichern
+// stillschweigend verloren gehen. Dasselbe gilt, wenn Einträge
+// gespeichert sind, aber kein Schlüssel gesetzt ist: sonst würde der
+// Scanner unbemerkt nur noch den Auslieferungszustand verwenden.
+func loadRouteCredentials() error {
+	credentialMutex.Lock()
+	defer credentialMutex.Unlock()
+
+	var sealed map[string]string
+	if _, err := store.LoadDocument(credentialDocument, &sealed); err != nil {
+		return err
+	}
+
+	key := credentialKeyFromEnv()
+	if key == nil {
+		// Gespeicherte Zugangsdaten ohne Schlüssel nicht stillschweigend
+		// durch den Auslieferungszustand ersetzen
+		if len(sealed) > 0 {
+			return fmt.Errorf("%d stored route credentials, but %s is not set", len(sealed), credentialKeyEnv)
+		}
+		credentialAEAD = nil
+		routeCredentials = make(map[string]RouteCredential)
+		return nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]RouteCredential, len(sealed))
+	for scope, s := range sealed {
+		c, err := openCredential(aead, s)
+		if err != nil {
+			return fmt.Errorf("decrypt credential %q (wrong %s?): %w", scope, credentialKeyEnv, err)
+		}
+		loaded[scope] = c
+	}
+
+	credentialAEAD = aead
+	routeCredentials = loaded
+	return nil
+}
+
+// saveRouteCredentialsLocked verschlüsselt und speichert alle Einträge
+// von creds und übernimmt sie erst nach erfolgreichem Speichern als
+// routeCredentials. Schlägt das Speichern fehl, bleibt der Stand im
+// Speicher unverändert.
+//
+// Aufrufer muss credentialMutex halten.
+func saveRouteCredentialsLocked(creds map[string]RouteCredential) error {
+	err := store.Update(func(tx StorageTx) error {
+		return putRouteCredentials(tx, creds)
+	})
+	if err != nil {
+		return err
+	}
+
+	routeCredentials = creds
+	return nil
+}
+
+// putRouteCredentials verschlüsselt creds und schreibt sie in tx.
+func putRouteCredentials(tx StorageTx, creds map[string]RouteCredential) error {
+	sealed := make(map[string]string, len(creds))
+
+	for scope, c := range creds {
+		s, err := sealCredential(credentialAEAD, c)
+		if err != nil {
+			return err
+		}
+		sealed[scope] = s
+	}
+
+	return tx.PutDocument(credentialDocument, sealed)
+}
+
+// copyRouteCredentialsLocked liefert eine Kopie von routeCredentials,
+// auf der Änderungen vor dem Speichern vorbereitet werden.
+//
+// Aufrufer muss credentialMutex halten.
+func copyRouteCredentialsLocked() map[string]RouteCredential {
+	creds := make(map[string]RouteCredential, len(routeCredentials))
+	for scope, c := range routeCredentials {
+		creds[scope] = c
+	}
+	return creds
+}
+
+// ------------------------------------------------------------
+// Auswahl für ein Gerät
+// ------------------------------------------------------------
+
+// renameCredentialOfficeLocked verschiebt den Scope "office:<alt>" auf
+// "office:<neu>" (Umbenennen im Büro-Katalog).
+//
+// Der neue Stand wird in tx geschrieben; apply übernimmt ihn erst nach
+// erfolgreichem Speichern der Transaktion in den Speicher.
+//
+// Aufrufer muss credentialMutex halten.
+func renameCredentialOfficeLocked(tx StorageTx, oldName, newName string) (apply func(), err error) {
+	c, ok := routeCredentials[credentialScopeOffice+oldName]
+	if !ok {
+		return func() {}, nil
+	}
+
+	creds := copyRouteCredentialsLocked()
+	delete(creds, credentialScopeOffice+oldName)
+	creds[credentialScopeOffice+newName] = c
+
+	if err := putRouteCredentials(tx, creds); err != nil {
+		return nil, err
+	}
+	return func() { routeCredentials = creds }, nil
+}
+
+// normalizeCredentialScope prüft und vereinheitlicht einen Scope.
+//
+// Aufrufer muss officeCatalogMutex halten.
+func normalizeCredentialScope(scope string) (string, error) {
+	scope = strings.TrimSpace(scope)
+
+	switch {
+	case scope == credentialScopeDefault:
+		return scope, nil
+
+	case strings.HasPrefix(scope, credentialScopeMAC):
+		mac := normalizeMAC(strings.TrimPrefix(scope, credentialScopeMAC))
+		if mac == "" {
+			return "", errors.New("missing mac")
+		}
+		return credentialScopeMAC + mac, nil
+
+	case strings.HasPrefix(scope, credentialScopeOffice):
+		office := strings.TrimSpace(strings.TrimPrefix(scope, credentialScopeOffice))
+		if office == "" || !isValidOfficeLocked(office) {
+			return "", errors.New("invalid office")
+		}
+		return credentialScopeOffice + office, nil
+	}
+
+	return "", fmt.Errorf("invalid scope %q (default, mac:<MAC>, office:<Büro>)", scope)
+}
+
+// routeCredentialFor liefert die Zugangsdaten für ein Gerät
+// und den Scope, aus dem sie stammen ("" = Auslieferungszustand).
+func routeCredentialFor(mac, office string) (RouteCredential, string) {
+	credentialMutex.Lock()
+	defer credentialMutex.Unlock()
+
+	candidates := []string{}
+	if mac = normalizeMAC(mac); mac != "" {
+		candidates = append(candidates, credentialScopeMAC+mac)
+	}
+	if office != "" {
+		candidates = append(candidates, credentialScopeOffice+office)
+	}
+	candidates = append(candidates, credentialScopeDefault)
+
+	for _, scope := range candidates {
+		if c, ok := routeCredentials[scope]; ok {
+			return c, scope
+		}
+	}
+
+	return defaultRouteCredential, ""
+}
+
+// ------------------------------------------------------------
+// HTTP-API
+// ------------------------------------------------------------
+
+// credentialEntry ist die API-Sicht eines Eintrags.
+// Passwörter werden nie zurückgegeben.
+type credentialEntry struct {
+	Scope       string `json:"scope"`
+	User        string `json:"user"`
+	HasPassword bool   `json:"hasPassword"`
+}
+
+type credentialListResponse struct {
+	Enabled bool              `json:"enabled"` // false = kein Schlüssel gesetzt
+	Entries []credentialEntry `json:"entries"`
+}
+
+type credentialRequest struct {
+	Scope    string `json:"scope"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// listRouteCredentials liefert alle Einträge sortiert nach Scope.
+func listRouteCredentials() credentialListResponse {
+	credentialMutex.Lock()
+	defer credentialMutex.Unlock()
+
+	resp := credentialListResponse{
+		Enabled: credentialAEAD != nil,
+		Entries: make([]credentialEntry, 0, len(routeCredentials)),
+	}
+
+	for scope, c := range routeCredentials {
+		resp.Entries = append(resp.Entries, credentialEntry{
+			Scope:       scope,
+			User:        c.User,
+			HasPassword: c.Password != "",
+		})
+	}
+
+	sort.Slice(resp.Entries, func(i, j int) bool {
+		return resp.Entries[i].Scope < resp.Entries[j].Scope
+	})
+
+	return resp
+}
+
+// handleCredentials verwaltet die Routen-Zugangsdaten.
+//
+//	GET    /api/credentials                 Liste (ohne Passwörter)
+//	POST   /api/credentials                 {"scope":"office:B1","user":"...","password":"..."}
+//	DELETE /api/credentials?scope=mac:...   Eintrag entfernen
+func handleCredentials(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, listRouteCredentials())
+		return
+
+	case http.MethodPost, http.MethodDelete:
+		// unten
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req credentialRequest
+
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Scope = r.URL.Query().Get("scope")
+	}
+
+	// Prüfen und Speichern unter officeCatalogMutex,
+	// damit deleteOffice das Büro nicht dazwischen entfernt.
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	scope, err := normalizeCredentialScope(req.Scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	credentialMutex.Lock()
+	defer credentialMutex.Unlock()
+
+	if credentialAEAD == nil {
+		http.Error(w, credentialKeyEnv+" is not set", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Änderung auf einer Kopie vorbereiten, damit der Scanner bei
+	// einem Speicherfehler weiter den gespeicherten Stand verwendet
+	creds := copyRouteCredentialsLocked()
+
+	if r.Method == http.MethodPost {
+		req.User = strings.TrimSpace(req.User)
+		if req.User == "" {
+			http.Error(w, "missing user", http.StatusBadRequest)
+			return
+		}
+
+		creds[scope] = RouteCredential{User: req.User, Password: req.Password}
+	} else {
+		if _, ok := creds[scope]; !ok {
+			http.Error(w, "scope not found", http.StatusNotFound)
+			return
+		}
+		delete(creds, scope)
+	}
+
+	err = saveRouteCredentialsLocked(creds)
+
+	// Passwörter nie ins Audit-Log schreiben, nur den Benutzer
+	if r.Method == http.MethodPost {
+		appendAudit(r, auditCredentialSet, scope, "user "+req.User, err)
+	} else {
+		appendAudit(r, auditCredentialDel, scope, "", err)
+	}
+
+	if err != nil {
+		http.Error(w, "failed to save credentials", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ------------------------------------------------------------
+// Verwaltungsseite
+// ------------------------------------------------------------
+
+// handleCredentialsPage zeigt /credentials: Liste + Formular.
+// Gespeichert wird per fetch() gegen /api/credentials.
+func handleCredentialsPage(w http.ResponseWriter, r *http.Request) {
+	esc := template.HTMLEscapeString
+	list := listRouteCredentials()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 900px; }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            table { border-collapse: collapse; width: 100%; margin-bottom: 20px; }
+            th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #eee; font-size: 0.9em; }
+            th { background: #fafafa; }
+            .warn { background: #fff3cd; border: 1px solid #ffe08a; padding: 10px; border-radius: 4px; margin-bottom: 16px; }
+            .hint { color: #888; font-size: 0.85em; }
+            input { padding: 4px 6px; border: 1px solid #ddd; border-radius: 4px; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">ADS-Routen: Zugangsdaten</h1>
+            </div>
+`)
+
+	if !list.Enabled {
+		fmt.Fprint(w, `<div class="warn">`+credentialKeyEnv+` ist nicht gesetzt – es wird nur Administrator / 1 verwendet,
+            Zugangsdaten können nicht gespeichert werden.</div>`)
+	}
+
+	fmt.Fprint(w, `
+            <table>
+                <tr><th>Geltungsbereich</th><th>Benutzer</th><th>Passwort</th><th></th></tr>
+`)
+
+	if len(list.Entries) == 0 {
+		fmt.Fprint(w, `<tr><td colspan="4" class="hint">Keine Einträge – es gilt Administrator / 1.</td></tr>`)
+	}
+
+	for _, e := range list.Entries {
+		pw := "-"
+		if e.HasPassword {
+			pw = "••••••"
+		}
+
+		fmt.Fprintf(w, `<tr><td>%s</td><td>%s</td><td>%s</td><td><button data-del="%s">Löschen</button></td></tr>`,
+			esc(e.Scope), esc(e.User), pw, esc(e.Scope))
+	}
+
+	fmt.Fprint(w, `
+            </table>
+
+            <form id="credForm">
+                <input name="scope" placeholder="default | office:&lt;Büro&gt; | mac:&lt;MAC&gt;" size="32" required>
+                <input name="user" placeholder="Benutzer" required>
+                <input name="password" type="password" placeholder="Passwort">
+                <button type="submit">Speichern</button>
+            </form>
+            <p class="hint">Reihenfolge: MAC vor Büro vor default. Passwörter werden verschlüsselt gespeichert und nie angezeigt.</p>
+        </div>
+
+        <script>
+            async function send(method, url, body) {
+                const res = await fetch(url, {
+                    method: method,
+                    headers: { "Content-Type": "application/json" },
+                    body: body ? JSON.stringify(body) : undefined
+                });
+                if (!res.ok) {
+                    alert("Fehler: " + (await res.text()));
+                    return;
+                }
+                location.reload();
+            }
+
+            document.getElementById("credForm").addEventListener("submit", (e) => {
+                e.preventDefault();
+                const f = e.target;
+                send("POST", "/api/credentials", {
+                    scope: f.scope.value,
+                    user: f.user.value,
+                    password: f.password.value
+                });
+            });
+
+            document.querySelectorAll("button[data-del]").forEach(btn => {
+                btn.addEventListener("click", () => {
+                    if (!confirm("Eintrag " + btn.dataset.del + " löschen?")) return;
+                    send("DELETE", "/api/credentials?scope=" + encodeURIComponent(btn.dataset.del));
+                });
+            });
+        </script>
+    </body>
+    </html>
+`)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadRouteCredentialsWithoutKey(t *testing.T) {
+	store = newJSONStorage(t.TempDir())
+
+	t.Setenv(credentialKeyEnv, "")
+	if err := loadRouteCredentials(); err != nil {
+		t.Fatalf("empty store without key: %v", err)
+	}
+
+	t.Setenv(credentialKeyEnv, "test-passphrase")
+	if err := loadRouteCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	credentialMutex.Lock()
+	creds := copyRouteCredentialsLocked()
+	creds[credentialScopeDefault] = RouteCredential{User: "Administrator", Password: "geheim"}
+	err := saveRouteCredentialsLocked(creds)
+	credentialMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(credentialKeyEnv, "")
+	err = loadRouteCredentials()
+	if err == nil || !strings.Contains(err.Error(), credentialKeyEnv) {
+		t.Errorf("stored credentials without key: err = %v, want startup error", err)
+	}
+
+	t.Setenv(credentialKeyEnv, "test-passphrase")
+	if err := loadRouteCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := routeCredentialFor("00:01:05:00:00:01", ""); c.Password != "geheim" {
+		t.Errorf("routeCredentialFor = %+v, want stored default", c)
+	}
+}
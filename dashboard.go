@@ -3,18 +3,35 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"slices"
 	"sort"
+	"strings"
 	"text/template"
 	"time"
 )
 
 // DashboardStats enthält die Kennzahlen, die oberhalb der Tabelle angezeigt werden.
 type DashboardStats struct {
-	Online  int // Anzahl aktuell erreichbarer Geräte
-	Offline int // Anzahl aktuell nicht erreichbarer Geräte
-	Known   int // Anzahl "erkannter" Geräte mit mindestens einem Merkmal
+	Online  int `json:"online"`  // Anzahl aktuell erreichbarer Geräte
+	Offline int `json:"offline"` // Anzahl aktuell nicht erreichbarer Geräte
+	Known   int `json:"known"`   // Anzahl "erkannter" Geräte mit mindestens einem Merkmal
+}
+
+// add zählt ein Gerät in die Statistik ein.
+func (s *DashboardStats) add(dev *IPC) {
+	if dev.IsReachable {
+		s.Online++
+	} else {
+		s.Offline++
+	}
+
+	// Ein Gerät gilt als "erkannt", wenn mindestens ein Identitätsmerkmal bekannt ist.
+	if dev.MACAddress != "" || dev.Hostname != "" || dev.AmsNetID != "" {
+		s.Known++
+	}
 }
 
 // DashboardModel ist das komplette ViewModel für das Dashboard.
@@ -22,6 +39,7 @@ type DashboardModel struct {
 	LastUpdateStr string // Zeitstempel für die Header-Anzeige "Refreshed"
 	Devices       []*IPC // sortierte Geräteliste für die Tabelle
 	Stats         DashboardStats
+	User          authUser // angemeldeter Benutzer (leer ohne Anmeldung)
 }
 
 //
@@ -73,6 +91,9 @@ func formatRelativeTime(t time.Time) string {
 // 1. Geräte aus dem Inventory unter Lock kopieren
 // 2. Statistik berechnen
 // 3. Geräte für die Anzeige sortieren
+//
+// Die Geräte werden als Kopie übernommen, damit Dashboard und REST-API
+// nach dem Unlock nicht mehr mit den laufenden Scan-Workern konkurrieren.
 func buildDashboardModel() DashboardModel {
 	lastUpdateStr := time.Now().Format("15:04:05")
 
@@ -81,19 +102,16 @@ func buildDashboardModel() DashboardModel {
 	var devices []*IPC
 	stats := DashboardStats{}
 
-	for _, dev := range inventory {
-		devices = append(devices, dev)
-
-		if dev.IsReachable {
-			stats.Online++
-		} else {
-			stats.Offline++
+	for _, live := range inventory {
+		if live == nil {
+			continue
 		}
 
-		// Ein Gerät gilt als "erkannt", wenn mindestens ein Identitätsmerkmal bekannt ist.
-		if dev.MACAddress != "" || dev.Hostname != "" || dev.AmsNetID != "" {
-			stats.Known++
-		}
+		dev := new(IPC)
+		*dev = *live
+		devices = append(devices, dev)
+
+		stats.add(dev)
 	}
 
 	inventoryMutex.Unlock()
@@ -178,6 +196,7 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
                 font-weight: 600;
             }
             .btn-reset:hover { background-color: #e2e2e2; }
+            .user-info { color: #555; font-size: 0.9em; margin-left: 6px; }
 
             .col-resizer {
                 position: absolute;
@@ -217,6 +236,24 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
                 cursor: default;
             }
 
+            .rescan-btn {
+                background: transparent;
+                border: 0;
+                padding: 0;
+                cursor: pointer;
+                font-size: 1.05em;
+                color: #0078d4;
+                line-height: 1;
+            }
+
+            .rescan-btn:hover { transform: scale(1.15); }
+
+            .rescan-btn.running {
+                opacity: 0.4;
+                cursor: wait;
+                pointer-events: none;
+            }
+
             /* kompakte Standard-Spaltenbreiten */
             th[data-col="fav"],
             td[data-col="fav"] {
@@ -257,6 +294,15 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
                 background: white;
             }
 
+            .state-action {
+                margin-left: 6px;
+                font-size: 0.8em;
+                padding: 1px 2px;
+                border: 1px solid #ddd;
+                border-radius: 4px;
+                background: white;
+            }
+
             th[data-col="comment"],
             td[data-col="comment"] {
                 width: 260px;
@@ -356,6 +402,32 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
                 font-size: 0.9em;
             }
             .stat-item { color: #333; }
+            .stat-last-scan { margin-left: auto; color: #666; }
+
+            /* Scan-Fortschritt */
+            .scan-progress {
+                display: none;
+                align-items: center;
+                gap: 12px;
+                margin: 0 0 12px 0;
+                font-size: 0.85em;
+                color: #333;
+            }
+            .scan-progress.active { display: flex; }
+            .scan-progress-track {
+                flex-grow: 1;
+                height: 8px;
+                background: #eee;
+                border-radius: 4px;
+                overflow: hidden;
+            }
+            .scan-progress-bar {
+                width: 0%;
+                height: 100%;
+                background: #ce1126;
+                transition: width 0.3s;
+            }
+            .scan-progress-text { white-space: nowrap; min-width: 260px; text-align: right; }
             .stat-online { color: #28a745; font-weight: 600; }
             .stat-offline { color: #999; font-weight: 600; }
 
@@ -391,13 +463,32 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
                     row.style.display = rowText.includes(filter) || office.includes(filter) ? "" : "none";
                 });
             }
+            // Export mit denselben Filtern wie die Tabelle (Suche, Favoriten)
+            function exportDevices() {
+                const params = new URLSearchParams();
+                params.set("format", document.getElementById("exportFormat").value);
+
+                const q = document.getElementById("searchInput").value.trim();
+                if (q) params.set("q", q);
+
+                if (isFavoriteFilterActive()) {
+                    const favs = loadFavorites();
+                    params.set("fav", Object.keys(favs).filter(k => favs[k]).join(","));
+                }
+
+                location.href = "/api/export?" + params.toString();
+            }
+
              function startScan() {
                 const btn = document.getElementById("scanBtn");
                 btn.disabled = true;
                 btn.innerText = "Scanning...";
             
-                fetch('/trigger-scan').then(() => {
-                    waitForScanToFinish();
+                fetch('/trigger-scan', {method: 'POST'}).then(() => {
+                    // Mit Live-Updates (app.js) aktualisiert sich die Tabelle selbst
+                    if (!window.liveUpdatesActive) {
+                        waitForScanToFinish();
+                    }
                 });
             }
             function waitForScanToFinish() {
@@ -442,7 +533,49 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
                     <button id="scanBtn" class="btn-scan" onclick="startScan()">Scan</button>
                     <button class="btn-reset" onclick="resetColumns()" title="Spaltenlayout zurücksetzen">Reset</button>
                     <button id="favFilterBtn" class="btn-reset" onclick="toggleFavoriteFilter()" title="Nur Favoriten anzeigen">Nur Favoriten</button>
-                </div>
+                    <select id="exportFormat" class="btn-reset" title="Format für den Export">
+                        <option value="xlsx">Excel</option>
+                        <option value="csv">CSV</option>
+                        <option value="json">JSON</option>
+                    </select>
+                    <button class="btn-reset" onclick="exportDevices()" title="Aktuell gefilterte Geräteliste herunterladen">Export</button>
+`)
+
+	// Import ändert Zuordnungen, daher erst ab Rolle editor
+	if roleLevels[m.User.Role] >= roleLevels[roleEditor] {
+		fmt.Fprint(w, `
+                    <button class="btn-reset" onclick="location.href='/import'" title="Büros und Kommentare aus CSV übernehmen">Import</button>
+`)
+	}
+
+	fmt.Fprint(w, `
+                    <button class="btn-reset" onclick="location.href='/floorplan'" title="Geräte auf dem Grundriss finden">Lageplan</button>
+                    <button class="btn-reset" onclick="location.href='/offices'" title="Büros und Räume">Büros</button>
+`)
+
+	// Verwaltungsseiten nur für Administratoren anbieten
+	// (ohne Anmeldung, auth = false, gilt jeder als admin)
+	if m.User.Role == roleAdmin {
+		fmt.Fprint(w, `
+                    <button class="btn-reset" onclick="location.href='/audit'" title="Wer hat wann was geändert">Audit</button>
+                    <button class="btn-reset" onclick="location.href='/credentials'" title="Zugangsdaten für ADS-Routen verwalten">Routen-Login</button>
+                    <button class="btn-reset" onclick="location.href='/alerts'" title="Alarm-Regeln verwalten">Alarme</button>
+`)
+		if cfg.Auth {
+			fmt.Fprint(w, `
+                    <button class="btn-reset" onclick="location.href='/users'" title="Benutzer und Rollen verwalten">Benutzer</button>
+`)
+		}
+	}
+
+	if m.User.Name != "" {
+		fmt.Fprintf(w, `
+                    <span class="user-info" title="Rolle: %s">%s</span>
+                    <button class="btn-reset" onclick="location.href='/logout'">Abmelden</button>
+`, template.HTMLEscapeString(m.User.Role), template.HTMLEscapeString(m.User.Name))
+	}
+
+	fmt.Fprint(w, `                </div>
             </div>
 `)
 
@@ -453,9 +586,14 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
 	//
 	fmt.Fprintf(w, `
             <div class="stats-bar">
-                <div class="stat-item">Erkannt: <strong>%d</strong></div>
-                <div class="stat-item stat-online">Online: <strong>%d</strong></div>
-                <div class="stat-item stat-offline">Offline: <strong>%d</strong></div>
+                <div class="stat-item">Erkannt: <strong id="statKnown">%d</strong></div>
+                <div class="stat-item stat-online">Online: <strong id="statOnline">%d</strong></div>
+                <div class="stat-item stat-offline">Offline: <strong id="statOffline">%d</strong></div>
+                <div class="stat-item stat-last-scan" id="lastScanInfo"></div>
+            </div>
+            <div class="scan-progress" id="scanProgress">
+                <div class="scan-progress-track"><div class="scan-progress-bar" id="scanProgressBar"></div></div>
+                <span class="scan-progress-text" id="scanProgressText"></span>
             </div>
 `,
 		m.Stats.Known,
@@ -482,6 +620,7 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
     <col data-col="ams">
     <col data-col="twincat">
     <col data-col="runtime">
+    <col data-col="plc">
     <col data-col="lastonline">
   </colgroup>
   <thead>
@@ -497,6 +636,7 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
       <th data-col="ams" draggable="true">AMS Net-ID<span class="col-resizer"></span></th>
       <th data-col="twincat" draggable="true">TwinCAT<span class="col-resizer"></span></th>
       <th data-col="runtime" draggable="true">TC State<span class="col-resizer"></span></th>
+      <th data-col="plc" draggable="true">PLC-Runtimes<span class="col-resizer"></span></th>
       <th data-col="lastonline" draggable="true">Zuletzt online<span class="col-resizer"></span></th>
     </tr>
   </thead>
@@ -509,141 +649,223 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
 	// --------------------------------------------------------
 	//
 	for _, device := range m.Devices {
-		//
-		// --------------------------------------------
-		// Letzte Online-Zeit aufbereiten
-		// --------------------------------------------
-		//
-		var lastSeenStr string
-
-		if device.IsReachable {
-			lastSeenStr = "<span style='color:#28a745;font-weight:bold;'>Jetzt</span>"
-		} else if !device.LastSeenOnline.IsZero() {
-			relative := formatRelativeTime(device.LastSeenOnline)
-			absolute := device.LastSeenOnline.Format("02.01.2006 15:04:05")
-
-			lastSeenStr = fmt.Sprintf(`
+		renderDeviceRow(w, device, m.User.Role == roleAdmin)
+	}
+
+	//
+	// --------------------------------------------------------
+	// 5) HTML sauber schließen
+	// --------------------------------------------------------
+	//
+	fmt.Fprintf(w, `
+                </tbody>
+            </table>
+        </div>
+
+        <script src="/static/app.js?v=%d"></script>
+    </body>
+    </html>
+`, time.Now().Unix())
+}
+
+// renderDeviceRow schreibt die Tabellenzeile eines Geräts.
+//
+// Wird vom Dashboard und von den Live-Updates (siehe events.go)
+// verwendet, damit beide exakt dasselbe HTML erzeugen.
+//
+// admin: Auswahl zur State-Steuerung anbieten. /api/devices/{id}/state
+// ist nur für Administratoren erlaubt, andere Rollen sehen sie nicht.
+func renderDeviceRow(w io.Writer, device *IPC, admin bool) {
+	//
+	// --------------------------------------------
+	// Letzte Online-Zeit aufbereiten
+	// --------------------------------------------
+	//
+	var lastSeenStr string
+
+	if device.IsReachable {
+		lastSeenStr = "<span style='color:#28a745;font-weight:bold;'>Jetzt</span>"
+	} else if !device.LastSeenOnline.IsZero() {
+		relative := formatRelativeTime(device.LastSeenOnline)
+		absolute := device.LastSeenOnline.Format("02.01.2006 15:04:05")
+
+		lastSeenStr = fmt.Sprintf(`
         <div style="line-height:1.2">
             <div>%s</div>
             <div style="font-size:0.75em;color:#888;">(%s)</div>
         </div>
     `, relative, absolute)
-		} else {
-			lastSeenStr = "-"
-		}
-
-		//
-		// --------------------------------------------
-		// Online/Offline-Anzeige
-		// --------------------------------------------
-		//
-		statusClass, statusText := "status-offline", "Offline"
-		if device.IsReachable {
-			statusClass, statusText = "status-online", "Online"
-		}
+	} else {
+		lastSeenStr = "-"
+	}
 
-		//
-		// --------------------------------------------
-		// RDP-Button
-		// --------------------------------------------
-		//
-		// Nur bei erreichbaren Geräten aktiv
-		rdpButton := `<span class="rdp-icon disabled">🖥</span>`
-		if device.IsReachable {
-			rdpButton = fmt.Sprintf(
-				`<a href="beckhoff-rdp://%s" class="rdp-icon" title="RDP öffnen">🖥</a>`,
-				device.IP,
-			)
-		}
+	//
+	// --------------------------------------------
+	// Online/Offline-Anzeige
+	// --------------------------------------------
+	//
+	statusClass, statusText := "status-offline", "Offline"
+	if device.IsReachable {
+		statusClass, statusText = "status-online", "Online"
+	}
 
-		//
-		// --------------------------------------------
-		// Favoriten-Button
-		// --------------------------------------------
-		//
-		favKey := device.MACAddress
-		if favKey == "" {
-			favKey = device.IP
-		}
+	//
+	// --------------------------------------------
+	// RDP-Button
+	// --------------------------------------------
+	//
+	// Nur bei erreichbaren Geräten aktiv
+	rdpButton := `<span class="rdp-icon disabled">🖥</span>`
+	if device.IsReachable {
+		rdpButton = fmt.Sprintf(
+			`<a href="beckhoff-rdp://%s" class="rdp-icon" title="RDP öffnen">🖥</a>`,
+			device.IP,
+		)
+	}
 
-		favCell := fmt.Sprintf(
-			`<button class="fav-btn" data-fav="%s" title="Favorit umschalten">☆</button>`,
-			favKey,
+	//
+	// --------------------------------------------
+	// Verlauf-Link
+	// --------------------------------------------
+	//
+	// Die Historie ist MAC-basiert → ohne MAC kein Verlauf
+	historyLink := `<span class="rdp-icon disabled">🕘</span>`
+	if device.MACAddress != "" {
+		historyLink = fmt.Sprintf(
+			`<a href="/history?id=%s" class="rdp-icon" title="Verlauf anzeigen">🕘</a>`,
+			device.MACAddress,
 		)
+	}
 
-		//
-		// --------------------------------------------
-		// Büro-Dropdown auf Basis der zentralen validOffices-Liste
-		// --------------------------------------------
-		//
-		officeOptions := append([]string{""}, validOffices...)
+	//
+	// --------------------------------------------
+	// Einzel-Rescan
+	// --------------------------------------------
+	//
+	rescanButton := fmt.Sprintf(
+		`<button class="rescan-btn" data-id="%s" title="Gerät jetzt neu scannen">⟳</button>`,
+		device.IP,
+	)
 
-		officeSelect := `<select class="office-select" data-mac="` + device.MACAddress + `">`
+	//
+	// --------------------------------------------
+	// Favoriten-Button
+	// --------------------------------------------
+	//
+	favKey := device.MACAddress
+	if favKey == "" {
+		favKey = device.IP
+	}
 
-		for _, office := range officeOptions {
-			selected := ""
-			if device.Office == office {
-				selected = ` selected`
-			}
+	favCell := fmt.Sprintf(
+		`<button class="fav-btn" data-fav="%s" title="Favorit umschalten">☆</button>`,
+		favKey,
+	)
 
-			label := office
-			if office == "" {
-				label = "-"
-			}
+	//
+	// --------------------------------------------
+	// Büro-Dropdown auf Basis des Büro-Katalogs
+	// --------------------------------------------
+	//
+	officeOptions := append([]string{""}, officeNames()...)
 
-			officeSelect += `<option value="` + office + `"` + selected + `>` + label + `</option>`
-		}
+	// Zuordnung auf ein nicht (mehr) katalogisiertes Büro trotzdem anzeigen
+	if device.Office != "" && !slices.Contains(officeOptions, device.Office) {
+		officeOptions = append(officeOptions, device.Office)
+	}
 
-		officeSelect += `</select>`
+	officeSelect := `<select class="office-select" data-mac="` + device.MACAddress + `">`
 
-		// Ohne MAC kann keine Zuordnung gespeichert werden → Dropdown deaktivieren
-		if device.MACAddress == "" {
-			officeSelect = `<select class="office-select" disabled><option>-</option></select>`
+	for _, office := range officeOptions {
+		selected := ""
+		if device.Office == office {
+			selected = ` selected`
 		}
 
-		//
-		// --------------------------------------------
-		// Kommentar-Feld
-		// --------------------------------------------
-		//
-		commentInput := `<textarea class="comment-input" data-mac="` + device.MACAddress +
-			`" title="` + template.HTMLEscapeString(device.Comment) +
-			`" placeholder="Kommentar...">` +
-			template.HTMLEscapeString(device.Comment) + `</textarea>`
-
-		if device.MACAddress == "" {
-			commentInput = `<textarea class="comment-input" placeholder="Kommentar..." disabled></textarea>`
+		label := office
+		if office == "" {
+			label = "-"
 		}
 
-		//
-		// --------------------------------------------
-		// Runtime-/TwinCAT-State farblich markieren
-		// --------------------------------------------
-		//
-		runtimeClass := ""
-
-		if !device.IsReachable && device.RuntimeStatus != "" {
-			runtimeClass = "runtime-offline"
-		} else {
-			switch device.RuntimeStatus {
-			case "RUN":
-				runtimeClass = "runtime-run"
-			case "STOP":
-				runtimeClass = "runtime-stop"
-			case "CONFIG":
-				runtimeClass = "runtime-config"
-			}
+		officeSelect += `<option value="` + template.HTMLEscapeString(office) + `"` + selected + `>` +
+			template.HTMLEscapeString(label) + `</option>`
+	}
+
+	officeSelect += `</select>`
+
+	// Ohne MAC kann keine Zuordnung gespeichert werden → Dropdown deaktivieren
+	if device.MACAddress == "" {
+		officeSelect = `<select class="office-select" disabled><option>-</option></select>`
+	}
+
+	//
+	// --------------------------------------------
+	// Kommentar-Feld
+	// --------------------------------------------
+	//
+	commentInput := `<textarea class="comment-input" data-mac="` + device.MACAddress +
+		`" title="` + template.HTMLEscapeString(device.Comment) +
+		`" placeholder="Kommentar...">` +
+		template.HTMLEscapeString(device.Comment) + `</textarea>`
+
+	if device.MACAddress == "" {
+		commentInput = `<textarea class="comment-input" placeholder="Kommentar..." disabled></textarea>`
+	}
+
+	//
+	// --------------------------------------------
+	// Runtime-/TwinCAT-State farblich markieren
+	// --------------------------------------------
+	//
+	runtimeClass := ""
+
+	if !device.IsReachable && device.RuntimeStatus != "" {
+		runtimeClass = "runtime-offline"
+	} else {
+		switch device.RuntimeStatus {
+		case "RUN":
+			runtimeClass = "runtime-run"
+		case "STOP":
+			runtimeClass = "runtime-stop"
+		case "CONFIG":
+			runtimeClass = "runtime-config"
 		}
+	}
+
+	//
+	// --------------------------------------------
+	// PLC-Runtimes (eine Zeile pro Runtime)
+	// --------------------------------------------
+	//
+	plcCell := renderPlcRuntimes(device.Runtimes)
+
+	// Auswahl für RUN/CONFIG/STOP/Neustart
+	// (nur für Admins und nur bei erreichbaren ADS-Geräten)
+	stateControl := ""
+	if admin && device.IsReachable && device.AmsNetID != "" {
+		stateControl = renderStateControl(device)
+	}
+
+	// Hinweis in der TC-State-Spalte, wenn einzelne Runtimes
+	// nicht laufen (z. B. System RUN, aber PLC 852 in STOP).
+	runtimeHint := ""
+	if n := runtimesNotRunning(device.Runtimes); n > 0 && device.IsReachable {
+		runtimeHint = fmt.Sprintf(
+			`<br><span class="runtime-stop" title="%s">⚠ %d/%d PLC nicht in RUN</span>`,
+			template.HTMLEscapeString(runtimesSummary(device.Runtimes)),
+			n, len(device.Runtimes),
+		)
+	}
 
-		//
-		// --------------------------------------------
-		// Tabellenzeile rendern
-		// --------------------------------------------
-		//
-		fmt.Fprintf(w, `<tr data-office="%s">
+	//
+	// --------------------------------------------
+	// Tabellenzeile rendern
+	// --------------------------------------------
+	//
+	fmt.Fprintf(w, `<tr data-ip="%s" data-office="%s">
   <td data-col="fav" class="fav-cell">%s</td>
   <td data-col="status" class="%s">%s</td>
-  <td data-col="ip"><div class="ip-cell">%s<strong>%s</strong></div></td>
+  <td data-col="ip"><div class="ip-cell">%s%s%s<strong>%s</strong></div></td>
   <td data-col="hostname">%s</td>
   <td data-col="office">%s</td>
   <td data-col="comment">%s</td>
@@ -651,37 +873,99 @@ func renderDashboard(w http.ResponseWriter, m DashboardModel) {
   <td data-col="os">%s</td>
   <td data-col="ams">%s</td>
   <td data-col="twincat">%s</td>
-  <td data-col="runtime"><span class="%s">%s</span></td>
+  <td data-col="runtime"><span class="%s">%s</span>%s%s</td>
+  <td data-col="plc">%s</td>
   <td data-col="lastonline">%s</td>
 </tr>`,
-			device.Office,
-			favCell,
-			statusClass, statusText,
-			rdpButton, device.IP,
-			device.Hostname,
-			officeSelect,
-			commentInput,
-			device.MACAddress,
-			device.OSVersion,
-			device.AmsNetID,
-			device.TwinCATVersion,
-			runtimeClass, device.RuntimeStatus,
-			lastSeenStr,
-		)
+		device.IP, template.HTMLEscapeString(device.Office),
+		favCell,
+		statusClass, statusText,
+		rdpButton, historyLink, rescanButton, device.IP,
+		template.HTMLEscapeString(device.Hostname),
+		officeSelect,
+		commentInput,
+		template.HTMLEscapeString(device.MACAddress),
+		template.HTMLEscapeString(device.OSVersion),
+		template.HTMLEscapeString(device.AmsNetID),
+		template.HTMLEscapeString(device.TwinCATVersion),
+		runtimeClass, template.HTMLEscapeString(device.RuntimeStatus), stateControl, runtimeHint,
+		plcCell,
+		lastSeenStr,
+	)
+}
+
+// renderPlcRuntimes erzeugt den Zelleninhalt der Spalte "PLC-Runtimes".
+//
+// Pro Runtime eine Zeile "Projektname (Port, State)",
+// Details (App, Boot-Projekt, Compile-Zeit, Fehler) stehen im Tooltip.
+func renderPlcRuntimes(runtimes []PlcRuntime) string {
+	var parts []string
+
+	for _, p := range runtimes {
+		name := p.ProjectName
+		if name == "" {
+			name = "?"
+		}
+
+		stateClass := ""
+		switch p.State {
+		case "RUN":
+			stateClass = "runtime-run"
+		case "STOP":
+			stateClass = "runtime-stop"
+		case "CONFIG":
+			stateClass = "runtime-config"
+		}
+
+		title := fmt.Sprintf("Port %d", p.Port)
+		if p.Err != "" {
+			title += "\nFehler: " + p.Err
+		}
+		if p.AppName != "" {
+			title += "\nApp: " + p.AppName
+		}
+		if p.DeviceName != "" {
+			title += "\n" + p.DeviceName + " " + p.DeviceVersion
+		}
+		if p.Err == "" {
+			title += "\n" + plcBootLabel(p)
+		}
+		if !p.CompileTime.IsZero() {
+			title += "\nKompiliert: " + p.CompileTime.Format("02.01.2006 15:04:05")
+		}
+
+		parts = append(parts, fmt.Sprintf(
+			`<span title="%s">%s (%d, <span class="%s">%s</span>)</span>`,
+			template.HTMLEscapeString(title),
+			template.HTMLEscapeString(name),
+			p.Port,
+			stateClass, template.HTMLEscapeString(p.State),
+		))
 	}
 
-	//
-	// --------------------------------------------------------
-	// 5) HTML sauber schließen
-	// --------------------------------------------------------
-	//
-	fmt.Fprintf(w, `
-                </tbody>
-            </table>
-        </div>
+	return strings.Join(parts, "<br>")
+}
 
-        <script src="/static/app.js?v=%d"></script>
-    </body>
-    </html>
-`, time.Now().Unix())
+// renderStateControl erzeugt die Auswahl zum Umschalten des TwinCAT-States.
+//
+// Der Wert einer Option ist "<aktion>:<port>", die Abfrage mit
+// Bestätigungsdialog und der POST an /api/devices/{id}/state
+// passieren in app.js (enableStateControl).
+func renderStateControl(device *IPC) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<select class="state-action" data-id="%s" title="TwinCAT-State ändern">`, device.IP)
+	b.WriteString(`<option value="">⚙</option>`)
+	fmt.Fprintf(&b, `<option value="run:%d">System: RUN (Neustart)</option>`, routeTcSystemService)
+	fmt.Fprintf(&b, `<option value="config:%d">System: CONFIG</option>`, routeTcSystemService)
+
+	for _, rt := range device.Runtimes {
+		fmt.Fprintf(&b, `<option value="run:%d">PLC %d: Start</option>`, rt.Port, rt.Port)
+		fmt.Fprintf(&b, `<option value="stop:%d">PLC %d: Stop</option>`, rt.Port, rt.Port)
+		fmt.Fprintf(&b, `<option value="restart:%d">PLC %d: Neustart</option>`, rt.Port, rt.Port)
+	}
+
+	b.WriteString(`</select>`)
+
+	return b.String()
 }
@@ -143,6 +143,15 @@ func migrateDeviceLocked(oldIP, newIP string) {
 		newDev.TwinCATVersion = oldDev.TwinCATVersion
 	}
 
+	if newDev.DeviceName == "" {
+		newDev.DeviceName = oldDev.DeviceName
+		newDev.DeviceVersion = oldDev.DeviceVersion
+	}
+
+	if newDev.Runtimes == nil {
+		newDev.Runtimes = oldDev.Runtimes
+	}
+
 	//
 	// Runtime-Status:
 	// Nur übernehmen, wenn der neue Zustand noch leer
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Live-Updates per Server-Sent Events
+// ------------------------------------------------------------
+//
+// GET /api/events hält die Verbindung offen und schickt Änderungen,
+// sobald sie im Inventory passieren. Das Dashboard tauscht damit
+// einzelne Tabellenzeilen aus, statt nach dem Scan neu zu laden.
+//
+// Events:
+//
+//	device  {"ip":"172.17.76.23","html":"<tr ...>...</tr>"}
+//	        {"ip":"172.17.76.23","removed":true}
+//	stats   {"online":12,"offline":240,"known":30}
+//	scan    wie /api/scan-status (siehe ScanStatus in scan_state.go)
+//
+// Die Zeile wird serverseitig mit renderDeviceRow gerendert,
+// damit Live-Update und Seitenaufbau identisch aussehen. Admins
+// bekommen die Zeile mit State-Steuerung, alle anderen ohne.
+//
+// Langsame Clients verpassen Events lieber, als den Scan zu bremsen:
+// ist der Puffer eines Clients voll, wird das Event für ihn verworfen.
+
+// sseEvent ist ein einzelnes Event für alle verbundenen Clients.
+type sseEvent struct {
+	Name string
+	Data []byte
+}
+
+// deviceEvent ist der Inhalt eines "device"-Events.
+type deviceEvent struct {
+	IP      string `json:"ip"`
+	HTML    string `json:"html,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+}
+
+const (
+	// Puffer pro Client
+	sseClientBuffer = 256
+
+	// Kommentarzeile, damit Proxys die Verbindung nicht schließen
+	sseKeepAlive = 25 * time.Second
+
+	// Sammelzeit für die Statistik: während des Scans kommen
+	// Zeilen-Updates pro IP, die Statistik wird nur einmal
+	// je Intervall über das ganze Inventory gezählt
+	sseStatsDelay = 500 * time.Millisecond
+)
+
+var (
+	// eventClients enthält die Kanäle aller verbundenen Clients;
+	// Wert true = Admin (Zeilen mit State-Steuerung).
+	eventClients = make(map[chan sseEvent]bool)
+
+	// lastDeviceRows merkt sich das zuletzt gesendete HTML je IP
+	// (Admin-Variante), damit unveränderte Zeilen nicht erneut
+	// verschickt werden.
+	lastDeviceRows = make(map[string]string)
+
+	// lastStats ist die zuletzt gesendete Statistik.
+	lastStats DashboardStats
+
+	// statsPending: publishStats ist bereits eingeplant.
+	statsPending bool
+
+	// eventsMutex schützt eventClients, lastDeviceRows, lastStats
+	// und statsPending.
+	eventsMutex sync.Mutex
+)
+
+// subscribeEvents meldet einen neuen Client an.
+func subscribeEvents(admin bool) chan sseEvent {
+	ch := make(chan sseEvent, sseClientBuffer)
+
+	eventsMutex.Lock()
+	eventClients[ch] = admin
+	eventsMutex.Unlock()
+
+	return ch
+}
+
+// unsubscribeEvents meldet einen Client ab.
+func unsubscribeEvents(ch chan sseEvent) {
+	eventsMutex.Lock()
+	delete(eventClients, ch)
+	eventsMutex.Unlock()
+}
+
+// hasEventClients meldet, ob überhaupt jemand zuhört.
+// So wird ohne offene Dashboards nichts gerendert.
+func hasEventClients() bool {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	return len(eventClients) > 0
+}
+
+// broadcastLocked verteilt ein Event an alle Clients.
+// Aufrufer muss eventsMutex halten.
+func broadcastLocked(name string, v any) {
+	broadcastRoleLocked(name, v, v)
+}
+
+// broadcastRoleLocked verteilt ein Event, das für Admins anders aussieht.
+// Aufrufer muss eventsMutex halten.
+func broadcastRoleLocked(name string, v, adminV any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println("SSE encode error:", err)
+		return
+	}
+	adminData, err := json.Marshal(adminV)
+	if err != nil {
+		fmt.Println("SSE encode error:", err)
+		return
+	}
+
+	ev := sseEvent{Name: name, Data: data}
+	adminEv := sseEvent{Name: name, Data: adminData}
+	for ch, admin := range eventClients {
+		e := ev
+		if admin {
+			e = adminEv
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// publishDevices schickt die aktuellen Zeilen der angegebenen IPs.
+// Die Statistik wird gesammelt nachgereicht (scheduleStatsLocked).
+//
+// Nicht (mehr) vorhandene IPs werden als "removed" gemeldet,
+// z. B. nach einer MAC-Deduplizierung.
+func publishDevices(ips ...string) {
+	if !hasEventClients() {
+		return
+	}
+
+	// Unter dem Lock nur kopieren, gerendert wird danach
+	devs := make(map[string]*IPC, len(ips))
+
+	inventoryMutex.Lock()
+	for _, ip := range ips {
+		dev, ok := inventory[ip]
+		if !ok || dev == nil {
+			devs[ip] = nil
+			continue
+		}
+
+		cp := *dev
+		cp.Runtimes = append([]PlcRuntime(nil), dev.Runtimes...)
+		devs[ip] = &cp
+	}
+	inventoryMutex.Unlock()
+
+	// je IP: Zeile ohne und mit State-Steuerung
+	rows := make(map[string][2]string, len(devs))
+	for ip, dev := range devs {
+		if dev == nil {
+			rows[ip] = [2]string{}
+			continue
+		}
+
+		var buf, adminBuf bytes.Buffer
+		renderDeviceRow(&buf, dev, false)
+		renderDeviceRow(&adminBuf, dev, true)
+		rows[ip] = [2]string{buf.String(), adminBuf.String()}
+	}
+
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	for ip, row := range rows {
+		html, adminHTML := row[0], row[1]
+		prev, known := lastDeviceRows[ip]
+
+		if adminHTML == "" {
+			if known {
+				delete(lastDeviceRows, ip)
+				broadcastLocked("device", deviceEvent{IP: ip, Removed: true})
+			}
+			continue
+		}
+
+		if prev == adminHTML {
+			continue
+		}
+
+		lastDeviceRows[ip] = adminHTML
+		broadcastRoleLocked("device", deviceEvent{IP: ip, HTML: html}, deviceEvent{IP: ip, HTML: adminHTML})
+	}
+
+	scheduleStatsLocked()
+}
+
+// scheduleStatsLocked plant publishStats ein, falls noch nicht geschehen.
+// Aufrufer muss eventsMutex halten.
+func scheduleStatsLocked() {
+	if statsPending {
+		return
+	}
+	statsPending = true
+	time.AfterFunc(sseStatsDelay, publishStats)
+}
+
+// publishStats zählt die Statistik einmal über das Inventory
+// und schickt sie, falls sie sich geändert hat.
+func publishStats() {
+	eventsMutex.Lock()
+	statsPending = false
+	eventsMutex.Unlock()
+
+	var stats DashboardStats
+
+	inventoryMutex.Lock()
+	for _, dev := range inventory {
+		if dev != nil {
+			stats.add(dev)
+		}
+	}
+	inventoryMutex.Unlock()
+
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	if stats != lastStats {
+		lastStats = stats
+		broadcastLocked("stats", stats)
+	}
+}
+
+// publishAllDevices gleicht alle Zeilen ab, inkl. entfernter Geräte.
+// Wird am Ende eines Scans aufgerufen.
+func publishAllDevices() {
+	if !hasEventClients() {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var ips []string
+
+	inventoryMutex.Lock()
+	for ip := range inventory {
+		seen[ip] = true
+		ips = append(ips, ip)
+	}
+	inventoryMutex.Unlock()
+
+	eventsMutex.Lock()
+	for ip := range lastDeviceRows {
+		if !seen[ip] {
+			ips = append(ips, ip)
+		}
+	}
+	eventsMutex.Unlock()
+
+	publishDevices(ips...)
+}
+
+// publishDevicesByMAC schickt alle Zeilen mit der angegebenen MAC
+// (nach Büro-/Kommentar-Änderungen).
+func publishDevicesByMAC(mac string) {
+	var ips []string
+
+	inventoryMutex.Lock()
+	for ip, dev := range inventory {
+		if dev != nil && normalizeMAC(dev.MACAddress) == mac {
+			ips = append(ips, ip)
+		}
+	}
+	inventoryMutex.Unlock()
+
+	publishDevices(ips...)
+}
+
+// publishScanStatus meldet den aktuellen Scan-Fortschritt.
+func publishScanStatus() {
+	if !hasEventClients() {
+		return
+	}
+
+	st := getScanStatus()
+
+	eventsMutex.Lock()
+	broadcastLocked("scan", st)
+	eventsMutex.Unlock()
+}
+
+// ------------------------------------------------------------
+// HTTP-Handler
+// ------------------------------------------------------------
+
+// handleEvents liefert den SSE-Stream für GET /api/events.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := subscribeEvents(requestUser(r).Role == roleAdmin)
+	defer unsubscribeEvents(ch)
+
+	// Aktuellen Scan-Zustand direkt mitgeben,
+	// damit ein neu geöffnetes Dashboard einen laufenden Scan anzeigt.
+	if data, err := json.Marshal(getScanStatus()); err == nil {
+		fmt.Fprintf(w, "event: scan\ndata: %s\n\n", data)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case ev := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Name, ev.Data)
+			flusher.Flush()
+		}
+	}
+}
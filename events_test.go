@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPublishDevicesBatchesStats(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	inventoryMutex.Lock()
+	for i := 1; i <= 6; i++ {
+		ip := fmt.Sprintf("10.99.0.%d", i)
+		inventory[ip] = &IPC{IP: ip, IsReachable: i%2 == 0}
+	}
+	inventoryMutex.Unlock()
+
+	eventsMutex.Lock()
+	lastDeviceRows = make(map[string]string)
+	lastStats = DashboardStats{}
+	eventsMutex.Unlock()
+
+	ch := subscribeEvents(false)
+	defer unsubscribeEvents(ch)
+
+	// wie in der Ping-Phase: ein Aufruf pro IP
+	for i := 1; i <= 6; i++ {
+		publishDevices(fmt.Sprintf("10.99.0.%d", i))
+	}
+
+	rows, stats := 0, 0
+	timeout := time.After(sseStatsDelay + 2*time.Second)
+	for stats == 0 {
+		select {
+		case ev := <-ch:
+			switch ev.Name {
+			case "device":
+				rows++
+			case "stats":
+				stats++
+				if string(ev.Data) != `{"online":3,"offline":3,"known":0}` {
+					t.Errorf("stats = %s", ev.Data)
+				}
+			}
+		case <-timeout:
+			t.Fatal("no stats event")
+		}
+	}
+
+	// keine weiteren Statistik-Events für denselben Stapel
+	select {
+	case ev := <-ch:
+		if ev.Name == "stats" {
+			t.Errorf("second stats event: %s", ev.Data)
+		}
+	case <-time.After(2 * sseStatsDelay):
+	}
+
+	if rows != 6 {
+		t.Errorf("%d device events, want 6", rows)
+	}
+}
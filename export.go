@@ -0,0 +1,346 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Export der Geräteliste
+// ------------------------------------------------------------
+//
+//	GET /api/export?format=csv|xlsx|json
+//
+// Spalten wie im Dashboard. Es gelten dieselben Filter wie bei
+// /api/devices (q, office, online, twincat, runtime), zusätzlich:
+//
+//	fav=<MAC oder IP>,...   nur diese Geräte (Favoritenfilter des Dashboards)
+//
+// CSV wird mit Semikolon und UTF-8-BOM geschrieben, damit Excel
+// die Datei ohne Importdialog korrekt öffnet.
+
+const (
+	exportCSV  = "csv"
+	exportXLSX = "xlsx"
+	exportJSON = "json"
+)
+
+// exportHeader sind die Spaltenüberschriften für CSV und XLSX.
+var exportHeader = []string{
+	"IP-Adresse", "MAC-Adresse", "Hostname", "Büro", "Kommentar",
+	"OS Version", "TwinCAT", "TC State", "Zuletzt online",
+}
+
+// exportColumnWidths sind die XLSX-Spaltenbreiten (Zeichen).
+var exportColumnWidths = []float64{15, 19, 22, 10, 40, 28, 14, 14, 20}
+
+// exportRow ist ein Gerät in der Exportdatei.
+type exportRow struct {
+	IP             string     `json:"ip"`
+	MACAddress     string     `json:"mac,omitempty"`
+	Hostname       string     `json:"hostname,omitempty"`
+	Office         string     `json:"office,omitempty"`
+	Comment        string     `json:"comment,omitempty"`
+	OSVersion      string     `json:"osVersion,omitempty"`
+	TwinCATVersion string     `json:"twincatVersion,omitempty"`
+	RuntimeStatus  string     `json:"runtimeStatus,omitempty"`
+	Online         bool       `json:"online"`
+	LastSeenOnline *time.Time `json:"lastSeenOnline,omitempty"`
+}
+
+// cells liefert die Zeile in Reihenfolge von exportHeader.
+func (e exportRow) cells() []string {
+	lastOnline := ""
+	switch {
+	case e.Online:
+		lastOnline = "Jetzt"
+	case e.LastSeenOnline != nil:
+		lastOnline = e.LastSeenOnline.Format("02.01.2006 15:04:05")
+	}
+
+	return []string{
+		e.IP, e.MACAddress, e.Hostname, e.Office, e.Comment,
+		e.OSVersion, e.TwinCATVersion, e.RuntimeStatus, lastOnline,
+	}
+}
+
+// exportRows sammelt die gefilterten Geräte in Dashboard-Reihenfolge.
+func exportRows(r *http.Request) ([]exportRow, error) {
+	filter, err := parseDeviceFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// fav gesetzt (auch leer) = nur diese Geräte
+	var favs map[string]bool
+	if v, ok := r.URL.Query()["fav"]; ok {
+		favs = make(map[string]bool)
+		for _, key := range strings.Split(strings.Join(v, ","), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				favs[strings.ToUpper(key)] = true
+			}
+		}
+	}
+
+	model := buildDashboardModel()
+
+	rows := []exportRow{}
+	for _, dev := range model.Devices {
+		if !filter.match(dev) {
+			continue
+		}
+		if favs != nil && !favs[strings.ToUpper(dev.MACAddress)] && !favs[dev.IP] {
+			continue
+		}
+
+		rows = append(rows, exportRow{
+			IP:             dev.IP,
+			MACAddress:     dev.MACAddress,
+			Hostname:       dev.Hostname,
+			Office:         dev.Office,
+			Comment:        dev.Comment,
+			OSVersion:      dev.OSVersion,
+			TwinCATVersion: dev.TwinCATVersion,
+			RuntimeStatus:  dev.RuntimeStatus,
+			Online:         dev.IsReachable,
+			LastSeenOnline: optTime(dev.LastSeenOnline),
+		})
+	}
+
+	return rows, nil
+}
+
+// handleExport verarbeitet GET /api/export.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = exportCSV
+	}
+	if format != exportCSV && format != exportXLSX && format != exportJSON {
+		http.Error(w, "invalid format (csv, xlsx, json)", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := exportRows(r)
+	if err != nil {
+		http.Error(w, "invalid online filter", http.StatusBadRequest)
+		return
+	}
+
+	filename := "inventar-" + time.Now().Format("20060102-1504") + "." + format
+
+	if format == exportJSON {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+
+	table := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		table = append(table, row.cells())
+	}
+
+	// Erst puffern, damit bei einem Fehler noch ein 500 gesendet werden kann
+	var buf bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+
+	if format == exportXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = writeXLSX(&buf, "Inventar", exportHeader, exportColumnWidths, table)
+	} else {
+		err = writeExportCSV(&buf, exportHeader, table)
+	}
+
+	if err != nil {
+		fmt.Println("Export error:", err)
+		http.Error(w, "failed to create export", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Write(buf.Bytes())
+}
+
+// ------------------------------------------------------------
+// CSV
+// ------------------------------------------------------------
+
+// writeExportCSV schreibt Kopfzeile und Zeilen (Semikolon, UTF-8-BOM).
+func writeExportCSV(w io.Writer, header []string, rows [][]string) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.UseCRLF = true
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		safe := make([]string, len(row))
+		for i, cell := range row {
+			safe[i] = csvSafeCell(cell)
+		}
+		if err := cw.Write(safe); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvFormulaPrefixes sind die Anfangszeichen, vor die csvSafeCell ein '
+// setzt. Der Import (parseImportCSV) entfernt es vor denselben Zeichen.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafeCell verhindert, dass Excel Freitext (z. B. Kommentare)
+// als Formel auswertet.
+func csvSafeCell(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ------------------------------------------------------------
+// XLSX
+// ------------------------------------------------------------
+//
+// Minimale Office-Open-XML-Arbeitsmappe mit einem Blatt:
+// Text als Inline-Strings, fette und fixierte Kopfzeile.
+// Dafür reicht archive/zip, eine Excel-Bibliothek ist nicht nötig.
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+// Stil 0 = normal, Stil 1 = fett (Kopfzeile)
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+
+// writeXLSX schreibt eine Arbeitsmappe mit einem Blatt.
+func writeXLSX(w io.Writer, sheetName string, header []string, widths []float64, rows [][]string) error {
+	zw := zip.NewWriter(w)
+
+	var workbook bytes.Buffer
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="`)
+	xml.EscapeText(&workbook, []byte(sheetName))
+	workbook.WriteString(`" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`)
+
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+`)
+
+	if len(widths) > 0 {
+		sheet.WriteString("<cols>")
+		for i, width := range widths {
+			fmt.Fprintf(&sheet, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, width)
+		}
+		sheet.WriteString("</cols>\n")
+	}
+
+	sheet.WriteString("<sheetData>\n")
+	xlsxWriteRow(&sheet, 1, header, 1)
+	for i, row := range rows {
+		xlsxWriteRow(&sheet, i+2, row, 0)
+	}
+	sheet.WriteString("</sheetData>\n</worksheet>")
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+		{"xl/worksheets/sheet1.xml", sheet.Bytes()},
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// xlsxWriteRow schreibt eine Zeile als Inline-Strings.
+func xlsxWriteRow(buf *bytes.Buffer, rowNum int, cells []string, style int) {
+	fmt.Fprintf(buf, `<row r="%d">`, rowNum)
+
+	for i, cell := range cells {
+		if cell == "" {
+			continue
+		}
+		fmt.Fprintf(buf, `<c r="%s%d" t="inlineStr"`, xlsxColumn(i), rowNum)
+		if style != 0 {
+			fmt.Fprintf(buf, ` s="%d"`, style)
+		}
+		buf.WriteString(`><is><t xml:space="preserve">`)
+		xml.EscapeText(buf, []byte(cell)) // ungültige XML-Zeichen werden ersetzt
+		buf.WriteString(`</t></is></c>`)
+	}
+
+	buf.WriteString("</row>\n")
+}
+
+// xlsxColumn liefert den Spaltenbuchstaben (0 → A, 26 → AA).
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSVSafeCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Prüfstand 4", "Prüfstand 4"},
+		{"=HYPERLINK(\"x\")", "'=HYPERLINK(\"x\")"},
+		{"+49 89", "'+49 89"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+	}
+
+	for _, tt := range tests {
+		if got := csvSafeCell(tt.in); got != tt.want {
+			t.Errorf("csvSafeCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+
+	for i, want := range tests {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestWriteXLSXEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeXLSX(&buf, "A&B", []string{"Kommentar"}, nil, [][]string{{`<b>"x" & y</b>`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	if strings.Contains(sheet, "<b>") || !strings.Contains(sheet, "&lt;b&gt;&#34;x&#34; &amp; y&lt;/b&gt;") {
+		t.Errorf("cell not escaped:\n%s", sheet)
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `name="A&amp;B"`) {
+		t.Errorf("sheet name not escaped:\n%s", files["xl/workbook.xml"])
+	}
+}
+
+// Exportdatei muss unverändert wieder eingelesen werden können.
+func TestExportCSVImportRoundTrip(t *testing.T) {
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}}
+	officeCatalogMutex.Unlock()
+
+	comments := []string{"Prüfstand 4", "=1+1", "+49 89", "-Test", "@SUM(A1)", "'zitiert", "a;b \"c\"\nd"}
+
+	var table [][]string
+	for i, c := range comments {
+		row := exportRow{IP: "10.99.0.1", MACAddress: "00:01:05:00:00:0" + string(rune('1'+i)), Office: "T4015", Comment: c}
+		table = append(table, row.cells())
+	}
+
+	var buf bytes.Buffer
+	if err := writeExportCSV(&buf, exportHeader, table); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, rejected, _, err := parseImportCSV(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 0 {
+		t.Fatalf("rejected = %+v", rejected)
+	}
+	if len(rows) != len(comments) {
+		t.Fatalf("rows = %d, want %d", len(rows), len(comments))
+	}
+
+	for i, row := range rows {
+		if row.Comment != comments[i] || row.Office != "T4015" || !row.HasComment {
+			t.Errorf("row %d: comment %q office %q, want %q T4015", i, row.Comment, row.Office, comments[i])
+		}
+	}
+}
+
+func TestExportRowsFavorites(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	inventory["10.99.0.1"] = &IPC{IP: "10.99.0.1", MACAddress: "00:01:05:AA:BB:01"}
+	inventory["10.99.0.2"] = &IPC{IP: "10.99.0.2", MACAddress: "00:01:05:AA:BB:02"}
+	inventory["10.99.0.3"] = &IPC{IP: "10.99.0.3"}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"10.99.0.1", "10.99.0.2", "10.99.0.3"}},
+		{"fav=00:01:05:aa:bb:02,10.99.0.3", []string{"10.99.0.2", "10.99.0.3"}},
+		{"fav=", []string{}},
+	}
+
+	for _, tt := range tests {
+		rows, err := exportRows(httptest.NewRequest("GET", "/api/export?"+tt.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, row := range rows {
+			got = append(got, row.IP)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: rows = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
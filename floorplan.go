@@ -0,0 +1,952 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Lagepläne
+// ------------------------------------------------------------
+//
+// Je Gebäude/Etage kann ein Grundriss (SVG oder PNG) hochgeladen werden.
+// Darauf werden Büros aus dem Katalog als Rechtecke markiert; die Seite
+// /floorplan zeigt je Büro Anzahl, Erreichbarkeit und RUN-State der
+// über officeAssignments zugeordneten Geräte.
+//
+//	GET    /api/floorplans             Pläne inkl. Bereiche
+//	POST   /api/floorplans             Plan hochladen (multipart: building, floor, title, file)
+//	PUT    /api/floorplans/{id}        Titel und Bereiche speichern
+//	DELETE /api/floorplans/{id}        Plan löschen
+//	GET    /api/floorplans/{id}/image  Bilddatei
+//	GET    /api/floorplans/status      Gerätestatus je Büro
+//
+// Bereiche werden in Prozent der Bildgröße gespeichert, damit sie
+// unabhängig von der Anzeigegröße passen:
+//
+//	{"office":"T4015","x":12.5,"y":30,"w":8,"h":11.2}
+type FloorPlan struct {
+	ID          string        `json:"id"` // "<Gebäude>-<Etage>", z. B. "T-4"
+	Building    string        `json:"building"`
+	Floor       string        `json:"floor"`
+	Title       string        `json:"title,omitempty"`
+	ContentType string        `json:"contentType"`
+	Regions     []FloorRegion `json:"regions"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+}
+
+// FloorRegion markiert ein Büro auf dem Plan (Werte in Prozent).
+type FloorRegion struct {
+	Office string  `json:"office"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	W      float64 `json:"w"`
+	H      float64 `json:"h"`
+}
+
+// floorPlanImage ist das gespeicherte Bild eines Plans.
+// Liegt in einem eigenen Dokument, damit die Planliste klein bleibt.
+type floorPlanImage struct {
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+}
+
+const (
+	floorPlanDocument      = "floorplans"
+	floorPlanImagePrefix   = "floorplan_"
+	floorPlanMaxImageBytes = 10 << 20 // 10 MB
+
+	floorPlanPNG = "image/png"
+	floorPlanSVG = "image/svg+xml"
+)
+
+var (
+	// floorPlans enthält alle Pläne, sortiert nach Gebäude und Etage.
+	floorPlans []FloorPlan
+
+	// floorPlanMutex schützt floorPlans.
+	floorPlanMutex sync.Mutex
+)
+
+var errFloorPlanNotFound = errors.New("floor plan not found")
+
+// ------------------------------------------------------------
+// Laden / Speichern
+// ------------------------------------------------------------
+
+// loadFloorPlans lädt die Planliste (ohne Bilder).
+func loadFloorPlans() error {
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	var loaded []FloorPlan
+	if _, err := store.LoadDocument(floorPlanDocument, &loaded); err != nil {
+		return err
+	}
+
+	floorPlans = loaded
+	return nil
+}
+
+// sortFloorPlans sortiert nach Gebäude und Etage.
+func sortFloorPlans(plans []FloorPlan) {
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].Building != plans[j].Building {
+			return plans[i].Building < plans[j].Building
+		}
+		return plans[i].Floor < plans[j].Floor
+	})
+}
+
+// floorPlanIndexLocked sucht einen Plan (-1 = nicht vorhanden).
+func floorPlanIndexLocked(id string) int {
+	for i, p := range floorPlans {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyFloorPlansLocked liefert eine tiefe Kopie der Planliste.
+func copyFloorPlansLocked() []FloorPlan {
+	out := make([]FloorPlan, len(floorPlans))
+	for i, p := range floorPlans {
+		p.Regions = append([]FloorRegion{}, p.Regions...)
+		out[i] = p
+	}
+	return out
+}
+
+// ------------------------------------------------------------
+// Prüfung
+// ------------------------------------------------------------
+
+// floorPlanID bildet die Plan-ID aus Gebäude und Etage.
+//
+// Nur Buchstaben und Ziffern, damit die ID gefahrlos als
+// Dokument-/Dateiname verwendet werden kann.
+func floorPlanID(building, floor string) (string, error) {
+	for _, part := range []string{building, floor} {
+		if part == "" || len(part) > 16 {
+			return "", errors.New("building and floor must have 1-16 characters")
+		}
+		for _, r := range part {
+			if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+				return "", errors.New("building and floor may only contain letters and digits")
+			}
+		}
+	}
+
+	return building + "-" + floor, nil
+}
+
+// floorPlanContentType erkennt PNG bzw. SVG am Inhalt.
+func floorPlanContentType(data []byte) (string, error) {
+	if http.DetectContentType(data) == floorPlanPNG {
+		return floorPlanPNG, nil
+	}
+
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if bytes.Contains(bytes.ToLower(head), []byte("<svg")) {
+		return floorPlanSVG, nil
+	}
+
+	return "", errors.New("only SVG or PNG images are supported")
+}
+
+// normalizeFloorRegions prüft die Bereiche eines Plans.
+//
+// Aufrufer muss officeCatalogMutex halten.
+func normalizeFloorRegions(regions []FloorRegion) ([]FloorRegion, error) {
+	out := make([]FloorRegion, 0, len(regions))
+
+	for _, rg := range regions {
+		rg.Office = strings.TrimSpace(rg.Office)
+		if rg.Office == "" || officeIndexLocked(rg.Office) < 0 {
+			return nil, fmt.Errorf("unknown office %q", rg.Office)
+		}
+
+		for _, v := range []float64{rg.X, rg.Y, rg.W, rg.H} {
+			if math.IsNaN(v) || v < 0 || v > 100 {
+				return nil, fmt.Errorf("region %s: values must be between 0 and 100 percent", rg.Office)
+			}
+		}
+		if rg.W == 0 || rg.H == 0 {
+			return nil, fmt.Errorf("region %s: width and height must not be 0", rg.Office)
+		}
+
+		// Überstand am Rand abschneiden
+		rg.W = math.Min(rg.W, 100-rg.X)
+		rg.H = math.Min(rg.H, 100-rg.Y)
+
+		out = append(out, rg)
+	}
+
+	return out, nil
+}
+
+// ------------------------------------------------------------
+// Ändern
+// ------------------------------------------------------------
+
+// saveFloorPlanImage legt einen Plan an bzw. ersetzt sein Bild.
+// Vorhandene Bereiche bleiben erhalten.
+func saveFloorPlanImage(building, floor, title string, img floorPlanImage) (FloorPlan, error) {
+	id, err := floorPlanID(building, floor)
+	if err != nil {
+		return FloorPlan{}, err
+	}
+
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	updated := copyFloorPlansLocked()
+
+	plan := FloorPlan{ID: id, Building: building, Floor: floor, Regions: []FloorRegion{}}
+	idx := floorPlanIndexLocked(id)
+	if idx >= 0 {
+		plan = updated[idx]
+	} else {
+		updated = append(updated, plan)
+		idx = len(updated) - 1
+	}
+
+	if title != "" {
+		plan.Title = title
+	}
+	plan.ContentType = img.ContentType
+	plan.UpdatedAt = time.Now()
+	updated[idx] = plan
+
+	sortFloorPlans(updated)
+
+	err = store.Update(func(tx StorageTx) error {
+		if err := tx.PutDocument(floorPlanImagePrefix+id, img); err != nil {
+			return err
+		}
+		return tx.PutDocument(floorPlanDocument, updated)
+	})
+	if err != nil {
+		return FloorPlan{}, err
+	}
+
+	floorPlans = updated
+	return plan, nil
+}
+
+// updateFloorPlan speichert Titel und Bereiche eines Plans.
+func updateFloorPlan(id, title string, regions []FloorRegion) (FloorPlan, error) {
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	idx := floorPlanIndexLocked(id)
+	if idx < 0 {
+		return FloorPlan{}, errFloorPlanNotFound
+	}
+
+	updated := copyFloorPlansLocked()
+	updated[idx].Title = title
+	updated[idx].Regions = regions
+	updated[idx].UpdatedAt = time.Now()
+
+	err := store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(floorPlanDocument, updated)
+	})
+	if err != nil {
+		return FloorPlan{}, err
+	}
+
+	floorPlans = updated
+	return updated[idx], nil
+}
+
+// deleteFloorPlan entfernt einen Plan samt Bild.
+//
+// Der Storage kennt kein Löschen von Dokumenten,
+// daher wird das Bild-Dokument geleert.
+func deleteFloorPlan(id string) error {
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	idx := floorPlanIndexLocked(id)
+	if idx < 0 {
+		return errFloorPlanNotFound
+	}
+
+	updated := copyFloorPlansLocked()
+	updated = append(updated[:idx], updated[idx+1:]...)
+
+	err := store.Update(func(tx StorageTx) error {
+		if err := tx.PutDocument(floorPlanImagePrefix+id, floorPlanImage{}); err != nil {
+			return err
+		}
+		return tx.PutDocument(floorPlanDocument, updated)
+	})
+	if err != nil {
+		return err
+	}
+
+	floorPlans = updated
+	return nil
+}
+
+// renameFloorPlanOfficeLocked stellt Bereiche auf einen neuen Büronamen um
+// (Umbenennen im Büro-Katalog).
+//
+// Der neue Stand wird in tx geschrieben; apply übernimmt ihn erst nach
+// erfolgreichem Speichern der Transaktion in den Speicher.
+//
+// Aufrufer muss floorPlanMutex halten.
+func renameFloorPlanOfficeLocked(tx StorageTx, oldName, newName string) (apply func(), err error) {
+	updated := copyFloorPlansLocked()
+	changed := false
+	for i := range updated {
+		for j := range updated[i].Regions {
+			if updated[i].Regions[j].Office == oldName {
+				updated[i].Regions[j].Office = newName
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return func() {}, nil
+	}
+
+	if err := tx.PutDocument(floorPlanDocument, updated); err != nil {
+		return nil, err
+	}
+	return func() { floorPlans = updated }, nil
+}
+
+// floorPlansWithOffice liefert die IDs der Pläne, auf denen ein Büro markiert ist.
+func floorPlansWithOffice(name string) []string {
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	var ids []string
+	for _, p := range floorPlans {
+		for _, rg := range p.Regions {
+			if rg.Office == name {
+				ids = append(ids, p.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// ------------------------------------------------------------
+// Gerätestatus je Büro
+// ------------------------------------------------------------
+
+// officeStatus fasst die Geräte eines Büros für den Lageplan zusammen.
+type officeStatus struct {
+	Office  string               `json:"office"`
+	Devices int                  `json:"devices"` // zugeordnete MAC-Adressen
+	Online  int                  `json:"online"`  // davon aktuell erreichbar
+	IPCs    int                  `json:"ipcs"`    // davon mit AMS Net ID (TwinCAT)
+	Run     int                  `json:"run"`     // davon TwinCAT im RUN
+	Items   []officeStatusDevice `json:"items"`
+}
+
+// officeStatusDevice ist ein Gerät in der Detailliste eines Büros.
+type officeStatusDevice struct {
+	MAC      string `json:"mac"`
+	IP       string `json:"ip,omitempty"` // leer = zugeordnet, aber nicht im Inventar
+	Hostname string `json:"hostname,omitempty"`
+	Online   bool   `json:"online"`
+	Runtime  string `json:"runtime,omitempty"`
+}
+
+// officeStatuses ermittelt den Status aller Büros mit Zuordnungen.
+//
+// Grundlage sind die officeAssignments; zugeordnete MACs, die aktuell
+// nicht im Inventar stehen, zählen als offline.
+func officeStatuses() map[string]*officeStatus {
+	officeMutex.Lock()
+	assignments := make(map[string]string, len(officeAssignments))
+	for mac, office := range officeAssignments {
+		assignments[mac] = office
+	}
+	officeMutex.Unlock()
+
+	// MAC → Gerät; bei Dubletten gewinnt der erreichbare Eintrag
+	byMAC := make(map[string]IPC)
+	inventoryMutex.Lock()
+	for _, dev := range inventory {
+		if dev == nil || dev.MACAddress == "" {
+			continue
+		}
+		mac := normalizeMAC(dev.MACAddress)
+		if prev, ok := byMAC[mac]; ok && prev.IsReachable && !dev.IsReachable {
+			continue
+		}
+		byMAC[mac] = *dev
+	}
+	inventoryMutex.Unlock()
+
+	out := make(map[string]*officeStatus)
+	for mac, office := range assignments {
+		st := out[office]
+		if st == nil {
+			st = &officeStatus{Office: office, Items: []officeStatusDevice{}}
+			out[office] = st
+		}
+
+		st.Devices++
+		item := officeStatusDevice{MAC: mac}
+
+		if dev, ok := byMAC[mac]; ok {
+			item.IP = dev.IP
+			item.Hostname = dev.Hostname
+			item.Online = dev.IsReachable
+			if dev.AmsNetID != "" {
+				st.IPCs++
+				item.Runtime = runtimeStatusBase(dev.RuntimeStatus)
+				if dev.IsReachable && item.Runtime == "RUN" {
+					st.Run++
+				}
+			}
+			if dev.IsReachable {
+				st.Online++
+			}
+		}
+
+		st.Items = append(st.Items, item)
+	}
+
+	for _, st := range out {
+		sort.Slice(st.Items, func(i, j int) bool {
+			return st.Items[i].MAC < st.Items[j].MAC
+		})
+	}
+
+	return out
+}
+
+// ------------------------------------------------------------
+// HTTP-API
+// ------------------------------------------------------------
+
+// listFloorPlans liefert eine Kopie der Planliste.
+func listFloorPlans() []FloorPlan {
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	return copyFloorPlansLocked()
+}
+
+// handleFloorPlans verarbeitet GET/POST /api/floorplans.
+func handleFloorPlans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, listFloorPlans())
+
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, floorPlanMaxImageBytes+1<<20)
+		if err := r.ParseMultipartForm(floorPlanMaxImageBytes); err != nil {
+			http.Error(w, "invalid upload (multipart, max 10 MB)", http.StatusBadRequest)
+			return
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "failed to read file", http.StatusBadRequest)
+			return
+		}
+
+		contentType, err := floorPlanContentType(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		building := strings.TrimSpace(r.FormValue("building"))
+		floor := strings.TrimSpace(r.FormValue("floor"))
+		title := strings.TrimSpace(r.FormValue("title"))
+
+		if _, err := floorPlanID(building, floor); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plan, err := saveFloorPlanImage(building, floor, title, floorPlanImage{ContentType: contentType, Data: data})
+		appendAudit(r, auditFloorPlanSave, building+"-"+floor,
+			fmt.Sprintf("%s, %d bytes", contentType, len(data)), err)
+
+		if err != nil {
+			http.Error(w, "failed to save floor plan", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, plan)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// floorPlanUpdateRequest ist der Body von PUT /api/floorplans/{id}.
+type floorPlanUpdateRequest struct {
+	Title   string        `json:"title"`
+	Regions []FloorRegion `json:"regions"`
+}
+
+// handleFloorPlan verarbeitet PUT/DELETE /api/floorplans/{id}.
+func handleFloorPlan(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodPut:
+		var req floorPlanUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		// Prüfen und Speichern unter officeCatalogMutex,
+		// damit deleteOffice das Büro nicht dazwischen entfernt.
+		officeCatalogMutex.Lock()
+		regions, err := normalizeFloorRegions(req.Regions)
+		if err != nil {
+			officeCatalogMutex.Unlock()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plan, err := updateFloorPlan(id, strings.TrimSpace(req.Title), regions)
+		officeCatalogMutex.Unlock()
+
+		appendAudit(r, auditFloorPlanRegions, id, fmt.Sprintf("%d regions", len(regions)), err)
+
+		if errors.Is(err, errFloorPlanNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to save floor plan", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, plan)
+
+	case http.MethodDelete:
+		err := deleteFloorPlan(id)
+		appendAudit(r, auditFloorPlanDelete, id, "", err)
+
+		if errors.Is(err, errFloorPlanNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to delete floor plan", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFloorPlanImage liefert GET /api/floorplans/{id}/image.
+func handleFloorPlanImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	floorPlanMutex.Lock()
+	known := floorPlanIndexLocked(id) >= 0
+	floorPlanMutex.Unlock()
+
+	if !known {
+		http.Error(w, errFloorPlanNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	var img floorPlanImage
+	found, err := store.LoadDocument(floorPlanImagePrefix+id, &img)
+	if err != nil {
+		http.Error(w, "failed to read floor plan", http.StatusInternalServerError)
+		return
+	}
+	if !found || len(img.Data) == 0 {
+		http.Error(w, errFloorPlanNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	// SVG darf beim direkten Aufruf keine Skripte ausführen
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(img.Data)
+}
+
+// handleFloorPlanStatus liefert GET /api/floorplans/status.
+func handleFloorPlanStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, officeStatuses())
+}
+
+// ------------------------------------------------------------
+// Seite
+// ------------------------------------------------------------
+
+// handleFloorPlanPage zeigt /floorplan.
+//
+// Pläne, Status und (für Admins) der Bereichs-Editor werden im Browser
+// aus den JSON-Daten aufgebaut; der Status wird alle 15 s nachgeladen.
+func handleFloorPlanPage(w http.ResponseWriter, r *http.Request) {
+	isAdmin := requestUser(r).Role == roleAdmin
+
+	// json.Marshal maskiert <, > und &, daher direkt ins <script> einsetzbar
+	plansJSON, _ := json.Marshal(listFloorPlans())
+	statusJSON, _ := json.Marshal(officeStatuses())
+	officesJSON, _ := json.Marshal(officeNames())
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            .hint { color: #888; font-size: 0.85em; }
+            input, select { padding: 4px 6px; border: 1px solid #ddd; border-radius: 4px; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+            button.active { background: #ce1126; color: white; }
+            .tabs { display: flex; gap: 6px; flex-wrap: wrap; margin-bottom: 12px; }
+            .layout { display: flex; gap: 20px; align-items: flex-start; }
+            .plan-wrap { position: relative; display: inline-block; max-width: 75%; border: 1px solid #eee; user-select: none; }
+            .plan-wrap img { display: block; max-width: 100%; }
+            .plan-wrap.editing { cursor: crosshair; }
+            .region { position: absolute; box-sizing: border-box; border: 2px solid; border-radius: 3px; cursor: pointer;
+                      font-size: 11px; line-height: 1.2; padding: 2px; overflow: hidden; }
+            .region b { display: block; }
+            .region.ok    { border-color: #28a745; background: rgba(40,167,69,0.25); }
+            .region.warn  { border-color: #e0a800; background: rgba(255,193,7,0.30); }
+            .region.bad   { border-color: #ce1126; background: rgba(206,17,38,0.25); }
+            .region.empty { border-color: #999;    background: rgba(150,150,150,0.15); }
+            .region.draft { border-style: dashed; border-color: #007bff; background: rgba(0,123,255,0.15); }
+            .details { min-width: 260px; font-size: 0.9em; }
+            .details table { border-collapse: collapse; width: 100%; }
+            .details td { padding: 4px 6px; border-bottom: 1px solid #eee; }
+            .dot { display: inline-block; width: 8px; height: 8px; border-radius: 50%; margin-right: 4px; }
+            .dot.on { background: #28a745; } .dot.off { background: #ce1126; }
+            .legend span { margin-right: 12px; }
+            .admin { margin-top: 20px; padding-top: 12px; border-top: 1px solid #eee; }
+            .admin form { margin-bottom: 10px; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Lageplan</h1>
+            </div>
+
+            <div class="tabs" id="tabs"></div>
+            <p class="legend hint">
+                <span><span class="dot on"></span>alle online, TwinCAT in RUN</span>
+                <span style="color:#e0a800">■</span> online, aber nicht alle in RUN
+                <span style="color:#ce1126">■</span> Gerät offline
+                <span style="color:#999">■</span> keine Geräte
+            </p>
+
+            <div class="layout">
+                <div class="plan-wrap" id="planWrap"></div>
+                <div class="details" id="details"><p class="hint">Büro anklicken, um die Geräte anzuzeigen.</p></div>
+            </div>
+`)
+
+	if isAdmin {
+		fmt.Fprint(w, `
+            <div class="admin">
+                <form id="uploadForm">
+                    Plan hochladen:
+                    <input name="building" value="T" size="4" placeholder="Gebäude" required>
+                    <input name="floor" size="4" placeholder="Etage" required>
+                    <input name="title" size="20" placeholder="Titel (optional)">
+                    <input name="file" type="file" accept=".svg,.png,image/svg+xml,image/png" required>
+                    <button type="submit">Hochladen</button>
+                </form>
+                <div id="editBar">
+                    <button type="button" id="editBtn">Bereiche bearbeiten</button>
+                    <span id="editTools" style="display:none">
+                        Büro: <select id="editOffice"></select>
+                        <button type="button" id="saveRegions">Speichern</button>
+                        <button type="button" id="cancelEdit">Abbrechen</button>
+                        <span class="hint">Rechteck auf dem Plan aufziehen; Bereich anklicken entfernt ihn.</span>
+                    </span>
+                    <button type="button" id="deletePlan">Plan löschen</button>
+                </div>
+                <p class="hint">SVG oder PNG, max. 10 MB. Gleiches Gebäude/Etage ersetzt das Bild, die Bereiche bleiben erhalten.</p>
+            </div>
+`)
+	}
+
+	fmt.Fprintf(w, `
+        </div>
+
+        <script>
+            let plans = %s;
+            let status = %s;
+            const officeNames = %s;
+            const isAdmin = %t;
+
+            let currentId = plans.length ? plans[0].id : "";
+            let draft = null; // Bereiche im Bearbeitungsmodus
+
+            const wrap = document.getElementById("planWrap");
+            const details = document.getElementById("details");
+
+            function esc(s) {
+                return String(s ?? "").replace(/[&<>"']/g, c => ({"&":"&amp;","<":"&lt;",">":"&gt;","\"":"&quot;","'":"&#39;"}[c]));
+            }
+
+            function currentPlan() {
+                return plans.find(p => p.id === currentId);
+            }
+
+            function regionClass(st) {
+                if (!st || st.devices === 0) return "empty";
+                if (st.online < st.devices) return "bad";
+                if (st.run < st.ipcs) return "warn";
+                return "ok";
+            }
+
+            function renderTabs() {
+                const tabs = document.getElementById("tabs");
+                if (!plans.length) {
+                    tabs.innerHTML = '<span class="hint">Noch kein Lageplan hochgeladen.</span>';
+                    return;
+                }
+                tabs.innerHTML = plans.map(p =>
+                    '<button data-id="' + esc(p.id) + '" class="' + (p.id === currentId ? "active" : "") + '">' +
+                    esc(p.title || ("Gebäude " + p.building + " · Etage " + p.floor)) + '</button>').join("");
+                tabs.querySelectorAll("button").forEach(b => b.onclick = () => {
+                    if (draft) return;
+                    currentId = b.dataset.id;
+                    renderTabs();
+                    renderPlan();
+                });
+            }
+
+            function renderPlan() {
+                const plan = currentPlan();
+                if (!plan) {
+                    wrap.innerHTML = "";
+                    return;
+                }
+                const v = new Date(plan.updatedAt).getTime();
+                wrap.innerHTML = '<img draggable="false" src="/api/floorplans/' + encodeURIComponent(plan.id) + '/image?v=' + v + '">';
+                renderRegions();
+            }
+
+            function renderRegions() {
+                const plan = currentPlan();
+                if (!plan) return;
+                wrap.querySelectorAll(".region").forEach(el => el.remove());
+
+                (draft || plan.regions).forEach((rg, i) => {
+                    const st = status[rg.office];
+                    const el = document.createElement("div");
+                    el.className = "region " + (draft ? "draft" : regionClass(st));
+                    el.style.left = rg.x + "%%";
+                    el.style.top = rg.y + "%%";
+                    el.style.width = rg.w + "%%";
+                    el.style.height = rg.h + "%%";
+
+                    let label = "<b>" + esc(rg.office) + "</b>";
+                    if (st) {
+                        label += st.devices + " Geräte";
+                        if (st.ipcs) label += "<br>" + st.run + "/" + st.ipcs + " RUN";
+                    }
+                    el.innerHTML = label;
+                    el.title = rg.office;
+
+                    el.addEventListener("mousedown", e => e.stopPropagation());
+                    el.onclick = () => {
+                        if (draft) {
+                            draft.splice(i, 1);
+                            renderRegions();
+                        } else {
+                            showDetails(rg.office);
+                        }
+                    };
+                    wrap.appendChild(el);
+                });
+            }
+
+            function showDetails(office) {
+                const st = status[office];
+                let html = "<h3 style='margin-top:0'>" + esc(office) + "</h3>";
+                if (!st || !st.items.length) {
+                    details.innerHTML = html + '<p class="hint">Keine Geräte zugeordnet.</p>';
+                    return;
+                }
+                html += "<table>";
+                st.items.forEach(d => {
+                    html += "<tr><td><span class='dot " + (d.online ? "on" : "off") + "'></span></td>" +
+                        "<td>" + esc(d.hostname || d.ip || "nicht im Inventar") + "<br><span class='hint'>" +
+                        esc(d.ip) + " " + esc(d.mac) + "</span></td>" +
+                        "<td>" + esc(d.runtime) + "</td>" +
+                        "<td><a href='/history?id=" + encodeURIComponent(d.mac) + "' title='Verlauf'>🕘</a></td></tr>";
+                });
+                details.innerHTML = html + "</table>";
+                details.dataset.office = office;
+            }
+
+            async function refreshStatus() {
+                try {
+                    const res = await fetch("/api/floorplans/status");
+                    if (!res.ok) return;
+                    status = await res.json();
+                    if (!draft) renderRegions();
+                    if (details.dataset.office) showDetails(details.dataset.office);
+                } catch (e) {}
+            }
+
+            renderTabs();
+            renderPlan();
+            setInterval(refreshStatus, 15000);
+
+            if (isAdmin) {
+                const uploadForm = document.getElementById("uploadForm");
+                uploadForm.addEventListener("submit", async e => {
+                    e.preventDefault();
+                    const res = await fetch("/api/floorplans", { method: "POST", body: new FormData(uploadForm) });
+                    if (!res.ok) {
+                        alert("Fehler: " + (await res.text()));
+                        return;
+                    }
+                    location.reload();
+                });
+
+                const sel = document.getElementById("editOffice");
+                sel.innerHTML = officeNames.map(n => '<option>' + esc(n) + '</option>').join("");
+
+                function setEditing(on) {
+                    const plan = currentPlan();
+                    if (on && !plan) return;
+                    draft = on ? plan.regions.map(r => Object.assign({}, r)) : null;
+                    wrap.classList.toggle("editing", on);
+                    document.getElementById("editTools").style.display = on ? "" : "none";
+                    document.getElementById("editBtn").style.display = on ? "none" : "";
+                    renderRegions();
+                }
+
+                document.getElementById("editBtn").onclick = () => setEditing(true);
+                document.getElementById("cancelEdit").onclick = () => setEditing(false);
+
+                document.getElementById("saveRegions").onclick = async () => {
+                    const plan = currentPlan();
+                    const res = await fetch("/api/floorplans/" + encodeURIComponent(plan.id), {
+                        method: "PUT",
+                        headers: { "Content-Type": "application/json" },
+                        body: JSON.stringify({ title: plan.title || "", regions: draft })
+                    });
+                    if (!res.ok) {
+                        alert("Fehler: " + (await res.text()));
+                        return;
+                    }
+                    const saved = await res.json();
+                    plans = plans.map(p => p.id === saved.id ? saved : p);
+                    setEditing(false);
+                };
+
+                document.getElementById("deletePlan").onclick = async () => {
+                    const plan = currentPlan();
+                    if (!plan || !confirm("Plan " + plan.id + " löschen?")) return;
+                    const res = await fetch("/api/floorplans/" + encodeURIComponent(plan.id), { method: "DELETE" });
+                    if (!res.ok) {
+                        alert("Fehler: " + (await res.text()));
+                        return;
+                    }
+                    location.reload();
+                };
+
+                // Rechteck aufziehen (Koordinaten in Prozent des Bildes)
+                let start = null, box = null;
+
+                function pos(e) {
+                    const r = wrap.getBoundingClientRect();
+                    return {
+                        x: Math.min(100, Math.max(0, (e.clientX - r.left) / r.width * 100)),
+                        y: Math.min(100, Math.max(0, (e.clientY - r.top) / r.height * 100))
+                    };
+                }
+
+                function rect(a, b) {
+                    const round = v => Math.round(v * 10) / 10;
+                    return {
+                        office: sel.value,
+                        x: round(Math.min(a.x, b.x)), y: round(Math.min(a.y, b.y)),
+                        w: round(Math.abs(a.x - b.x)), h: round(Math.abs(a.y - b.y))
+                    };
+                }
+
+                wrap.addEventListener("mousedown", e => {
+                    if (!draft || !sel.value) return;
+                    e.preventDefault();
+                    start = pos(e);
+                    box = document.createElement("div");
+                    box.className = "region draft";
+                    wrap.appendChild(box);
+                });
+
+                wrap.addEventListener("mousemove", e => {
+                    if (!start) return;
+                    const rg = rect(start, pos(e));
+                    box.style.left = rg.x + "%%";
+                    box.style.top = rg.y + "%%";
+                    box.style.width = rg.w + "%%";
+                    box.style.height = rg.h + "%%";
+                });
+
+                window.addEventListener("mouseup", e => {
+                    if (!start) return;
+                    const rg = rect(start, pos(e));
+                    start = null;
+                    box.remove();
+                    if (rg.w >= 1 && rg.h >= 1) draft.push(rg);
+                    renderRegions();
+                });
+            }
+        </script>
+    </body>
+    </html>
+`, plansJSON, statusJSON, officesJSON, isAdmin)
+}
@@ -1,3 +1,17 @@
 module labor-inventar
 
-go 1.25.6
+go 1.26.0
+
+require (
+	github.com/go-ldap/ldap/v3 v3.4.8
+	go.etcd.io/bbolt v1.5.0
+	golang.org/x/crypto v0.57.0
+	golang.org/x/net v0.60.0
+)
+
+require (
+	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358 // indirect
+	github.com/go-asn1-ber/asn1-ber v1.5.5 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+)
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Geräte-Historie
+// ------------------------------------------------------------
+//
+// DeviceEvent beschreibt eine einzelne erkannte Änderung an einem Gerät.
+//
+// Die Historie ist MAC-basiert, da die IP sich ändern kann
+// (siehe migrateDeviceLocked), die MAC aber das Gerät identifiziert.
+//
+// Beispiel:
+//
+//	{"time":"...","mac":"00:01:05:12:34:56","ip":"172.17.76.23",
+//	 "field":"runtimeStatus","old":"RUN","new":"STOP"}
+type DeviceEvent struct {
+	Time  time.Time `json:"time"`
+	MAC   string    `json:"mac"`
+	IP    string    `json:"ip"`
+	Field string    `json:"field"` // geändertes Merkmal, siehe historyField*-Konstanten
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+}
+
+// Feldnamen in DeviceEvent.Field
+const (
+	historyFieldDiscovered = "discovered" // MAC zum ersten Mal gesehen
+	historyFieldOnline     = "online"
+	historyFieldIP         = "ip"
+	historyFieldHostname   = "hostname"
+	historyFieldAmsNetID   = "amsNetId"
+	historyFieldOS         = "osVersion"
+	historyFieldTwinCAT    = "twincatVersion"
+	historyFieldRuntime    = "runtimeStatus"
+	historyFieldRuntimes   = "runtimes" // States aller PLC-Runtimes, z. B. "851 RUN, 852 STOP"
+)
+
+// historyFieldLabels sind die Anzeigenamen für die Timeline.
+var historyFieldLabels = map[string]string{
+	historyFieldDiscovered: "Neu erkannt",
+	historyFieldOnline:     "Status",
+	historyFieldIP:         "IP-Adresse",
+	historyFieldHostname:   "Hostname",
+	historyFieldAmsNetID:   "AMS Net-ID",
+	historyFieldOS:         "OS Version",
+	historyFieldTwinCAT:    "TwinCAT",
+	historyFieldRuntime:    "TC State",
+	historyFieldRuntimes:   "PLC-Runtimes",
+}
+
+// historyStream ist der Name des Event-Streams im Persistenz-Backend.
+// Schlüssel ist die normalisierte MAC-Adresse.
+const historyStream = "history"
+
+// ------------------------------------------------------------
+// Ablage
+// ------------------------------------------------------------
+
+// appendDeviceEvents hängt Events an die Historie der jeweiligen Geräte an.
+//
+// Alle Events eines Scans werden in einer Transaktion geschrieben.
+// Im JSON-Backend landet jedes Gerät in einer eigenen Datei:
+//
+//	<dataDir>/history/00-01-05-12-34-56.jsonl
+func appendDeviceEvents(events []DeviceEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return store.Update(func(tx StorageTx) error {
+		for _, ev := range events {
+			if err := tx.AppendEvent(historyStream, normalizeMAC(ev.MAC), ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readDeviceEvents liest die Historie eines Geräts, neueste zuerst.
+//
+// limit <= 0 bedeutet: alle Events.
+func readDeviceEvents(mac string, limit int) ([]DeviceEvent, error) {
+	var out []DeviceEvent
+
+	err := store.ReadEvents(historyStream, normalizeMAC(mac), func(raw []byte) error {
+		var ev DeviceEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			// Beschädigte Zeile (z. B. Absturz beim Schreiben) überspringen
+			return nil
+		}
+		out = append(out, ev)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.After(out[j].Time)
+	})
+
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out, nil
+}
+
+// ------------------------------------------------------------
+// Änderungen zwischen zwei Scan-Ständen erkennen
+// ------------------------------------------------------------
+
+// snapshotByMACLocked kopiert alle Geräte mit MAC in eine Map MAC -> Gerät.
+//
+// Sollte dieselbe MAC kurzzeitig unter zwei IPs stehen,
+// gewinnt der zuletzt online gesehene Eintrag.
+//
+// WICHTIG:
+// Nur unter inventoryMutex.Lock() aufrufen.
+func snapshotByMACLocked() map[string]IPC {
+	out := make(map[string]IPC)
+
+	for _, dev := range inventory {
+		if dev == nil {
+			continue
+		}
+
+		mac := normalizeMAC(dev.MACAddress)
+		if mac == "" {
+			continue
+		}
+
+		if prev, ok := out[mac]; ok && prev.LastSeenOnline.After(dev.LastSeenOnline) {
+			continue
+		}
+
+		out[mac] = *dev
+	}
+
+	return out
+}
+
+// runtimeStatusBase entfernt angehängte Fehlerdetails,
+// z. B. "no Info (ReadState(port 10000): ...)" -> "no Info".
+//
+// Sonst würde jede leicht andere Fehlermeldung als Änderung zählen.
+func runtimeStatusBase(s string) string {
+	if i := strings.Index(s, " ("); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// onlineLabel übersetzt die Erreichbarkeit in den Anzeigetext.
+func onlineLabel(b bool) string {
+	if b {
+		return "Online"
+	}
+	return "Offline"
+}
+
+// diffDeviceStates vergleicht zwei Stände (vor und nach einem Scan)
+// und erzeugt daraus Events.
+//
+// Regeln:
+//   - neue MAC -> "discovered"
+//   - leere neue Werte werden nicht als Änderung gewertet,
+//     da ein Scan nicht jedes Merkmal jedes Mal liefert
+func diffDeviceStates(before, after map[string]IPC, now time.Time) []DeviceEvent {
+	var events []DeviceEvent
+
+	macs := make([]string, 0, len(after))
+	for mac := range after {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+
+	for _, mac := range macs {
+		cur := after[mac]
+
+		add := func(field, oldV, newV string) {
+			events = append(events, DeviceEvent{
+				Time:  now,
+				MAC:   mac,
+				IP:    cur.IP,
+				Field: field,
+				Old:   oldV,
+				New:   newV,
+			})
+		}
+
+		prev, known := before[mac]
+		if !known {
+			add(historyFieldDiscovered, "", cur.IP)
+			continue
+		}
+
+		if prev.IsReachable != cur.IsReachable {
+			add(historyFieldOnline, onlineLabel(prev.IsReachable), onlineLabel(cur.IsReachable))
+		}
+
+		fields := []struct {
+			name     string
+			old, new string
+		}{
+			{historyFieldIP, prev.IP, cur.IP},
+			{historyFieldHostname, prev.Hostname, cur.Hostname},
+			{historyFieldAmsNetID, prev.AmsNetID, cur.AmsNetID},
+			{historyFieldOS, prev.OSVersion, cur.OSVersion},
+			{historyFieldTwinCAT, prev.TwinCATVersion, cur.TwinCATVersion},
+			{historyFieldRuntime, runtimeStatusBase(prev.RuntimeStatus), runtimeStatusBase(cur.RuntimeStatus)},
+			{historyFieldRuntimes, runtimesSummary(prev.Runtimes), runtimesSummary(cur.Runtimes)},
+		}
+
+		for _, f := range fields {
+			if f.new != "" && f.old != f.new {
+				add(f.name, f.old, f.new)
+			}
+		}
+	}
+
+	return events
+}
+
+// ------------------------------------------------------------
+// HTTP-Handler
+// ------------------------------------------------------------
+
+// historyMACForID bestimmt die MAC zu einer Geräte-ID (MAC oder IP).
+//
+// Ist das Gerät nicht (mehr) im Inventory, wird eine MAC-ID
+// trotzdem akzeptiert, damit auch die Historie entfernter Geräte lesbar bleibt.
+func historyMACForID(id string) string {
+	if dev, ok := findDevice(id); ok && dev.MACAddress != "" {
+		return normalizeMAC(dev.MACAddress)
+	}
+
+	hw, err := net.ParseMAC(normalizeMAC(id))
+	if err != nil || len(hw) != 6 {
+		return ""
+	}
+
+	return normalizeMAC(hw.String())
+}
+
+// handleDeviceHistory liefert die Historie eines Geräts:
+//
+//	GET /api/devices/{id}/history?limit=100
+//
+// id ist eine MAC-Adresse oder IPv4-Adresse eines Geräts mit bekannter MAC.
+func handleDeviceHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mac := historyMACForID(r.PathValue("id"))
+	if mac == "" {
+		http.Error(w, "device not found or without MAC", http.StatusNotFound)
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	events, err := readDeviceEvents(mac, limit)
+	if err != nil {
+		http.Error(w, "failed to read history", http.StatusInternalServerError)
+		return
+	}
+
+	if events == nil {
+		events = []DeviceEvent{}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		MAC    string        `json:"mac"`
+		Events []DeviceEvent `json:"events"`
+	}{mac, events})
+}
+
+// handleHistoryPage rendert die Timeline-Ansicht eines Geräts:
+//
+//	GET /history?id=<mac-or-ip>
+func handleHistoryPage(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	mac := historyMACForID(id)
+	if mac == "" {
+		http.Error(w, "device not found or without MAC", http.StatusNotFound)
+		return
+	}
+
+	events, err := readDeviceEvents(mac, 500)
+	if err != nil {
+		http.Error(w, "failed to read history", http.StatusInternalServerError)
+		return
+	}
+
+	dev, _ := findDevice(mac)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	renderHistoryPage(w, mac, dev, events, requestUser(r).Role == roleAdmin)
+}
+
+// renderHistoryPage schreibt die Timeline als HTML,
+// im selben Stil wie renderDashboard().
+//
+// showAudit blendet den Link zum Audit-Log ein (nur für Administratoren).
+func renderHistoryPage(w http.ResponseWriter, mac string, dev IPC, events []DeviceEvent, showAudit bool) {
+	esc := template.HTMLEscapeString
+
+	auditLink := ""
+	if showAudit {
+		auditLink = `<a class="back-link" href="/audit?target=` + url.QueryEscape(mac) + `">Änderungen durch Benutzer &rarr;</a>`
+	}
+
+	title := mac
+	if dev.Hostname != "" {
+		title = dev.Hostname + " (" + mac + ")"
+	}
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+
+            .timeline { list-style: none; margin: 0; padding: 0 0 0 20px; border-left: 2px solid #eee; }
+            .timeline li { position: relative; padding: 8px 0 12px 12px; font-size: 0.9em; }
+            .timeline li::before { content: ""; position: absolute; left: -27px; top: 12px; width: 10px; height: 10px; border-radius: 50%; background: #ce1126; }
+            .ev-time { color: #888; font-size: 0.85em; }
+            .ev-field { font-weight: 600; margin-right: 6px; }
+            .ev-old { color: #999; text-decoration: line-through; }
+            .ev-new { color: #333; font-weight: 600; }
+            .empty { color: #999; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Verlauf: `+esc(title)+`</h1>
+                `+auditLink+`
+            </div>
+            <ul class="timeline">
+`)
+
+	if len(events) == 0 {
+		fmt.Fprint(w, `<li class="empty">Noch keine Änderungen aufgezeichnet.</li>`)
+	}
+
+	for _, ev := range events {
+		label := historyFieldLabels[ev.Field]
+		if label == "" {
+			label = ev.Field
+		}
+
+		change := ""
+		if ev.Field == historyFieldDiscovered {
+			change = `<span class="ev-new">` + esc(ev.New) + `</span>`
+		} else {
+			oldV := ev.Old
+			if oldV == "" {
+				oldV = "-"
+			}
+			change = `<span class="ev-old">` + esc(oldV) + `</span> &rarr; <span class="ev-new">` + esc(ev.New) + `</span>`
+		}
+
+		fmt.Fprintf(w, `<li>
+    <div class="ev-time">%s (%s) · %s</div>
+    <div><span class="ev-field">%s</span>%s</div>
+</li>`,
+			ev.Time.Local().Format("02.01.2006 15:04:05"),
+			formatRelativeTime(ev.Time),
+			esc(ev.IP),
+			esc(label),
+			change,
+		)
+	}
+
+	fmt.Fprint(w, `
+            </ul>
+        </div>
+    </body>
+    </html>
+`)
+}
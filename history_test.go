@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestHistoryMACForID(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	tests := map[string]string{
+		"00-01-05-aa-bb-01":  "00:01:05:AA:BB:01",
+		"00:01:05:AA:BB:01":  "00:01:05:AA:BB:01",
+		":::::/../../AUDIT":  "",
+		"zz:zz:zz:zz:zz:zz":  "",
+		"10.99.0.9":          "",
+		"00:01:05:AA:BB:01:": "",
+	}
+
+	for id, want := range tests {
+		if got := historyMACForID(id); got != want {
+			t.Errorf("historyMACForID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestJSONEventPathRejectsTraversal(t *testing.T) {
+	s := newJSONStorage(t.TempDir())
+
+	for _, key := range []string{"-/../../audit", `..\audit`, ".."} {
+		if _, err := s.eventPath(historyStream, key); err == nil {
+			t.Errorf("eventPath accepted key %q", key)
+		}
+	}
+
+	if _, err := s.eventPath(historyStream, "00:01:05:AA:BB:01"); err != nil {
+		t.Errorf("eventPath rejected mac key: %v", err)
+	}
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ------------------------------------------------------------
+// Sammel-Import von Büro-Zuordnungen und Kommentaren
+// ------------------------------------------------------------
+//
+//	POST /api/import?dryRun=true        nur Vorschau (Diff), nichts speichern
+//	POST /api/import?preview=<hash>     gültige Zeilen übernehmen
+//
+// Die Vorschau liefert in "preview" eine Prüfsumme des Diffs. Wird sie
+// beim Übernehmen mitgeschickt, wird nur übernommen, wenn der Diff noch
+// genau der Vorschau entspricht; sonst 409 (Stand hat sich inzwischen
+// geändert, erneut Vorschau anzeigen). Ohne preview wird der aktuelle
+// Diff übernommen (Skripte).
+//
+// Body ist die CSV-Datei direkt oder als Feld "file" (multipart).
+// Trennzeichen Semikolon oder Komma, Spalten: MAC, Büro, Kommentar.
+//
+// Mit Kopfzeile werden die Spalten über den Namen gefunden
+// (mac/mac-adresse, office/büro/raum, comment/kommentar), so kann auch
+// eine Exportdatei (/api/export?format=csv) wieder eingelesen werden.
+// Fehlt die Kommentar-Spalte, bleiben Kommentare unverändert.
+//
+// Leere Zellen entfernen wie im Dashboard die Zuordnung bzw. den
+// Kommentar. Ungültige Zeilen werden übersprungen und gemeldet;
+// alle gültigen Änderungen werden in einem Schreibvorgang gespeichert.
+//
+// Beispiel:
+//
+//	MAC;Büro;Kommentar
+//	00:01:05:12:34:56;T4015;Prüfstand 4
+//	00-01-05-AB-CD-EF;T2004;
+
+const (
+	// maximale Größe der CSV-Datei
+	importMaxBytes = 5 << 20 // 5 MB
+)
+
+// importMutex verhindert, dass zwei Importe gleichzeitig übernommen werden.
+var importMutex sync.Mutex
+
+// errImportConflict: der Stand hat sich seit dem Diff geändert
+// (Büro gelöscht oder Zuordnung/Kommentar parallel bearbeitet).
+var errImportConflict = errors.New("import conflict")
+
+// importRow ist eine gültige Zeile der CSV-Datei.
+type importRow struct {
+	Line       int
+	MAC        string
+	Office     string
+	Comment    string
+	HasComment bool // Kommentar-Spalte vorhanden
+}
+
+// importChange ist eine Änderung im Diff.
+type importChange struct {
+	Line       int    `json:"line"`
+	MAC        string `json:"mac"`
+	Device     string `json:"device,omitempty"` // Hostname/IP, falls im Inventar
+	OldOffice  string `json:"oldOffice"`
+	NewOffice  string `json:"newOffice"`
+	OldComment string `json:"oldComment"`
+	NewComment string `json:"newComment"`
+}
+
+// importRejected ist eine übersprungene Zeile.
+type importRejected struct {
+	Line   int    `json:"line"`
+	MAC    string `json:"mac,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// importResult ist die Antwort von /api/import.
+type importResult struct {
+	DryRun    bool             `json:"dryRun"`
+	Preview   string           `json:"preview"`   // Prüfsumme des Diffs (importDiffHash)
+	Rows      int              `json:"rows"`      // gelesene Datenzeilen
+	Unchanged int              `json:"unchanged"` // gültig, aber ohne Änderung
+	Changes   []importChange   `json:"changes"`
+	Rejected  []importRejected `json:"rejected"`
+}
+
+// ------------------------------------------------------------
+// CSV lesen und prüfen
+// ------------------------------------------------------------
+
+// importColumns sind die erkannten Spaltennamen (klein geschrieben).
+var importColumns = map[string]string{
+	"mac":         "mac",
+	"mac-adresse": "mac",
+	"mac address": "mac",
+	"office":      "office",
+	"büro":        "office",
+	"buero":       "office",
+	"raum":        "office",
+	"comment":     "comment",
+	"kommentar":   "comment",
+}
+
+// parseImportMAC prüft eine MAC-Adresse und liefert die normalisierte Form.
+func parseImportMAC(s string) (string, bool) {
+	hw, err := net.ParseMAC(normalizeMAC(s))
+	if err != nil || len(hw) != 6 {
+		return "", false
+	}
+	return normalizeMAC(hw.String()), true
+}
+
+// parseImportCSV liest die Datei und prüft jede Zeile.
+//
+// Zeilennummern beziehen sich auf die Datei (cr.FieldPos), damit sie
+// auch bei mehrzeiligen Zellen und übersprungenen Leerzeilen stimmen.
+func parseImportCSV(data []byte) (rows []importRow, rejected []importRejected, total int, err error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	// Trennzeichen anhand der ersten Zeile erkennen
+	first := data
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	comma := ','
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		comma = ';'
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	rec, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, 0, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	// Ohne Kopfzeile: MAC, Büro, Kommentar
+	col := map[string]int{"mac": 0, "office": 1, "comment": 2}
+	hasHeader := false
+
+	header := make(map[string]int)
+	for i, name := range rec {
+		if key, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, dup := header[key]; !dup {
+				header[key] = i
+			}
+		}
+	}
+	if _, ok := header["mac"]; ok {
+		col = header
+		hasHeader = true
+	}
+	if _, ok := col["office"]; !ok {
+		return nil, nil, 0, errors.New("missing office column")
+	}
+
+	// Jede Zeile muss so viele Zellen haben wie die Kopfzeile
+	// (ohne Kopfzeile: bis einschließlich Büro). Eine fehlende
+	// Büro-Zelle würde sonst die Zuordnung stillschweigend löschen.
+	width := len(rec)
+	if !hasHeader {
+		width = col["office"] + 1
+	}
+
+	cell := func(rec []string, key string) (string, bool) {
+		i, ok := col[key]
+		if !ok || i >= len(rec) {
+			return "", false
+		}
+		return strings.TrimSpace(rec[i]), true
+	}
+
+	seen := make(map[string]int)
+
+	// Kopfzeile überspringen
+	if hasHeader {
+		rec, err = cr.Read()
+	}
+
+	for ; err != io.EOF; rec, err = cr.Read() {
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("invalid csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		// Leerzeilen ignorieren
+		if strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue
+		}
+		total++
+
+		rawMAC, _ := cell(rec, "mac")
+
+		if len(rec) < width {
+			rejected = append(rejected, importRejected{Line: line, MAC: rawMAC,
+				Reason: fmt.Sprintf("too few columns (%d of %d)", len(rec), width)})
+			continue
+		}
+
+		mac, ok := parseImportMAC(rawMAC)
+		if !ok {
+			rejected = append(rejected, importRejected{Line: line, MAC: rawMAC, Reason: "invalid mac"})
+			continue
+		}
+
+		if prev, dup := seen[mac]; dup {
+			rejected = append(rejected, importRejected{Line: line, MAC: mac,
+				Reason: "duplicate mac (line " + strconv.Itoa(prev) + ")"})
+			continue
+		}
+
+		office, _ := cell(rec, "office")
+		if !isValidOffice(office) {
+			rejected = append(rejected, importRejected{Line: line, MAC: mac, Reason: "invalid office " + strconv.Quote(office)})
+			continue
+		}
+
+		// von csvSafeCell (Export) vorangestelltes ' wieder entfernen;
+		// Kommentare werden wie im Dashboard ohne Leerraum gespeichert
+		comment, hasComment := cell(rec, "comment")
+		if len(comment) > 1 && comment[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(comment[1])) {
+			comment = strings.TrimSpace(comment[1:])
+		}
+
+		seen[mac] = line
+		rows = append(rows, importRow{Line: line, MAC: mac, Office: office, Comment: comment, HasComment: hasComment})
+	}
+
+	return rows, rejected, total, nil
+}
+
+// importDiff vergleicht die Zeilen mit den aktuellen Zuordnungen.
+func importDiff(rows []importRow) (changes []importChange, unchanged int) {
+	// MAC → Anzeigename aus dem Inventar
+	names := make(map[string]string)
+	inventoryMutex.Lock()
+	for _, dev := range inventory {
+		if dev == nil || dev.MACAddress == "" {
+			continue
+		}
+		name := dev.IP
+		if dev.Hostname != "" {
+			name = dev.Hostname + " / " + dev.IP
+		}
+		names[normalizeMAC(dev.MACAddress)] = name
+	}
+	inventoryMutex.Unlock()
+
+	for _, row := range rows {
+		c := importChange{
+			Line:       row.Line,
+			MAC:        row.MAC,
+			Device:     names[row.MAC],
+			OldOffice:  getOfficeForMAC(row.MAC),
+			NewOffice:  row.Office,
+			OldComment: getCommentForMAC(row.MAC),
+		}
+
+		c.NewComment = c.OldComment
+		if row.HasComment {
+			c.NewComment = row.Comment
+		}
+
+		if c.OldOffice == c.NewOffice && c.OldComment == c.NewComment {
+			unchanged++
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, unchanged
+}
+
+// importDiffHash bildet die Prüfsumme eines Diffs für den Abgleich
+// zwischen Vorschau und Übernahme.
+//
+// Device (Hostname/IP) ist nur Anzeige und geht nicht ein.
+func importDiffHash(changes []importChange) string {
+	h := sha256.New()
+	for _, c := range changes {
+		fmt.Fprintf(h, "%q;%q;%q;%q;%q\n", c.MAC, c.OldOffice, c.NewOffice, c.OldComment, c.NewComment)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ------------------------------------------------------------
+// Übernehmen
+// ------------------------------------------------------------
+
+// applyImport setzt alle Änderungen und speichert Büro-Zuordnungen und
+// Kommentare in einer Transaktion.
+//
+// Die Geräte im RAM werden erst nachgezogen, wenn Katalog-, Büro- und
+// Kommentar-Lock wieder frei sind: Scan und Dashboard nehmen diese
+// Locks unter inventoryMutex, die umgekehrte Reihenfolge würde
+// verklemmen.
+func applyImport(changes []importChange) error {
+	if err := saveImport(changes); err != nil {
+		return err
+	}
+
+	// Geräte im RAM nachziehen, damit Dashboard und API sofort stimmen
+	byMAC := make(map[string]importChange, len(changes))
+	for _, c := range changes {
+		byMAC[c.MAC] = c
+	}
+
+	inventoryMutex.Lock()
+	for _, dev := range inventory {
+		if dev == nil {
+			continue
+		}
+		if c, ok := byMAC[normalizeMAC(dev.MACAddress)]; ok {
+			dev.Office = c.NewOffice
+			dev.Comment = c.NewComment
+		}
+	}
+	inventoryMutex.Unlock()
+
+	return nil
+}
+
+// saveImport wendet die Änderungen auf Kopien an; erst wenn das
+// Speichern geklappt hat, ersetzen die Kopien den Stand im Speicher.
+// Büro- und Kommentar-Mutex bleiben dabei durchgehend gesperrt, damit
+// keine parallele Änderung aus dem Dashboard verloren geht. Der
+// Büro-Katalog bleibt ebenfalls gesperrt, damit kein Büro zwischen
+// Prüfung und Speichern gelöscht wird.
+//
+// WICHTIG:
+// Nicht unter inventoryMutex aufrufen.
+func saveImport(changes []importChange) error {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	for _, c := range changes {
+		if c.NewOffice != "" && officeIndexLocked(c.NewOffice) < 0 {
+			return fmt.Errorf("%w: office %q no longer exists", errImportConflict, c.NewOffice)
+		}
+	}
+
+	officeMutex.Lock()
+	defer officeMutex.Unlock()
+	commentMutex.Lock()
+	defer commentMutex.Unlock()
+
+	// Der Diff wurde ohne diese Locks gebildet: eine Änderung aus dem
+	// Dashboard seitdem nicht überschreiben
+	for _, c := range changes {
+		if officeAssignments[c.MAC] != c.OldOffice || deviceComments[c.MAC] != c.OldComment {
+			return fmt.Errorf("%w: %s was changed meanwhile", errImportConflict, c.MAC)
+		}
+	}
+
+	offices := make(map[string]string, len(officeAssignments))
+	for k, v := range officeAssignments {
+		offices[k] = v
+	}
+	comments := make(map[string]string, len(deviceComments))
+	for k, v := range deviceComments {
+		comments[k] = v
+	}
+
+	// wie setOfficeForMAC / setCommentForMAC: leer = entfernen
+	for _, c := range changes {
+		if c.NewOffice == "" {
+			delete(offices, c.MAC)
+		} else {
+			offices[c.MAC] = c.NewOffice
+		}
+		if c.NewComment == "" {
+			delete(comments, c.MAC)
+		} else {
+			comments[c.MAC] = c.NewComment
+		}
+	}
+
+	err := store.Update(func(tx StorageTx) error {
+		if err := tx.PutOfficeAssignments(offices); err != nil {
+			return err
+		}
+		return tx.PutComments(comments)
+	})
+	if err != nil {
+		return err
+	}
+
+	officeAssignments = offices
+	deviceComments = comments
+	return nil
+}
+
+// ------------------------------------------------------------
+// HTTP
+// ------------------------------------------------------------
+
+// readImportBody liest die CSV-Datei aus dem Body bzw. dem Feld "file".
+func readImportBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(importMaxBytes); err != nil {
+			return nil, err
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("missing file")
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	return io.ReadAll(r.Body)
+}
+
+// handleImport verarbeitet POST /api/import.
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid dryRun", http.StatusBadRequest)
+			return
+		}
+		dryRun = b
+	}
+
+	data, err := readImportBody(w, r)
+	if err != nil {
+		http.Error(w, "invalid upload (max 5 MB)", http.StatusBadRequest)
+		return
+	}
+
+	rows, rejected, total, err := parseImportCSV(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Diff, Abgleich mit der Vorschau und Übernahme unter einem Lock,
+	// damit kein zweiter Import dazwischen speichert
+	importMutex.Lock()
+	defer importMutex.Unlock()
+
+	changes, unchanged := importDiff(rows)
+	hash := importDiffHash(changes)
+
+	if preview := r.URL.Query().Get("preview"); !dryRun && preview != "" && preview != hash {
+		http.Error(w, "inventory changed since preview, please preview again", http.StatusConflict)
+		return
+	}
+
+	result := importResult{
+		DryRun:    dryRun,
+		Preview:   hash,
+		Rows:      total,
+		Unchanged: unchanged,
+		Changes:   changes,
+		Rejected:  rejected,
+	}
+	if result.Changes == nil {
+		result.Changes = []importChange{}
+	}
+	if result.Rejected == nil {
+		result.Rejected = []importRejected{}
+	}
+
+	if dryRun || len(changes) == 0 {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	err = applyImport(changes)
+
+	appendAudit(r, auditDeviceImport, "",
+		fmt.Sprintf("%d rows, %d changes, %d rejected", total, len(changes), len(rejected)), err)
+
+	if errors.Is(err, errImportConflict) {
+		http.Error(w, err.Error()+", please preview again", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save import", http.StatusInternalServerError)
+		return
+	}
+
+	// Einzeländerungen wie im Dashboard protokollieren und verteilen
+	for _, c := range changes {
+		if c.OldOffice != c.NewOffice {
+			appendAuditChange(r, auditDeviceOffice, c.MAC, c.OldOffice, c.NewOffice, nil)
+		}
+		if c.OldComment != c.NewComment {
+			appendAuditChange(r, auditDeviceComment, c.MAC, c.OldComment, c.NewComment, nil)
+		}
+		publishDevicesByMAC(c.MAC)
+	}
+
+	fmt.Printf("Import: %d Änderungen übernommen, %d Zeilen abgelehnt\n", len(changes), len(rejected))
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleImportPage zeigt /import: Datei wählen, Vorschau, Übernehmen.
+func handleImportPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 1100px; }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            h2 { font-size: 1.05em; font-weight: 600; margin: 24px 0 8px; }
+            table { border-collapse: collapse; width: 100%; margin-bottom: 20px; }
+            th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #eee; font-size: 0.9em; vertical-align: top; }
+            th { background: #fafafa; }
+            textarea { width: 100%; height: 160px; font-family: monospace; border: 1px solid #ddd; border-radius: 4px; padding: 6px; }
+            .hint { color: #888; font-size: 0.85em; }
+            .old { color: #ce1126; text-decoration: line-through; }
+            .new { color: #28a745; }
+            .ok { background: #d4edda; border: 1px solid #b7dfc1; padding: 10px; border-radius: 4px; margin: 12px 0; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+            button:disabled { opacity: 0.4; cursor: default; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Import Büros / Kommentare</h1>
+            </div>
+
+            <p class="hint">CSV mit den Spalten MAC; Büro; Kommentar (Semikolon oder Komma, Kopfzeile optional).
+                Leere Zellen entfernen die Zuordnung bzw. den Kommentar; ohne Kommentar-Spalte bleiben Kommentare unverändert.</p>
+
+            <input type="file" id="file" accept=".csv,text/csv">
+            <textarea id="csv" placeholder="MAC;Büro;Kommentar&#10;00:01:05:12:34:56;T4015;Prüfstand 4"></textarea>
+            <p>
+                <button id="previewBtn">Vorschau</button>
+                <button id="applyBtn" disabled>Übernehmen</button>
+            </p>
+
+            <div id="result"></div>
+        </div>
+
+        <script>
+            const csv = document.getElementById("csv");
+            const result = document.getElementById("result");
+            const applyBtn = document.getElementById("applyBtn");
+
+            // Prüfsumme der letzten Vorschau; übernommen wird nur genau dieser Diff
+            let preview = "";
+
+            function esc(s) {
+                return String(s ?? "").replace(/[&<>"']/g, c => ({"&":"&amp;","<":"&lt;",">":"&gt;","\"":"&quot;","'":"&#39;"}[c]));
+            }
+
+            function diffCell(oldV, newV) {
+                if (oldV === newV) return esc(newV);
+                return (oldV ? '<span class="old">' + esc(oldV) + '</span> ' : '') +
+                    '<span class="new">' + (newV ? esc(newV) : '(leer)') + '</span>';
+            }
+
+            document.getElementById("file").addEventListener("change", e => {
+                const f = e.target.files[0];
+                if (!f) return;
+                const reader = new FileReader();
+                reader.onload = () => { csv.value = reader.result; applyBtn.disabled = true; };
+                reader.readAsText(f);
+            });
+
+            csv.addEventListener("input", () => { applyBtn.disabled = true; });
+
+            async function run(dryRun) {
+                const query = dryRun ? "?dryRun=true" : "?preview=" + encodeURIComponent(preview);
+                const res = await fetch("/api/import" + query, {
+                    method: "POST",
+                    headers: { "Content-Type": "text/csv; charset=utf-8" },
+                    body: csv.value
+                });
+                if (!res.ok) {
+                    result.innerHTML = '<p class="old">Fehler: ' + esc(await res.text()) + '</p>';
+                    applyBtn.disabled = true;
+                    return;
+                }
+                const r = await res.json();
+                if (dryRun) preview = r.preview;
+
+                let html = dryRun
+                    ? "<h2>Vorschau</h2>"
+                    : '<div class="ok">' + r.changes.length + " Änderungen übernommen.</div>";
+                html += "<p>" + r.rows + " Zeilen, " + r.changes.length + " Änderungen, " +
+                    r.unchanged + " unverändert, " + r.rejected.length + " abgelehnt.</p>";
+
+                if (r.changes.length) {
+                    html += "<table><tr><th>Zeile</th><th>MAC</th><th>Gerät</th><th>Büro</th><th>Kommentar</th></tr>";
+                    r.changes.forEach(c => {
+                        html += "<tr><td>" + c.line + "</td><td>" + esc(c.mac) + "</td><td>" + esc(c.device) + "</td><td>" +
+                            diffCell(c.oldOffice, c.newOffice) + "</td><td>" + diffCell(c.oldComment, c.newComment) + "</td></tr>";
+                    });
+                    html += "</table>";
+                }
+
+                if (r.rejected.length) {
+                    html += "<h2>Abgelehnte Zeilen</h2><table><tr><th>Zeile</th><th>MAC</th><th>Grund</th></tr>";
+                    r.rejected.forEach(x => {
+                        html += "<tr><td>" + x.line + "</td><td>" + esc(x.mac) + "</td><td>" + esc(x.reason) + "</td></tr>";
+                    });
+                    html += "</table>";
+                }
+
+                result.innerHTML = html;
+                applyBtn.disabled = !dryRun || r.changes.length === 0;
+            }
+
+            document.getElementById("previewBtn").onclick = () => run(true);
+            applyBtn.onclick = () => {
+                if (confirm("Änderungen übernehmen?")) run(false);
+            };
+        </script>
+    </body>
+    </html>
+`)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}, {Name: "T2004"}}
+	officeCatalogMutex.Unlock()
+
+	data := "MAC;Büro;Kommentar\r\n" +
+		"00:01:05:00:00:01;T4015;\"zwei\nZeilen\"\r\n" + // Zeile 2-3
+		"\r\n" + // Zeile 4 leer
+		"00:01:05:00:00:02;T2004\r\n" + // Zeile 5: Kommentar-Zelle fehlt
+		"00:01:05:00:00:03\r\n" + // Zeile 6: Büro-Zelle fehlt
+		"00-01-05-00-00-04;;\r\n" + // Zeile 7: Zuordnung entfernen
+		"00:01:05:00:00:01;T2004;\r\n" // Zeile 8: doppelt
+
+	rows, rejected, total, err := parseImportCSV([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 5 {
+		t.Errorf("total = %d, want 5", total)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("rows = %+v, want 2", rows)
+	}
+	if rows[0].Line != 2 || rows[0].Comment != "zwei\nZeilen" {
+		t.Errorf("rows[0] = %+v", rows[0])
+	}
+	if rows[1].Line != 7 || rows[1].MAC != "00:01:05:00:00:04" || rows[1].Office != "" {
+		t.Errorf("rows[1] = %+v", rows[1])
+	}
+
+	want := map[int]string{5: "too few columns", 6: "too few columns", 8: "duplicate mac (line 2)"}
+	if len(rejected) != len(want) {
+		t.Fatalf("rejected = %+v, want %d", rejected, len(want))
+	}
+	for _, r := range rejected {
+		if !strings.HasPrefix(r.Reason, want[r.Line]) || want[r.Line] == "" {
+			t.Errorf("line %d: reason %q, want %q", r.Line, r.Reason, want[r.Line])
+		}
+	}
+}
+
+func TestParseImportCSVWithoutHeader(t *testing.T) {
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}}
+	officeCatalogMutex.Unlock()
+
+	data := "00:01:05:00:00:01,T4015\n00:01:05:00:00:02\n"
+
+	rows, rejected, _, err := parseImportCSV([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0].Line != 1 || rows[0].HasComment {
+		t.Errorf("rows = %+v", rows)
+	}
+	if len(rejected) != 1 || rejected[0].Line != 2 {
+		t.Errorf("rejected = %+v, want line 2", rejected)
+	}
+}
+
+func TestApplyImport(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}}
+	officeCatalogMutex.Unlock()
+
+	officeAssignments["00:01:05:00:00:01"] = "T4015"
+	deviceComments["00:01:05:00:00:01"] = "alt"
+
+	// Büro existiert nicht mehr: nichts übernehmen
+	err := applyImport([]importChange{{MAC: "00:01:05:00:00:02", NewOffice: "T2004"}})
+	if err == nil {
+		t.Fatal("applyImport with deleted office succeeded")
+	}
+	if len(officeAssignments) != 1 || len(deviceComments) != 1 {
+		t.Errorf("state changed after failed import: %v %v", officeAssignments, deviceComments)
+	}
+
+	err = applyImport([]importChange{
+		{MAC: "00:01:05:00:00:01", OldOffice: "T4015", OldComment: "alt"},
+		{MAC: "00:01:05:00:00:02", NewOffice: "T4015", NewComment: "neu"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := officeAssignments["00:01:05:00:00:01"]; ok {
+		t.Errorf("assignment for :01 not removed")
+	}
+	if _, ok := deviceComments["00:01:05:00:00:01"]; ok {
+		t.Errorf("comment for :01 not removed")
+	}
+	if officeAssignments["00:01:05:00:00:02"] != "T4015" || deviceComments["00:01:05:00:00:02"] != "neu" {
+		t.Errorf("state = %v %v", officeAssignments, deviceComments)
+	}
+}
+
+func TestApplyImportDuringScan(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}, {Name: "T2004"}}
+	officeCatalogMutex.Unlock()
+
+	p := &fakeProber{
+		up:   map[string]bool{"10.99.0.2": true, "10.99.0.3": true},
+		macs: map[string]string{"10.99.0.2": "00:01:05:00:00:01", "10.99.0.3": "00:01:05:00:00:02"},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				runScanPass(p, noDiscovery)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			offices := []string{"T4015", "T2004"}
+			old1, old2 := "", ""
+			for i := 0; i < 200; i++ {
+				new1, new2 := offices[i%2], offices[(i+1)%2]
+				err := applyImport([]importChange{
+					{MAC: "00:01:05:00:00:01", OldOffice: old1, NewOffice: new1},
+					{MAC: "00:01:05:00:00:02", OldOffice: old2, NewOffice: new2},
+				})
+				old1, old2 = new1, new2
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatal("applyImport and runScanPass deadlocked")
+	}
+}
+
+func TestHandleImportPreviewMismatch(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}, {Name: "T2004"}}
+	officeCatalogMutex.Unlock()
+
+	const data = "00:01:05:00:00:01;T4015\n"
+
+	post := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/import"+query, strings.NewReader(data))
+		rec := httptest.NewRecorder()
+		handleImport(rec, req)
+		return rec
+	}
+
+	rec := post("?dryRun=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dry run: %d %s", rec.Code, rec.Body)
+	}
+	var res importResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Preview == "" || len(res.Changes) != 1 {
+		t.Fatalf("dry run result = %+v", res)
+	}
+
+	// Stand ändert sich zwischen Vorschau und Übernahme
+	setOfficeForMAC("00:01:05:00:00:01", "T2004")
+
+	if rec := post("?preview=" + res.Preview); rec.Code != http.StatusConflict {
+		t.Errorf("apply after change: %d, want 409", rec.Code)
+	}
+	if got := getOfficeForMAC("00:01:05:00:00:01"); got != "T2004" {
+		t.Errorf("office = %q after rejected apply, want T2004", got)
+	}
+
+	rec = post("?dryRun=true")
+	json.Unmarshal(rec.Body.Bytes(), &res)
+
+	if rec := post("?preview=" + res.Preview); rec.Code != http.StatusOK {
+		t.Errorf("apply with current preview: %d %s", rec.Code, rec.Body)
+	}
+	if got := getOfficeForMAC("00:01:05:00:00:01"); got != "T4015" {
+		t.Errorf("office = %q after apply, want T4015", got)
+	}
+}
+
+func TestApplyImportConflict(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}, {Name: "T2004"}}
+	officeCatalogMutex.Unlock()
+
+	rows, _, _, err := parseImportCSV([]byte("00:01:05:00:00:01;T4015;neu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, _ := importDiff(rows)
+
+	// Dashboard-Änderung zwischen Diff und Übernahme
+	setOfficeForMAC("00:01:05:00:00:01", "T2004")
+
+	if err := applyImport(changes); !errors.Is(err, errImportConflict) {
+		t.Fatalf("applyImport = %v, want conflict", err)
+	}
+	if got := getOfficeForMAC("00:01:05:00:00:01"); got != "T2004" {
+		t.Errorf("office = %q, dashboard change overwritten", got)
+	}
+	if got := getCommentForMAC("00:01:05:00:00:01"); got != "" {
+		t.Errorf("comment = %q, want unchanged", got)
+	}
+}
@@ -3,35 +3,67 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 )
 
 // ------------------------------------------------------------
 // HTTP-Handler
 // ------------------------------------------------------------
 
-// handleTriggerScan stößt manuell einen neuen Netzwerkscan an.
-
+// handleTriggerScan stößt manuell einen neuen Netzwerkscan an (POST).
+//
+// Nur POST: ein GET ließe sich per Link von einer fremden Seite auslösen,
+// das SameSite=Lax-Cookie wird bei solchen Navigationen mitgeschickt.
+//
 // Der Trigger wird nicht blockierend in den Kanal geschrieben.
 // Ist bereits ein Trigger vorhanden, wird einfach nichts weiter getan.
 func handleTriggerScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	fmt.Println("Manueller Scan-Trigger empfangen.")
 
 	select {
 	case scanTrigger <- struct{}{}:
+		appendAudit(r, auditScanTrigger, "all", "", nil)
 	default:
+		appendAudit(r, auditScanTrigger, "all", "already pending", nil)
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func handleScanStatus(w http.ResponseWriter, r *http.Request) {
-	inventoryMutex.Lock()
-	scanning := isScanning
-	inventoryMutex.Unlock()
+// handleConfig liefert die effektive Konfiguration als JSON.
+//
+// Die Werte entsprechen dem Stand nach Datei, Umgebungsvariablen
+// und Flags, also genau dem, womit der Server tatsächlich läuft.
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := struct {
+		ConfigFile string `json:"configFile"`
+		Config
+	}{
+		ConfigFile: cfgPath,
+		Config:     cfg,
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"isScanning": %t}`, scanning)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleScanStatus liefert den Fortschritt des laufenden
+// und das Ergebnis des letzten Scans (siehe scan_state.go).
+func handleScanStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, getScanStatus())
 }
 
 // handleDashboard rendert die HTML-Übersichtsseite.
@@ -43,6 +75,7 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	model := buildDashboardModel()
+	model.User = requestUser(r)
 	renderDashboard(w, model)
 }
 
@@ -64,8 +97,8 @@ type officeAssignmentRequest struct {
 	Office string `json:"office"`
 }
 
-// isValidOffice prüft, ob ein Office-Wert in der zentralen Liste validOffices enthalten ist.
-
+// isValidOffice prüft, ob ein Office-Wert im Büro-Katalog enthalten ist.
+//
 // Leerer String ist erlaubt und bedeutet:
 // vorhandene Zuordnung entfernen.
 func isValidOffice(office string) bool {
@@ -73,13 +106,13 @@ func isValidOffice(office string) bool {
 		return true
 	}
 
-	for _, valid := range validOffices {
-		if office == valid {
-			return true
-		}
-	}
+	return officeExists(office)
+}
 
-	return false
+// isValidOfficeLocked entspricht isValidOffice für Aufrufer,
+// die officeCatalogMutex bereits halten.
+func isValidOfficeLocked(office string) bool {
+	return office == "" || officeIndexLocked(office) >= 0
 }
 
 // handleOfficeAssignment verarbeitet Office-Zuordnungen aus dem Frontend.
@@ -110,19 +143,29 @@ func handleOfficeAssignment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !isValidOffice(req.Office) {
+	// Prüfen und Speichern unter officeCatalogMutex,
+	// damit deleteOffice das Büro nicht dazwischen entfernt.
+	officeCatalogMutex.Lock()
+	if req.Office != "" && officeIndexLocked(req.Office) < 0 {
+		officeCatalogMutex.Unlock()
 		http.Error(w, "invalid office", http.StatusBadRequest)
 		return
 	}
 
-	// Zuordnung persistent setzen
+	// Zuordnung persistent setzen, alten Wert fürs Audit-Log merken
+	oldOffice := getOfficeForMAC(req.MAC)
 	setOfficeForMAC(req.MAC, req.Office)
+	err := saveOfficeAssignments()
+	officeCatalogMutex.Unlock()
 
-	if err := saveOfficeAssignments(); err != nil {
+	if err != nil {
+		appendAuditChange(r, auditDeviceOffice, req.MAC, oldOffice, req.Office, err)
 		http.Error(w, "failed to save office assignment", http.StatusInternalServerError)
 		return
 	}
 
+	appendAuditChange(r, auditDeviceOffice, req.MAC, oldOffice, req.Office, nil)
+
 	// Bereits geladene Geräte im RAM sofort aktualisieren,
 	// damit das Dashboard ohne Neustart den neuen Wert zeigt.
 	inventoryMutex.Lock()
@@ -133,6 +176,9 @@ func handleOfficeAssignment(w http.ResponseWriter, r *http.Request) {
 	}
 	inventoryMutex.Unlock()
 
+	// Andere offene Dashboards aktualisieren
+	publishDevicesByMAC(req.MAC)
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -181,13 +227,17 @@ func handleCommentAssignment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	oldComment := getCommentForMAC(req.MAC)
 	setCommentForMAC(req.MAC, req.Comment)
 
 	if err := saveComments(); err != nil {
+		appendAuditChange(r, auditDeviceComment, req.MAC, oldComment, req.Comment, err)
 		http.Error(w, "failed to save comment", http.StatusInternalServerError)
 		return
 	}
 
+	appendAuditChange(r, auditDeviceComment, req.MAC, oldComment, req.Comment, nil)
+
 	// Bereits geladene Geräte im RAM sofort aktualisieren,
 	// damit das Dashboard direkt den neuen Kommentar anzeigt.
 	inventoryMutex.Lock()
@@ -198,6 +248,9 @@ func handleCommentAssignment(w http.ResponseWriter, r *http.Request) {
 	}
 	inventoryMutex.Unlock()
 
+	// Andere offene Dashboards aktualisieren
+	publishDevicesByMAC(req.MAC)
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -208,41 +261,132 @@ func handleCommentAssignment(w http.ResponseWriter, r *http.Request) {
 // main ist der Einstiegspunkt des Programms.
 
 // Reihenfolge:
+// 0. Konfiguration (Datei + Flags) laden, Storage öffnen
 // 1. Snapshot laden
 // 2. Hintergrund-Discovery starten
 // 3. HTTP-Routen registrieren
 // 4. Zusatzdaten (Office / Comments) laden
 // 5. Webserver starten
 func main() {
+	// Konfiguration laden; ohne gültige Scan-Bereiche ist kein Scan möglich.
+	c, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Println("Config error:", err)
+		os.Exit(2)
+	}
+	cfg = c
+
+	fmt.Println("Konfiguration:", cfgPath)
+	fmt.Println("Datenverzeichnis:", cfg.DataDir)
+	fmt.Println("Scan-Bereiche:", scanRangesString())
+
+	// Persistenz-Backend öffnen (bei bbolt inkl. einmaliger JSON-Migration)
+	st, err := openStorage(cfg)
+	if err != nil {
+		fmt.Println("Storage error:", err)
+		os.Exit(1)
+	}
+	defer st.Close()
+	store = st
+
+	fmt.Println("Storage:", store.Name())
+
 	// Vorhandenen Snapshot laden, damit bekannte Geräte und Zustände
 	// beim Start sofort wieder verfügbar sind.
 	if err := loadSnapshot(); err != nil {
 		fmt.Println("Snapshot load error:", err)
 	}
 
+	// Büro-Katalog (wird beim ersten Start aus defaultOffices angelegt)
+	if err := loadOfficeCatalog(); err != nil {
+		fmt.Println("Office catalog load error:", err)
+		os.Exit(1)
+	}
+
+	// Lagepläne (nur Liste und Bereiche, Bilder werden bei Bedarf gelesen)
+	if err := loadFloorPlans(); err != nil {
+		fmt.Println("Floor plan load error:", err)
+	}
+
+	// Zugangsdaten für ADS-Routen (verschlüsselt, Schlüssel aus der Umgebung)
+	if err := loadRouteCredentials(); err != nil {
+		fmt.Println("Credential load error:", err)
+		os.Exit(1)
+	}
+
+	// Benutzer (legt beim ersten Start den Administrator an)
+	if err := loadUsers(); err != nil {
+		fmt.Println("User load error:", err)
+		os.Exit(1)
+	}
+
+	// Alarm-Regeln und Entprell-Zustand
+	if err := loadAlerts(); err != nil {
+		fmt.Println("Alert load error:", err)
+	}
+
 	// Hintergrund-Scan starten
 	go runDiscovery()
 
 	// Statische Dateien und HTTP-Endpunkte registrieren
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	http.HandleFunc("/", handleDashboard)
-	http.HandleFunc("/trigger-scan", handleTriggerScan)
-	http.HandleFunc("/api/office", handleOfficeAssignment)
-	http.HandleFunc("/api/comment", handleCommentAssignment)
-	http.HandleFunc("/api/scan-status", handleScanStatus)
+	// Anmeldung (ohne Rollenprüfung erreichbar)
+	http.HandleFunc("/login", handleLogin)
+	http.HandleFunc("/logout", handleLogout)
+
+	// Jede Route mit Rolle für lesende (GET) und ändernde Aufrufe, siehe auth.go
+	http.HandleFunc("/", requireRole(roleViewer, roleViewer, handleDashboard))
+	http.HandleFunc("/trigger-scan", requireRole(roleEditor, roleEditor, handleTriggerScan))
+	http.HandleFunc("/api/office", requireRole(roleEditor, roleEditor, handleOfficeAssignment))
+	http.HandleFunc("/api/comment", requireRole(roleEditor, roleEditor, handleCommentAssignment))
+	http.HandleFunc("/api/scan-status", requireRole(roleViewer, roleViewer, handleScanStatus))
+	http.HandleFunc("/api/config", requireRole(roleAdmin, roleAdmin, handleConfig))
+	http.HandleFunc("/api/devices", requireRole(roleViewer, roleViewer, handleDevices))
+	http.HandleFunc("/api/devices/{id}", requireRole(roleViewer, roleViewer, handleDevice))
+	http.HandleFunc("/api/devices/{id}/history", requireRole(roleViewer, roleViewer, handleDeviceHistory))
+	http.HandleFunc("/api/devices/{id}/state", requireRole(roleAdmin, roleAdmin, handleDeviceState))
+	http.HandleFunc("/api/devices/{id}/routes", requireRole(roleViewer, roleAdmin, handleDeviceRoutes))
+	http.HandleFunc("/api/devices/{id}/rescan", requireRole(roleEditor, roleEditor, handleDeviceRescan))
+	http.HandleFunc("/api/import", requireRole(roleEditor, roleEditor, handleImport))
+	http.HandleFunc("/import", requireRole(roleEditor, roleEditor, handleImportPage))
+	http.HandleFunc("/api/export", requireRole(roleViewer, roleViewer, handleExport))
+	http.HandleFunc("/history", requireRole(roleViewer, roleViewer, handleHistoryPage))
+	http.HandleFunc("/api/credentials", requireRole(roleAdmin, roleAdmin, handleCredentials))
+	http.HandleFunc("/credentials", requireRole(roleAdmin, roleAdmin, handleCredentialsPage))
+	http.HandleFunc("/metrics", requireRole(roleViewer, roleViewer, handleMetrics))
+	http.HandleFunc("/api/events", requireRole(roleViewer, roleViewer, handleEvents))
+	http.HandleFunc("/api/alerts/rules", requireRole(roleAdmin, roleAdmin, handleAlertRules))
+	http.HandleFunc("/api/alerts/rules/{id}/test", requireRole(roleAdmin, roleAdmin, handleAlertRuleTest))
+	http.HandleFunc("/api/alerts/log", requireRole(roleAdmin, roleAdmin, handleAlertLog))
+	http.HandleFunc("/alerts", requireRole(roleAdmin, roleAdmin, handleAlertsPage))
+	http.HandleFunc("/api/me", requireRole(roleViewer, roleViewer, handleMe))
+	http.HandleFunc("/api/users", requireRole(roleAdmin, roleAdmin, handleUsers))
+	http.HandleFunc("/users", requireRole(roleAdmin, roleAdmin, handleUsersPage))
+	http.HandleFunc("/api/offices", requireRole(roleViewer, roleAdmin, handleOffices))
+	http.HandleFunc("/api/offices/{name}", requireRole(roleViewer, roleAdmin, handleOffice))
+	http.HandleFunc("/offices", requireRole(roleViewer, roleViewer, handleOfficesPage))
+	http.HandleFunc("/api/floorplans", requireRole(roleViewer, roleAdmin, handleFloorPlans))
+	http.HandleFunc("/api/floorplans/status", requireRole(roleViewer, roleViewer, handleFloorPlanStatus))
+	http.HandleFunc("/api/floorplans/{id}", requireRole(roleViewer, roleAdmin, handleFloorPlan))
+	http.HandleFunc("/api/floorplans/{id}/image", requireRole(roleViewer, roleViewer, handleFloorPlanImage))
+	http.HandleFunc("/floorplan", requireRole(roleViewer, roleViewer, handleFloorPlanPage))
+	http.HandleFunc("/api/audit", requireRole(roleAdmin, roleAdmin, handleAudit))
+	http.HandleFunc("/audit", requireRole(roleAdmin, roleAdmin, handleAuditPage))
 
 	fmt.Println("-----------------------------------------------")
-	const port = 18080
+	port := cfg.Port
 
 	fmt.Println("Beckhoff Inventar-Server Online")
 	fmt.Printf("Lokal: http://localhost:%d\n", port)
 
-	// Lokale Testnetz-IP ermitteln, damit in der Konsole direkt
-	// die Netzwerk-URL angezeigt werden kann.
-	if ip, _, err := getLocalLabIPv4(); err == nil {
-		fmt.Printf("Netz:  http://%s:%d\n", ip.String(), port)
+	// Lokale Testnetz-IPs ermitteln, damit in der Konsole direkt
+	// die Netzwerk-URLs angezeigt werden können.
+	if addrs, err := getLocalLabAddrs(); err == nil {
+		for _, a := range addrs {
+			fmt.Printf("Netz:  http://%s:%d\n", a.IP.String(), port)
+		}
 	} else {
-		fmt.Println("Netz:  (keine 172.17.76.x Adresse gefunden)")
+		fmt.Printf("Netz:  (keine Adresse in %s gefunden)\n", scanRangesString())
 	}
 
 	fmt.Println("-----------------------------------------------")
@@ -262,7 +406,8 @@ func main() {
 	}
 
 	// HTTP-Server starten
-	if err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", port), nil); err != nil {
+	addr := net.JoinHostPort(cfg.ListenAddr, strconv.Itoa(port))
+	if err := http.ListenAndServe(addr, nil); err != nil {
 		fmt.Println("ListenAndServe error:", err)
 	}
 }
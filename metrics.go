@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Prometheus-Metriken
+// ------------------------------------------------------------
+//
+// GET /metrics liefert die Kennzahlen im Prometheus-Textformat
+// (Version 0.0.4). Das Format ist einfach genug, um es ohne
+// Client-Bibliothek direkt zu schreiben.
+//
+// Scan-Dauern und Zähler werden während des Scans gesammelt,
+// Geräte-Metriken beim Abruf aus dem Inventory berechnet.
+//
+// Beispiel-Scrape-Config:
+//
+//	- job_name: labor-inventar
+//	  static_configs:
+//	    - targets: ["inventar-host:18080"]
+
+// scanMetrics enthält die während der Scans gesammelten Werte.
+type scanMetrics struct {
+	scansTotal     uint64
+	lastScanEnd    time.Time
+	lastScanTotal  time.Duration
+	phaseDurations map[string]time.Duration // Dauer je Phase im letzten Scan
+	adsErrors      map[uint32]uint64        // ADS-Fehler je Fehlercode seit Programmstart
+	routeFailures  uint64                   // fehlgeschlagene AddRoute-Versuche seit Programmstart
+}
+
+var (
+	metrics = scanMetrics{
+		phaseDurations: make(map[string]time.Duration),
+		adsErrors:      make(map[uint32]uint64),
+	}
+
+	// metricsMutex schützt metrics.
+	metricsMutex sync.Mutex
+)
+
+// recordScanPhase merkt sich die Dauer einer Scan-Phase.
+func recordScanPhase(phase string, d time.Duration) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	metrics.phaseDurations[phase] = d
+}
+
+// recordScanDone zählt einen abgeschlossenen Scan.
+func recordScanDone(total time.Duration) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	metrics.scansTotal++
+	metrics.lastScanTotal = total
+	metrics.lastScanEnd = time.Now()
+}
+
+// recordAdsError zählt den ADS-Fehlercode aus err (ADS-Result oder
+// AMS-Header). Gezählt wird beim Aufrufer, nicht im AdsClient: nur er
+// weiß, welche Codes erwartet sind (z. B. 0x6 beim Abtasten der
+// Runtime-Ports) – diese stehen in expected und werden nicht gezählt.
+func recordAdsError(err error, expected ...uint32) {
+	code, ok := adsResultCode(err)
+	if !ok || slices.Contains(expected, code) {
+		return
+	}
+
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	metrics.adsErrors[code]++
+}
+
+// recordRouteFailure zählt einen fehlgeschlagenen AddRoute-Versuch.
+func recordRouteFailure() {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	metrics.routeFailures++
+}
+
+// ------------------------------------------------------------
+// Textformat
+// ------------------------------------------------------------
+
+// promLabelEscaper maskiert Label-Werte nach Prometheus-Regeln.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels baut "{k1="v1",k2="v2"}" aus abwechselnden Key/Value-Paaren.
+func promLabels(kv ...string) string {
+	if len(kv) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(kv[i])
+		sb.WriteString(`="`)
+		sb.WriteString(promLabelEscaper.Replace(kv[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
+// promHeader schreibt HELP- und TYPE-Zeile einer Metrik.
+func promHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// promBool wandelt einen Wahrheitswert in 0/1.
+func promBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writeMetrics schreibt alle Metriken im Prometheus-Textformat.
+func writeMetrics(w io.Writer) {
+	// Scan-Werte kopieren, damit das Schreiben nicht unter Lock passiert
+	metricsMutex.Lock()
+	m := metrics
+	phases := make(map[string]time.Duration, len(metrics.phaseDurations))
+	for k, v := range metrics.phaseDurations {
+		phases[k] = v
+	}
+	adsErrors := make(map[uint32]uint64, len(metrics.adsErrors))
+	for k, v := range metrics.adsErrors {
+		adsErrors[k] = v
+	}
+	metricsMutex.Unlock()
+
+	inventoryMutex.Lock()
+	scanning := isScanning
+	inventoryMutex.Unlock()
+
+	model := buildDashboardModel()
+
+	// --- Scan ---
+	promHeader(w, "inventar_scans_total", "counter", "Number of completed network scans.")
+	fmt.Fprintf(w, "inventar_scans_total %d\n", m.scansTotal)
+
+	promHeader(w, "inventar_scan_running", "gauge", "Whether a scan is currently running.")
+	fmt.Fprintf(w, "inventar_scan_running %d\n", promBool(scanning))
+
+	promHeader(w, "inventar_scan_duration_seconds", "gauge", "Duration of the last complete scan.")
+	fmt.Fprintf(w, "inventar_scan_duration_seconds %g\n", m.lastScanTotal.Seconds())
+
+	promHeader(w, "inventar_scan_phase_duration_seconds", "gauge", "Duration of each phase of the last scan.")
+	for _, phase := range scanPhaseOrder {
+		fmt.Fprintf(w, "inventar_scan_phase_duration_seconds%s %g\n",
+			promLabels("phase", phase), phases[phase].Seconds())
+	}
+
+	promHeader(w, "inventar_last_scan_timestamp_seconds", "gauge", "Unix time of the end of the last scan.")
+	if !m.lastScanEnd.IsZero() {
+		fmt.Fprintf(w, "inventar_last_scan_timestamp_seconds %d\n", m.lastScanEnd.Unix())
+	} else {
+		fmt.Fprintln(w, "inventar_last_scan_timestamp_seconds 0")
+	}
+
+	// --- Geräte (DashboardStats) ---
+	promHeader(w, "inventar_devices", "gauge", "Number of devices by state (as shown on the dashboard).")
+	fmt.Fprintf(w, "inventar_devices%s %d\n", promLabels("state", "online"), model.Stats.Online)
+	fmt.Fprintf(w, "inventar_devices%s %d\n", promLabels("state", "offline"), model.Stats.Offline)
+	fmt.Fprintf(w, "inventar_devices%s %d\n", promLabels("state", "known"), model.Stats.Known)
+
+	// Pro Gerät nur "erkannte" Geräte ausgeben; leere Adressen
+	// der Scan-Bereiche würden sonst tausende Serien erzeugen.
+	var devices []*IPC
+	for _, d := range model.Devices {
+		if d.MACAddress != "" || d.Hostname != "" || d.AmsNetID != "" {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].IP < devices[j].IP })
+
+	// Geräte-Serien nur über ip und mac identifizieren: Hostname und Büro
+	// ändern sich (Umbenennen) und würden sonst neue Serien beginnen.
+	// Beide stehen in inventar_device_info und lassen sich per Join ergänzen:
+	//
+	//	inventar_device_reachable * on(ip, mac) group_left(hostname, office) inventar_device_info
+	deviceLabels := func(d *IPC, extra ...string) string {
+		kv := []string{"ip", d.IP, "mac", d.MACAddress}
+		return promLabels(append(kv, extra...)...)
+	}
+
+	promHeader(w, "inventar_device_info", "gauge", "Hostname and office per device (value is always 1).")
+	for _, d := range devices {
+		fmt.Fprintf(w, "inventar_device_info%s 1\n", deviceLabels(d, "hostname", d.Hostname, "office", d.Office))
+	}
+
+	promHeader(w, "inventar_device_reachable", "gauge", "Whether the device answered in the last scan (1) or not (0).")
+	for _, d := range devices {
+		fmt.Fprintf(w, "inventar_device_reachable%s %d\n", deviceLabels(d), promBool(d.IsReachable))
+	}
+
+	promHeader(w, "inventar_device_last_seen_timestamp_seconds", "gauge", "Unix time the device was last seen online.")
+	for _, d := range devices {
+		if !d.LastSeenOnline.IsZero() {
+			fmt.Fprintf(w, "inventar_device_last_seen_timestamp_seconds%s %d\n", deviceLabels(d), d.LastSeenOnline.Unix())
+		}
+	}
+
+	// Runtime-State als Info-Metrik: Wert immer 1, State als Label.
+	// Port 10000 = TwinCAT-System, sonst PLC-Runtime.
+	// Fehlerdetails werden abgeschnitten (runtimeStatusBase), sonst
+	// entstünde für jede Fehlermeldung eine eigene Zeitreihe.
+	promHeader(w, "inventar_device_runtime_state", "gauge", "TwinCAT system and PLC runtime state per device (value is always 1).")
+	for _, d := range devices {
+		if d.AmsNetID == "" || d.RuntimeStatus == "" {
+			continue
+		}
+		fmt.Fprintf(w, "inventar_device_runtime_state%s 1\n",
+			deviceLabels(d, "port", fmt.Sprint(routeTcSystemService), "state", runtimeStatusBase(d.RuntimeStatus)))
+		for _, rt := range d.Runtimes {
+			fmt.Fprintf(w, "inventar_device_runtime_state%s 1\n",
+				deviceLabels(d, "port", fmt.Sprint(rt.Port), "state", runtimeStatusBase(rt.State)))
+		}
+	}
+
+	promHeader(w, "inventar_device_runtime_running", "gauge", "Whether the runtime is in RUN (1) or not (0).")
+	for _, d := range devices {
+		if d.AmsNetID == "" || d.RuntimeStatus == "" {
+			continue
+		}
+		fmt.Fprintf(w, "inventar_device_runtime_running%s %d\n",
+			deviceLabels(d, "port", fmt.Sprint(routeTcSystemService)), promBool(d.RuntimeStatus == "RUN"))
+		for _, rt := range d.Runtimes {
+			fmt.Fprintf(w, "inventar_device_runtime_running%s %d\n",
+				deviceLabels(d, "port", fmt.Sprint(rt.Port)), promBool(rt.State == "RUN"))
+		}
+	}
+
+	// --- ADS / Routen ---
+	promHeader(w, "inventar_ads_errors_total", "counter", "ADS errors returned by targets, by ADS error code (expected codes such as port not found while probing runtimes are not counted).")
+	codes := make([]uint32, 0, len(adsErrors))
+	for code := range adsErrors {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for _, code := range codes {
+		fmt.Fprintf(w, "inventar_ads_errors_total%s %d\n",
+			promLabels("code", fmt.Sprintf("0x%08X", code)), adsErrors[code])
+	}
+
+	promHeader(w, "inventar_route_failures_total", "counter", "Failed ADS AddRoute attempts.")
+	fmt.Fprintf(w, "inventar_route_failures_total %d\n", m.routeFailures)
+
+	promHeader(w, "inventar_device_route_failures", "gauge", "Consecutive failed AddRoute attempts per device.")
+	for _, d := range devices {
+		if d.AmsNetID == "" {
+			continue
+		}
+		fmt.Fprintf(w, "inventar_device_route_failures%s %d\n", deviceLabels(d), d.RouteFailures)
+	}
+
+	promHeader(w, "inventar_device_route_ok", "gauge", "Whether the ADS route to the device is known to work.")
+	for _, d := range devices {
+		if d.AmsNetID == "" {
+			continue
+		}
+		fmt.Fprintf(w, "inventar_device_route_ok%s %d\n", deviceLabels(d), promBool(d.RouteKnownGood))
+	}
+}
+
+// handleMetrics liefert GET /metrics.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetricsDeviceLabels(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	inventoryMutex.Lock()
+	inventory["10.99.0.2"] = &IPC{
+		IP:            "10.99.0.2",
+		MACAddress:    "00:01:05:AA:BB:01",
+		Hostname:      "plc1",
+		Office:        "T4015",
+		IsReachable:   true,
+		AmsNetID:      "10.99.0.2.1.1",
+		RuntimeStatus: "no Info (ReadState(port 10000): timeout)",
+	}
+	inventoryMutex.Unlock()
+
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		`inventar_device_info{ip="10.99.0.2",mac="00:01:05:AA:BB:01",hostname="plc1",office="T4015"} 1`,
+		`inventar_device_reachable{ip="10.99.0.2",mac="00:01:05:AA:BB:01"} 1`,
+		`inventar_device_runtime_state{ip="10.99.0.2",mac="00:01:05:AA:BB:01",port="10000",state="no Info"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "inventar_device_") && !strings.HasPrefix(line, "inventar_device_info") &&
+			(strings.Contains(line, "hostname=") || strings.Contains(line, "office=")) {
+			t.Errorf("hostname/office label outside inventar_device_info: %s", line)
+		}
+	}
+}
+
+// Erwartete Codes (Port ohne Runtime, Ende der Routentabelle) zählen nicht
+func TestRecordAdsErrorExpected(t *testing.T) {
+	metricsMutex.Lock()
+	saved := metrics.adsErrors
+	metrics.adsErrors = make(map[uint32]uint64)
+	metricsMutex.Unlock()
+	t.Cleanup(func() {
+		metricsMutex.Lock()
+		metrics.adsErrors = saved
+		metricsMutex.Unlock()
+	})
+
+	recordAdsError(AmsError{Code: adsErrTargetPortNotFound}, adsErrTargetPortNotFound)
+	recordAdsError(AdsError{Code: adsErrNoMoreEntries}, adsErrNoMoreEntries)
+	recordAdsError(errors.New("timeout"))
+	recordAdsError(nil)
+	recordAdsError(AdsError{Code: 0x710})
+
+	metricsMutex.Lock()
+	got := maps.Clone(metrics.adsErrors)
+	metricsMutex.Unlock()
+
+	if len(got) != 1 || got[0x710] != 1 {
+		t.Errorf("adsErrors = %v, want only 0x710 once", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ------------------------------------------------------------
+// Konfigurierte Scan-Bereiche
+// ------------------------------------------------------------
+
+// maxScanHosts begrenzt die Gesamtzahl der Adressen über alle Bereiche.
+//
+// Schutz vor Tippfehlern wie "172.17.0.0/8",
+// die sonst Millionen Inventory-Einträge erzeugen würden.
+const maxScanHosts = 65536
+
+// scanNets enthält die geparsten Scan-Bereiche aus der Konfiguration.
+//
+// Wird einmalig beim Start in loadConfig() gesetzt und danach nur gelesen.
+var scanNets []*net.IPNet
+
+// parseScanRanges wandelt CIDR-Strings in IPv4-Netze um.
+//
+// Regeln:
+// - nur IPv4
+// - doppelte Bereiche werden ignoriert
+// - Gesamtzahl der Hosts darf maxScanHosts nicht überschreiten
+func parseScanRanges(ranges []string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	seen := make(map[string]bool)
+	var total uint64
+
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scan range %q: %w", r, err)
+		}
+
+		if ipnet.IP.To4() == nil {
+			return nil, fmt.Errorf("scan range %q is not IPv4", r)
+		}
+
+		if seen[ipnet.String()] {
+			continue
+		}
+		seen[ipnet.String()] = true
+
+		// Anzahl aus der Maske berechnen, bevor Adressen erzeugt werden
+		// (bei /0 oder /8 wären das Milliarden bzw. Millionen Strings)
+		total += hostCount(ipnet)
+		if total > maxScanHosts {
+			return nil, fmt.Errorf("scan ranges too large: more than %d hosts", maxScanHosts)
+		}
+
+		out = append(out, ipnet)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no scan range configured")
+	}
+
+	return out, nil
+}
+
+// hostCount liefert die Anzahl der Host-Adressen eines IPv4-Netzes,
+// ohne sie aufzuzählen (Regeln wie hostsInNet).
+func hostCount(ipnet *net.IPNet) uint64 {
+	if ipnet.IP.To4() == nil || len(ipnet.Mask) != 4 {
+		return 0
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	size := uint64(1) << uint(bits-ones)
+
+	// Netz- und Broadcast-Adresse zählen erst ab /30 nicht mit
+	if size > 2 {
+		size -= 2
+	}
+	return size
+}
+
+// hostsInNet liefert alle Host-Adressen eines IPv4-Netzes.
+//
+// Netz- und Broadcast-Adresse werden ausgelassen,
+// außer bei /31 und /32, wo alle Adressen Hosts sind.
+//
+// Beispiel:
+// 172.17.76.0/24 -> 172.17.76.1 .. 172.17.76.254
+func hostsInNet(ipnet *net.IPNet) []string {
+	ip4 := ipnet.IP.To4()
+	if ip4 == nil || len(ipnet.Mask) != 4 {
+		return nil
+	}
+
+	// Aufrufer prüfen die Größe über hostCount (parseScanRanges);
+	// hier nur gegen Überlauf bei /0 absichern
+	count := hostCount(ipnet)
+	if count == 0 || count > maxScanHosts {
+		return nil
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	size := uint32(1) << uint(bits-ones)
+
+	start := binary.BigEndian.Uint32(ip4)
+	first, last := start, start+size-1
+
+	if size > 2 {
+		first++
+		last--
+	}
+
+	out := make([]string, 0, count)
+
+	for n := first; ; n++ {
+		b := make(net.IP, 4)
+		binary.BigEndian.PutUint32(b, n)
+		out = append(out, b.String())
+
+		if n == last {
+			break
+		}
+	}
+
+	return out
+}
+
+// scanHostIPs liefert alle zu scannenden Adressen über alle Bereiche,
+// in Konfigurationsreihenfolge und ohne Duplikate.
+func scanHostIPs() []string {
+	var out []string
+	seen := make(map[string]bool)
+
+	for _, n := range scanNets {
+		for _, ip := range hostsInNet(n) {
+			if seen[ip] {
+				continue
+			}
+			seen[ip] = true
+			out = append(out, ip)
+		}
+	}
+
+	return out
+}
+
+// scanRangesString liefert die Bereiche für Konsolen-/Fehlermeldungen.
+func scanRangesString() string {
+	parts := make([]string, 0, len(scanNets))
+	for _, n := range scanNets {
+		parts = append(parts, n.String())
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHostCount(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want uint64
+	}{
+		{"0.0.0.0/0", 1<<32 - 2},
+		{"172.0.0.0/8", 1<<24 - 2},
+		{"172.17.76.0/24", 254},
+		{"172.17.76.0/30", 2},
+		{"172.17.76.0/31", 2},
+		{"172.17.76.5/32", 1},
+	}
+
+	for _, tt := range tests {
+		_, ipnet, err := net.ParseCIDR(tt.cidr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cidr, err)
+		}
+		if got := hostCount(ipnet); got != tt.want {
+			t.Errorf("hostCount(%s) = %d, want %d", tt.cidr, got, tt.want)
+		}
+	}
+}
+
+func TestHostsInNet(t *testing.T) {
+	tests := []struct {
+		cidr        string
+		first, last string
+		n           int
+	}{
+		{"172.17.76.0/24", "172.17.76.1", "172.17.76.254", 254},
+		{"172.17.76.0/31", "172.17.76.0", "172.17.76.1", 2},
+		{"172.17.76.5/32", "172.17.76.5", "172.17.76.5", 1},
+		{"0.0.0.0/0", "", "", 0}, // zu groß, wird nicht aufgezählt
+		{"172.0.0.0/8", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		_, ipnet, _ := net.ParseCIDR(tt.cidr)
+		got := hostsInNet(ipnet)
+
+		if len(got) != tt.n {
+			t.Errorf("hostsInNet(%s): %d hosts, want %d", tt.cidr, len(got), tt.n)
+			continue
+		}
+		if tt.n > 0 && (got[0] != tt.first || got[len(got)-1] != tt.last) {
+			t.Errorf("hostsInNet(%s) = %s .. %s, want %s .. %s",
+				tt.cidr, got[0], got[len(got)-1], tt.first, tt.last)
+		}
+	}
+}
+
+func TestParseScanRanges(t *testing.T) {
+	tests := []struct {
+		ranges  []string
+		wantErr string
+		n       int
+	}{
+		{[]string{"0.0.0.0/0"}, "too large", 0},
+		{[]string{"172.17.0.0/8"}, "too large", 0},
+		{[]string{"172.17.76.0/31"}, "", 1},
+		{[]string{"172.17.76.5/32"}, "", 1},
+		{[]string{"172.17.76.0/24", "172.17.76.0/24"}, "", 1},
+		{[]string{"172.17.0.0/16", "172.18.0.0/24"}, "too large", 0},
+		{[]string{"fd00::/64"}, "not IPv4", 0},
+		{[]string{" "}, "no scan range", 0},
+	}
+
+	for _, tt := range tests {
+		nets, err := parseScanRanges(tt.ranges)
+
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseScanRanges(%v): err = %v, want %q", tt.ranges, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseScanRanges(%v): %v", tt.ranges, err)
+			continue
+		}
+		if len(nets) != tt.n {
+			t.Errorf("parseScanRanges(%v): %d nets, want %d", tt.ranges, len(nets), tt.n)
+		}
+	}
+}
@@ -1,9 +1,6 @@
 package main
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -17,56 +14,22 @@ var (
 	officeMutex sync.Mutex
 )
 
-// ------------------------------------------------------------
-// Dateipfad für Office-Zuordnungen
-// ------------------------------------------------------------
-
-// officeFilePath liefert den Pfad zur JSON-Datei mit den Office-Zuordnungen.
-
-// Standardpfad:
-//	<exe-dir>/data/offices.json
-
-// Falls der Pfad zur EXE nicht bestimmt werden kann,
-// wird auf einen relativen Fallback-Pfad zurückgegriffen.
-func officeFilePath() string {
-	exe, err := os.Executable()
-	if err != nil {
-		return filepath.Join("data", "offices.json")
-	}
-
-	baseDir := filepath.Dir(exe)
-	return filepath.Join(baseDir, "data", "offices.json")
-}
-
 // ------------------------------------------------------------
 // Office-Zuordnungen laden
 // ------------------------------------------------------------
 
 // loadOfficeAssignments lädt die gespeicherten MAC->Office-Zuordnungen
-// aus der Datei offices.json in den Arbeitsspeicher.
+// aus dem Persistenz-Backend in den Arbeitsspeicher.
 
 // Verhalten:
-// - Wenn die Datei nicht existiert, wird mit leerer Map gestartet.
-// - Wenn die Datei existiert, wird sie vollständig geladen.
+// - Wenn noch nichts gespeichert ist, wird mit leerer Map gestartet.
+// - Sonst wird der gespeicherte Stand vollständig geladen.
 func loadOfficeAssignments() error {
 	officeMutex.Lock()
 	defer officeMutex.Unlock()
 
-	path := officeFilePath()
-
-	data, err := os.ReadFile(path)
+	loaded, err := store.LoadOfficeAssignments()
 	if err != nil {
-		// Kein Fehler im eigentlichen Sinn:
-		// Wenn die Datei noch nicht existiert, starten wir einfach leer.
-		if os.IsNotExist(err) {
-			officeAssignments = make(map[string]string)
-			return nil
-		}
-		return err
-	}
-
-	var loaded map[string]string
-	if err := json.Unmarshal(data, &loaded); err != nil {
 		return err
 	}
 
@@ -84,37 +47,17 @@ func loadOfficeAssignments() error {
 // ------------------------------------------------------------
 
 // saveOfficeAssignments schreibt die aktuelle MAC->Office-Zuordnung
-// in die Datei offices.json.
-
-// Das Schreiben erfolgt atomar:
-// - erst temp-Datei schreiben
-// - dann per Rename ersetzen
+// ins Persistenz-Backend.
 
-// So bleibt die Datei auch bei Fehlern konsistent.
+// Das Schreiben erfolgt atomar (siehe Storage.Update),
+// so bleibt der gespeicherte Stand auch bei Fehlern konsistent.
 func saveOfficeAssignments() error {
 	officeMutex.Lock()
 	defer officeMutex.Unlock()
 
-	path := officeFilePath()
-
-	// Zielverzeichnis sicherstellen
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
-	}
-
-	// JSON lesbar formatieren
-	data, err := json.MarshalIndent(officeAssignments, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// Erst temporär schreiben, dann atomar ersetzen
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return err
-	}
-
-	return os.Rename(tmpPath, path)
+	return store.Update(func(tx StorageTx) error {
+		return tx.PutOfficeAssignments(officeAssignments)
+	})
 }
 
 // ------------------------------------------------------------
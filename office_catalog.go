@@ -0,0 +1,661 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+)
+
+// ------------------------------------------------------------
+// Büro-Katalog
+// ------------------------------------------------------------
+//
+// Die gültigen Büros/Räume liegen als Daten im Dokument "office_catalog",
+// nicht mehr als fest einkompilierte Liste. Beim ersten Start wird
+// der Katalog aus defaultOffices (offices_list.go) angelegt.
+//
+//	GET    /api/offices          Liste inkl. Anzahl zugeordneter Geräte
+//	POST   /api/offices          Büro anlegen
+//	PUT    /api/offices/{name}   Büro ändern; anderer "name" = Umbenennen
+//	DELETE /api/offices/{name}   Büro löschen (nur ohne Geräte)
+//
+// Beim Umbenennen werden alle officeAssignments im selben Schreibvorgang
+// umgestellt, außerdem Zugangsdaten-Scopes ("office:<Büro>"),
+// Büro-Filter der Alarm-Regeln und Bereiche auf Lageplänen.
+//
+// Beispiel:
+//
+//	{"name":"T4039","building":"T","floor":"4","responsible":"Müller",
+//	 "description":"Schaltschrank-Labor"}
+type Office struct {
+	Name        string `json:"name"`
+	Building    string `json:"building,omitempty"`
+	Floor       string `json:"floor,omitempty"`
+	Responsible string `json:"responsible,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+const (
+	// nicht "offices": so heißt bei storage=json bereits die Datei der Zuordnungen
+	officeCatalogDocument = "office_catalog"
+
+	// maximale Länge eines Büronamens
+	officeNameMaxLen = 32
+)
+
+var (
+	// officeCatalog enthält alle Büros, sortiert nach Name.
+	officeCatalog []Office
+
+	// officeCatalogMutex schützt officeCatalog.
+	//
+	// Reihenfolge beim Sperren: officeCatalogMutex, officeMutex,
+	// credentialMutex, alertMutex, floorPlanMutex. Die Büro-Prüfung
+	// (officeExists) darf deshalb nicht unter diesen Mutexen laufen.
+	officeCatalogMutex sync.Mutex
+)
+
+var (
+	errOfficeExists   = errors.New("office already exists")
+	errOfficeNotFound = errors.New("office not found")
+	errOfficeInUse    = errors.New("office is still in use")
+)
+
+// ------------------------------------------------------------
+// Laden / Speichern
+// ------------------------------------------------------------
+
+// loadOfficeCatalog lädt den Katalog bzw. legt ihn aus defaultOffices an.
+func loadOfficeCatalog() error {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	var loaded []Office
+	found, err := store.LoadDocument(officeCatalogDocument, &loaded)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		officeCatalog = loaded
+		sortOfficesLocked()
+		return nil
+	}
+
+	// Erster Start: Gebäude und Etage aus dem Namen ableiten (T4015 → T, 4)
+	officeCatalog = make([]Office, 0, len(defaultOffices))
+	for _, name := range defaultOffices {
+		o := Office{Name: name}
+		if len(name) >= 2 && unicode.IsLetter(rune(name[0])) && unicode.IsDigit(rune(name[1])) {
+			o.Building = name[:1]
+			o.Floor = name[1:2]
+		}
+		officeCatalog = append(officeCatalog, o)
+	}
+	sortOfficesLocked()
+
+	return store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(officeCatalogDocument, officeCatalog)
+	})
+}
+
+// sortOfficesLocked sortiert den Katalog nach Name.
+func sortOfficesLocked() {
+	sort.Slice(officeCatalog, func(i, j int) bool {
+		return officeCatalog[i].Name < officeCatalog[j].Name
+	})
+}
+
+// officeIndexLocked sucht ein Büro (-1 = nicht vorhanden).
+func officeIndexLocked(name string) int {
+	for i, o := range officeCatalog {
+		if o.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ------------------------------------------------------------
+// Abfragen
+// ------------------------------------------------------------
+
+// officeNames liefert alle Büronamen in Katalog-Reihenfolge.
+func officeNames() []string {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	names := make([]string, 0, len(officeCatalog))
+	for _, o := range officeCatalog {
+		names = append(names, o.Name)
+	}
+	return names
+}
+
+// officeExists prüft, ob ein Büro im Katalog steht.
+func officeExists(name string) bool {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	return officeIndexLocked(name) >= 0
+}
+
+// officeDeviceCounts zählt die zugeordneten MAC-Adressen je Büro.
+func officeDeviceCounts() map[string]int {
+	officeMutex.Lock()
+	defer officeMutex.Unlock()
+
+	counts := make(map[string]int)
+	for _, office := range officeAssignments {
+		counts[office]++
+	}
+	return counts
+}
+
+// isOfficeNameChars prüft, ob ein Büroname nur aus [A-Za-z0-9._-] besteht.
+//
+// Büronamen landen in HTML-Attributen, URLs und Dateinamen (Export),
+// daher sind keine Sonderzeichen erlaubt.
+func isOfficeNameChars(name string) bool {
+	for _, r := range name {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeOffice prüft die Felder eines Büros.
+func normalizeOffice(o *Office) error {
+	o.Name = strings.TrimSpace(o.Name)
+	o.Building = strings.TrimSpace(o.Building)
+	o.Floor = strings.TrimSpace(o.Floor)
+	o.Responsible = strings.TrimSpace(o.Responsible)
+	o.Description = strings.TrimSpace(o.Description)
+
+	if o.Name == "" {
+		return errors.New("missing name")
+	}
+	if len(o.Name) > officeNameMaxLen {
+		return fmt.Errorf("name longer than %d characters", officeNameMaxLen)
+	}
+	if !isOfficeNameChars(o.Name) {
+		return errors.New("name may only contain A-Z, a-z, 0-9, '.', '_' and '-'")
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------
+// Ändern
+// ------------------------------------------------------------
+
+// createOffice legt ein neues Büro an.
+func createOffice(o Office) error {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	if officeIndexLocked(o.Name) >= 0 {
+		return errOfficeExists
+	}
+
+	updated := append(append([]Office{}, officeCatalog...), o)
+
+	err := store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(officeCatalogDocument, updated)
+	})
+	if err != nil {
+		return err
+	}
+
+	officeCatalog = updated
+	sortOfficesLocked()
+	return nil
+}
+
+// updateOffice ändert ein Büro; bei anderem Namen wird umbenannt.
+//
+// Katalog, officeAssignments, Zugangsdaten-Scopes, Büro-Filter der
+// Alarm-Regeln und Lageplan-Bereiche werden in einer Transaktion
+// gespeichert und erst danach im Speicher übernommen. Schlägt ein Teil
+// fehl, bleibt alles beim alten Stand und kein Verweis zeigt auf ein
+// nicht mehr vorhandenes Büro.
+func updateOffice(oldName string, o Office) (renamed int, err error) {
+	renamed, err = saveOfficeUpdate(oldName, o)
+	if err != nil || o.Name == oldName {
+		return 0, err
+	}
+
+	// Geräte im RAM nachziehen (inventoryMutex nie unter officeCatalogMutex)
+	inventoryMutex.Lock()
+	for _, dev := range inventory {
+		if dev != nil && dev.Office == oldName {
+			dev.Office = o.Name
+		}
+	}
+	inventoryMutex.Unlock()
+
+	return renamed, nil
+}
+
+// saveOfficeUpdate speichert die Änderung aus updateOffice unter
+// officeCatalogMutex und allen Mutexen der abhängigen Daten.
+//
+// WICHTIG:
+// Nicht unter inventoryMutex aufrufen.
+func saveOfficeUpdate(oldName string, o Office) (renamed int, err error) {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	idx := officeIndexLocked(oldName)
+	if idx < 0 {
+		return 0, errOfficeNotFound
+	}
+	if o.Name != oldName && officeIndexLocked(o.Name) >= 0 {
+		return 0, errOfficeExists
+	}
+
+	updated := append([]Office{}, officeCatalog...)
+	updated[idx] = o
+
+	officeMutex.Lock()
+	defer officeMutex.Unlock()
+	credentialMutex.Lock()
+	defer credentialMutex.Unlock()
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+	floorPlanMutex.Lock()
+	defer floorPlanMutex.Unlock()
+
+	assignments := make(map[string]string, len(officeAssignments))
+	for mac, office := range officeAssignments {
+		if office == oldName {
+			office = o.Name
+			renamed++
+		}
+		assignments[mac] = office
+	}
+
+	var applies []func()
+
+	err = store.Update(func(tx StorageTx) error {
+		applies = nil
+
+		if err := tx.PutDocument(officeCatalogDocument, updated); err != nil {
+			return err
+		}
+		if err := tx.PutOfficeAssignments(assignments); err != nil {
+			return err
+		}
+		if o.Name == oldName {
+			return nil
+		}
+
+		// Verweise in derselben Transaktion umstellen
+		for _, rename := range []func(StorageTx, string, string) (func(), error){
+			renameCredentialOfficeLocked,
+			renameAlertRuleOfficeLocked,
+			renameFloorPlanOfficeLocked,
+		} {
+			apply, err := rename(tx, oldName, o.Name)
+			if err != nil {
+				return err
+			}
+			applies = append(applies, apply)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	officeCatalog = updated
+	sortOfficesLocked()
+	officeAssignments = assignments
+	for _, apply := range applies {
+		apply()
+	}
+
+	return renamed, nil
+}
+
+// officeReferences liefert, wodurch ein Büro noch verwendet wird
+// (leer = darf gelöscht werden).
+func officeReferences(name string) []string {
+	var refs []string
+
+	if n := officeDeviceCounts()[name]; n > 0 {
+		refs = append(refs, fmt.Sprintf("%d devices", n))
+	}
+
+	credentialMutex.Lock()
+	_, hasCredential := routeCredentials[credentialScopeOffice+name]
+	credentialMutex.Unlock()
+	if hasCredential {
+		refs = append(refs, "route credentials")
+	}
+
+	alertMutex.Lock()
+	for _, rule := range alertRules {
+		if rule.Office == name {
+			refs = append(refs, "alert rule "+rule.Name)
+		}
+	}
+	alertMutex.Unlock()
+
+	for _, id := range floorPlansWithOffice(name) {
+		refs = append(refs, "floor plan "+id)
+	}
+
+	return refs
+}
+
+// deleteOffice entfernt ein Büro, sofern es nicht mehr verwendet wird.
+//
+// Die Verweise werden unter officeCatalogMutex geprüft. Büro-Zuordnung,
+// Import, Zugangsdaten, Alarm-Regeln und Lageplan-Bereiche prüfen und
+// speichern ebenfalls unter diesem Mutex, ein Verweis kann also nicht
+// zwischen Prüfung und Löschen entstehen.
+func deleteOffice(name string) error {
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	idx := officeIndexLocked(name)
+	if idx < 0 {
+		return errOfficeNotFound
+	}
+
+	if refs := officeReferences(name); len(refs) > 0 {
+		return fmt.Errorf("%w: %s", errOfficeInUse, strings.Join(refs, ", "))
+	}
+
+	updated := append(append([]Office{}, officeCatalog[:idx]...), officeCatalog[idx+1:]...)
+
+	err := store.Update(func(tx StorageTx) error {
+		return tx.PutDocument(officeCatalogDocument, updated)
+	})
+	if err != nil {
+		return err
+	}
+
+	officeCatalog = updated
+	return nil
+}
+
+// ------------------------------------------------------------
+// HTTP-API
+// ------------------------------------------------------------
+
+// officeEntry ist die API-Sicht eines Büros.
+type officeEntry struct {
+	Office
+	Devices int `json:"devices"` // zugeordnete MAC-Adressen
+}
+
+// listOffices liefert den Katalog mit Geräteanzahl.
+func listOffices() []officeEntry {
+	counts := officeDeviceCounts()
+
+	officeCatalogMutex.Lock()
+	defer officeCatalogMutex.Unlock()
+
+	out := make([]officeEntry, 0, len(officeCatalog))
+	for _, o := range officeCatalog {
+		out = append(out, officeEntry{Office: o, Devices: counts[o.Name]})
+	}
+	return out
+}
+
+// handleOffices verarbeitet GET/POST /api/offices.
+func handleOffices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, listOffices())
+
+	case http.MethodPost:
+		var o Office
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if err := normalizeOffice(&o); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := createOffice(o)
+		appendAuditChange(r, auditOfficeSave, o.Name, "", o.Name, err)
+
+		if errors.Is(err, errOfficeExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to save offices", http.StatusInternalServerError)
+			return
+		}
+
+		publishAllDevices()
+		writeJSON(w, http.StatusOK, o)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOffice verarbeitet PUT/DELETE /api/offices/{name}.
+func handleOffice(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodPut:
+		var o Office
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if o.Name == "" {
+			o.Name = name
+		}
+		if err := normalizeOffice(&o); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		renamed, err := updateOffice(name, o)
+
+		action := auditOfficeSave
+		if o.Name != name {
+			action = auditOfficeRename
+		}
+		appendAuditChange(r, action, name, name, o.Name, err)
+
+		switch {
+		case errors.Is(err, errOfficeNotFound):
+			http.Error(w, "office not found", http.StatusNotFound)
+			return
+		case errors.Is(err, errOfficeExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, "failed to save offices", http.StatusInternalServerError)
+			return
+		}
+
+		if renamed > 0 || o.Name != name {
+			fmt.Printf("Büro %s umbenannt in %s (%d Zuordnungen)\n", name, o.Name, renamed)
+		}
+
+		publishAllDevices()
+		writeJSON(w, http.StatusOK, o)
+
+	case http.MethodDelete:
+		err := deleteOffice(name)
+		appendAuditChange(r, auditOfficeDelete, name, name, "", err)
+
+		switch {
+		case errors.Is(err, errOfficeNotFound):
+			http.Error(w, "office not found", http.StatusNotFound)
+			return
+		case errors.Is(err, errOfficeInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, "failed to save offices", http.StatusInternalServerError)
+			return
+		}
+
+		publishAllDevices()
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ------------------------------------------------------------
+// Verwaltungsseite
+// ------------------------------------------------------------
+
+// handleOfficesPage zeigt /offices: Katalog und (für Admins) Formular.
+func handleOfficesPage(w http.ResponseWriter, r *http.Request) {
+	esc := template.HTMLEscapeString
+	list := listOffices()
+	isAdmin := requestUser(r).Role == roleAdmin
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprint(w, `
+    <html>
+    <head>
+        <style>
+            body { font-family: 'Segoe UI', sans-serif; margin: 0; padding: 20px; background-color: #f4f7f6; }
+            .container { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 1100px; }
+            .header-bar { display: flex; align-items: center; gap: 15px; margin-bottom: 16px; }
+            .back-link { color: #ce1126; text-decoration: none; font-weight: 600; }
+            table { border-collapse: collapse; width: 100%; margin-bottom: 20px; }
+            th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #eee; font-size: 0.9em; }
+            th { background: #fafafa; }
+            .hint { color: #888; font-size: 0.85em; }
+            input { padding: 4px 6px; border: 1px solid #ddd; border-radius: 4px; }
+            button { padding: 4px 10px; border: 1px solid #ce1126; background: white; color: #ce1126; border-radius: 4px; cursor: pointer; }
+        </style>
+    </head>
+    <body>
+        <div class="container">
+            <div class="header-bar">
+                <a class="back-link" href="/">&larr; Dashboard</a>
+                <h1 style="margin: 0; font-size: 1.3em; font-weight: 300;">Büros / Räume</h1>
+            </div>
+
+            <table>
+                <tr><th>Name</th><th>Gebäude</th><th>Etage</th><th>Verantwortlich</th><th>Beschreibung</th><th>Geräte</th><th></th></tr>
+`)
+
+	for _, o := range list {
+		actions := ""
+		if isAdmin {
+			data, _ := json.Marshal(o.Office)
+			actions = `<button data-edit="` + esc(string(data)) + `">Bearbeiten</button>`
+			if o.Devices == 0 {
+				actions += ` <button data-del="` + esc(o.Name) + `">Löschen</button>`
+			}
+		}
+
+		fmt.Fprintf(w, `<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%d</td><td>%s</td></tr>
+`,
+			esc(o.Name), esc(o.Building), esc(o.Floor), esc(o.Responsible), esc(o.Description), o.Devices, actions)
+	}
+
+	fmt.Fprint(w, `
+            </table>
+`)
+
+	if isAdmin {
+		fmt.Fprint(w, `
+            <form id="officeForm">
+                <input type="hidden" name="original">
+                <input name="name" placeholder="Name (z. B. T4039)" size="12" required>
+                <input name="building" placeholder="Gebäude" size="8">
+                <input name="floor" placeholder="Etage" size="5">
+                <input name="responsible" placeholder="Verantwortlich" size="16">
+                <input name="description" placeholder="Beschreibung" size="28">
+                <button type="submit" id="saveBtn">Anlegen</button>
+                <button type="button" id="resetForm">Neu</button>
+            </form>
+            <p class="hint">Umbenennen ändert alle Zuordnungen, Zugangsdaten, Alarm-Regeln und Lageplan-Bereiche des Büros.
+                Büros mit zugeordneten Geräten können nicht gelöscht werden.</p>
+
+            <script>
+                const form = document.getElementById("officeForm");
+
+                function fillForm(o) {
+                    form.original.value = o.name || "";
+                    form.name.value = o.name || "";
+                    form.building.value = o.building || "";
+                    form.floor.value = o.floor || "";
+                    form.responsible.value = o.responsible || "";
+                    form.description.value = o.description || "";
+                    document.getElementById("saveBtn").textContent = o.name ? "Speichern" : "Anlegen";
+                }
+
+                async function send(method, url, body) {
+                    const res = await fetch(url, {
+                        method: method,
+                        headers: { "Content-Type": "application/json" },
+                        body: body ? JSON.stringify(body) : undefined
+                    });
+                    if (!res.ok) {
+                        alert("Fehler: " + (await res.text()));
+                        return;
+                    }
+                    location.reload();
+                }
+
+                form.addEventListener("submit", (e) => {
+                    e.preventDefault();
+                    const o = {
+                        name: form.name.value,
+                        building: form.building.value,
+                        floor: form.floor.value,
+                        responsible: form.responsible.value,
+                        description: form.description.value
+                    };
+                    if (form.original.value) {
+                        send("PUT", "/api/offices/" + encodeURIComponent(form.original.value), o);
+                    } else {
+                        send("POST", "/api/offices", o);
+                    }
+                });
+
+                document.getElementById("resetForm").addEventListener("click", () => fillForm({}));
+
+                document.querySelectorAll("button[data-edit]").forEach(btn => {
+                    btn.addEventListener("click", () => fillForm(JSON.parse(btn.dataset.edit)));
+                });
+
+                document.querySelectorAll("button[data-del]").forEach(btn => {
+                    btn.addEventListener("click", () => {
+                        if (!confirm("Büro " + btn.dataset.del + " löschen?")) return;
+                        send("DELETE", "/api/offices/" + encodeURIComponent(btn.dataset.del));
+                    });
+                });
+            </script>
+`)
+	}
+
+	fmt.Fprint(w, `
+        </div>
+    </body>
+    </html>
+`)
+}
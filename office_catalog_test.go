@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOffice(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"T4015", true},
+		{" T4015 ", true},
+		{"Lab_2.OG-West", true},
+		{"", false},
+		{"T 4015", false},
+		{`T4015"><script>`, false},
+		{"Büro1", false},
+		{"T4015/1", false},
+		{strings.Repeat("A", officeNameMaxLen+1), false},
+	}
+
+	for _, tt := range tests {
+		o := Office{Name: tt.name}
+		err := normalizeOffice(&o)
+		if (err == nil) != tt.ok {
+			t.Errorf("normalizeOffice(%q): err = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestRenderDeviceRowEscapes(t *testing.T) {
+	const payload = `"><script>alert(1)</script>`
+
+	dev := &IPC{
+		IP:             "10.99.0.2",
+		MACAddress:     "00:01:05:AA:BB:01",
+		Office:         payload,
+		Hostname:       payload,
+		OSVersion:      payload,
+		AmsNetID:       payload,
+		TwinCATVersion: payload,
+	}
+
+	var buf bytes.Buffer
+	renderDeviceRow(&buf, dev, true)
+
+	if strings.Contains(buf.String(), "<script>") {
+		t.Errorf("unescaped payload in row:\n%s", buf.String())
+	}
+}
+
+func TestDeleteOfficeInUse(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}, {Name: "T2004"}}
+	officeCatalogMutex.Unlock()
+
+	officeAssignments["00:01:05:AA:BB:01"] = "T4015"
+
+	if err := deleteOffice("T4015"); !errors.Is(err, errOfficeInUse) {
+		t.Errorf("deleteOffice(T4015) = %v, want in use", err)
+	}
+	if err := deleteOffice("T2004"); err != nil {
+		t.Errorf("deleteOffice(T2004) = %v", err)
+	}
+	if err := deleteOffice("T2004"); err != errOfficeNotFound {
+		t.Errorf("second deleteOffice(T2004) = %v, want not found", err)
+	}
+	if !officeExists("T4015") || officeExists("T2004") {
+		t.Errorf("catalog after delete: %+v", officeCatalog)
+	}
+}
+
+// failingDocStorage lässt das Schreiben eines Dokuments scheitern.
+type failingDocStorage struct {
+	Storage
+	doc string
+}
+
+func (s failingDocStorage) Update(fn func(tx StorageTx) error) error {
+	return s.Storage.Update(func(tx StorageTx) error {
+		return fn(failingDocTx{tx, s.doc})
+	})
+}
+
+type failingDocTx struct {
+	StorageTx
+	doc string
+}
+
+func (tx failingDocTx) PutDocument(name string, v any) error {
+	if name == tx.doc {
+		return errors.New("disk full")
+	}
+	return tx.StorageTx.PutDocument(name, v)
+}
+
+func TestUpdateOfficeRenameIsAtomic(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	officeCatalogMutex.Lock()
+	officeCatalog = []Office{{Name: "T4015"}}
+	officeCatalogMutex.Unlock()
+
+	officeAssignments["00:01:05:AA:BB:01"] = "T4015"
+
+	credentialMutex.Lock()
+	credentialAEAD = nil
+	routeCredentials = map[string]RouteCredential{}
+	credentialMutex.Unlock()
+
+	alertMutex.Lock()
+	alertRules = []AlertRule{{ID: "r1", Name: "aus", Kind: alertKindOffline, Office: "T4015"}}
+	alertMutex.Unlock()
+
+	floorPlanMutex.Lock()
+	floorPlans = []FloorPlan{{ID: "T4", Regions: []FloorRegion{{Office: "T4015", W: 10, H: 10}}}}
+	floorPlanMutex.Unlock()
+
+	t.Cleanup(func() {
+		alertMutex.Lock()
+		alertRules = nil
+		alertMutex.Unlock()
+		floorPlanMutex.Lock()
+		floorPlans = nil
+		floorPlanMutex.Unlock()
+	})
+
+	// Lageplan lässt sich nicht speichern: nichts wird umbenannt
+	base := store
+	store = failingDocStorage{base, floorPlanDocument}
+
+	if _, err := updateOffice("T4015", Office{Name: "T4016"}); err == nil {
+		t.Fatal("rename succeeded although floor plans could not be saved")
+	}
+	if !officeExists("T4015") || officeAssignments["00:01:05:AA:BB:01"] != "T4015" ||
+		alertRules[0].Office != "T4015" || floorPlans[0].Regions[0].Office != "T4015" {
+		t.Errorf("state changed after failed rename")
+	}
+
+	store = base
+
+	renamed, err := updateOffice("T4015", Office{Name: "T4016"})
+	if err != nil || renamed != 1 {
+		t.Fatalf("updateOffice = %d, %v", renamed, err)
+	}
+	if !officeExists("T4016") || officeAssignments["00:01:05:AA:BB:01"] != "T4016" ||
+		alertRules[0].Office != "T4016" || floorPlans[0].Regions[0].Office != "T4016" {
+		t.Errorf("references not renamed")
+	}
+}
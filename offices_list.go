@@ -1,6 +1,11 @@
 package main
 
-var validOffices = []string{
+// defaultOffices ist der Startbestand des Büro-Katalogs.
+//
+// Wird nur beim ersten Start übernommen, solange noch kein
+// Katalog gespeichert ist (siehe loadOfficeCatalog in office_catalog.go).
+// Danach werden Büros über /offices bzw. /api/offices gepflegt.
+var defaultOffices = []string{
 	"T2002", "T2004", "T2006", "T2010",
 	"T4015", "T4016", "T4017", "T4018", "T4019",
 	"T4020", "T4021", "T4022", "T4023", "T4024",
@@ -1,74 +1,39 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 )
 
 // snapshotEnvelope kapselt das Inventory zusammen mit Metadaten.
 // Dadurch kann neben den Gerätedaten auch gespeichert werden,
 // wann der Snapshot erzeugt wurde.
+//
+// Format der Datei inventory_snapshot.json im JSON-Backend.
 type snapshotEnvelope struct {
 	SavedAt   time.Time       `json:"saved_at"`  // Zeitpunkt des Speicherns
 	Inventory map[string]*IPC `json:"inventory"` // kompletter Gerätestand
 }
 
-// ------------------------------------------------------------
-// Snapshot-Dateipfad bestimmen
-// ------------------------------------------------------------
-
-// snapshotPath liefert immer einen absoluten Pfad relativ zur EXE:
-//
-// <exe-dir>/data/inventory_snapshot.json
-//
-// Vorteil:
-// Das Tool funktioniert unabhängig davon,
-// aus welchem Arbeitsverzeichnis es gestartet wird.
-func snapshotPath() (string, error) {
-	exe, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("os.Executable: %w", err)
-	}
-
-	base := filepath.Dir(exe)
-
-	return filepath.Join(base, "data", "inventory_snapshot.json"), nil
-}
-
 // ------------------------------------------------------------
 // Snapshot laden
 // ------------------------------------------------------------
 
 // loadSnapshot lädt beim Start den zuletzt gespeicherten Gerätestand
-// aus der JSON-Datei in das RAM-Inventory.
+// aus dem Persistenz-Backend (siehe Storage) in das RAM-Inventory.
 
 // Dadurch bleiben bekannte Geräte erhalten,
 // auch wenn sie beim nächsten Start zunächst offline sind.
 func loadSnapshot() error {
-	path, err := snapshotPath()
+	inv, savedAt, err := store.LoadInventory()
 	if err != nil {
 		return err
 	}
 
-	// Datei lesen
-	b, err := os.ReadFile(path)
-	if err != nil {
-		// Kein Snapshot vorhanden = normaler Erststart
-		if os.IsNotExist(err) {
-			fmt.Println("Snapshot: keiner vorhanden (Start ohne Persistenzdaten).")
-			return nil
-		}
-
-		return fmt.Errorf("read snapshot: %w", err)
-	}
-
-	// JSON dekodieren
-	var env snapshotEnvelope
-	if err := json.Unmarshal(b, &env); err != nil {
-		return fmt.Errorf("unmarshal snapshot: %w", err)
+	// Kein Snapshot vorhanden = normaler Erststart
+	if len(inv) == 0 {
+		fmt.Println("Snapshot: keiner vorhanden (Start ohne Persistenzdaten).")
+		return nil
 	}
 
 	// Daten unter Lock ins globale Inventory übernehmen
@@ -78,7 +43,7 @@ func loadSnapshot() error {
 		inventory = make(map[string]*IPC)
 	}
 
-	for ip, dev := range env.Inventory {
+	for ip, dev := range inv {
 		inventory[ip] = dev
 	}
 
@@ -86,8 +51,8 @@ func loadSnapshot() error {
 
 	fmt.Printf(
 		"Snapshot geladen: %s (%d Geräte)\n",
-		env.SavedAt.Local().Format("02.01.2006 15:04:05"),
-		len(env.Inventory),
+		savedAt.Local().Format("02.01.2006 15:04:05"),
+		len(inv),
 	)
 
 	return nil
@@ -97,29 +62,20 @@ func loadSnapshot() error {
 // Snapshot speichern
 // ------------------------------------------------------------
 
-// saveSnapshot schreibt den aktuellen Gerätestand in JSON.
+// saveSnapshot schreibt den aktuellen Gerätestand ins Persistenz-Backend.
 
 // Wichtig:
-// Das Schreiben erfolgt atomar:
-
-// 1. temp-Datei schreiben
-// 2. temp-Datei umbenennen
-
-// Dadurch bleibt die Snapshot-Datei konsistent,
+// Das Schreiben erfolgt atomar (bbolt-Transaktion bzw. temp-Datei + Rename).
+// Dadurch bleibt der Snapshot konsistent,
 // selbst wenn während des Schreibens ein Fehler auftritt.
 func saveSnapshot() error {
-	path, err := snapshotPath()
-	if err != nil {
-		return err
-	}
-
 	// --------------------------------------------------------
 	// Inventory unter Lock kopieren
 	// --------------------------------------------------------
 
 	// Warum kopieren?
 
-	// Während JSON geschrieben wird, soll der Lock nicht gehalten werden,
+	// Während geschrieben wird, soll der Lock nicht gehalten werden,
 	// damit Scanner und Webserver weiterarbeiten können.
 	//
 	// Deshalb wird zuerst eine vollständige Kopie erzeugt.
@@ -139,56 +95,18 @@ func saveSnapshot() error {
 
 	inventoryMutex.Unlock()
 
-	// --------------------------------------------------------
-	// Snapshot-Objekt bauen
-	// --------------------------------------------------------
-
-	env := snapshotEnvelope{
-		SavedAt:   time.Now(),
-		Inventory: copyMap,
-	}
-
-	// --------------------------------------------------------
-	// Zielverzeichnis sicherstellen
-	// --------------------------------------------------------
-
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return fmt.Errorf("mkdir data dir: %w", err)
-	}
-
-	// --------------------------------------------------------
-	// JSON formatieren
-	// --------------------------------------------------------
+	savedAt := time.Now()
 
-	// MarshalIndent erzeugt bewusst lesbares JSON,
-	// damit Snapshot-Datei auch manuell geprüft werden kann.
-	data, err := json.MarshalIndent(env, "", "  ")
+	err := store.Update(func(tx StorageTx) error {
+		return tx.PutInventory(copyMap, savedAt)
+	})
 	if err != nil {
-		return fmt.Errorf("marshal snapshot: %w", err)
-	}
-
-	// --------------------------------------------------------
-	// Atomisches Schreiben
-	// --------------------------------------------------------
-
-	// Erst temp-Datei schreiben,
-	// danach per Rename ersetzen.
-
-	// Vorteil:
-	// Niemals halbfertige Snapshot-Datei im Fehlerfall.
-	tmp := path + ".tmp"
-
-	if err := os.WriteFile(tmp, data, 0644); err != nil {
-		return fmt.Errorf("write tmp snapshot: %w", err)
-	}
-
-	if err := os.Rename(tmp, path); err != nil {
-		return fmt.Errorf("rename snapshot: %w", err)
+		return fmt.Errorf("save snapshot: %w", err)
 	}
 
 	fmt.Println(
 		"Snapshot gespeichert:",
-		env.SavedAt.Local().Format("02.01.2006 15:04:05"),
+		savedAt.Local().Format("02.01.2006 15:04:05"),
 	)
 
 	return nil
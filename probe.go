@@ -0,0 +1,238 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// ------------------------------------------------------------
+// Prober-Schnittstelle
+// ------------------------------------------------------------
+//
+// Prober kapselt alle betriebssystemabhängigen Abfragen,
+// die der Scanner pro IP-Adresse ausführt:
+//
+// - Ping: ist das Gerät aktuell erreichbar?
+// - MAC: welche MAC steht in der lokalen ARP-Tabelle?
+// - Hostname: welcher Name ist per Reverse-DNS bekannt?
+//
+// Vollscan und Einzel-Rescan verwenden die globale Variable prober;
+// runScanPass bekommt den Prober übergeben, damit Tests eine eigene
+// Implementierung mit festen Antworten einsetzen können.
+type Prober interface {
+	Ping(ip string, timeout time.Duration) bool
+	MAC(ip string) string
+	Hostname(ip string) string
+}
+
+// prober ist die vom Scanner verwendete Implementierung.
+var prober Prober = newSystemProber()
+
+// ------------------------------------------------------------
+// Standard-Implementierung
+// ------------------------------------------------------------
+
+// systemProber ist die plattformübergreifende Standard-Implementierung.
+//
+// Ping:
+//  1. ICMP über unprivilegierten Socket ("udp4", Linux/macOS)
+//  2. ICMP über Raw-Socket ("ip4:icmp", benötigt Admin/root)
+//  3. Fallback: System-"ping"-Kommando
+//
+// Welche Variante funktioniert, wird beim ersten Ping einmalig
+// ermittelt (nur ob der Socket geöffnet werden kann) und danach
+// beibehalten.
+//
+// ARP:
+//
+//	Linux:   /proc/net/arp
+//	sonst:   "arp -a <ip>"
+type systemProber struct {
+	// icmpNetwork ist die funktionierende Socket-Art:
+	// "udp4", "ip4:icmp" oder "exec". Wird genau einmal
+	// über icmpOnce gesetzt.
+	icmpNetwork string
+	icmpOnce    sync.Once
+
+	// seq wird pro Echo Request erhöht, damit Antworten
+	// parallel laufender Pings auseinandergehalten werden.
+	seq atomic.Uint32
+}
+
+func newSystemProber() *systemProber {
+	return &systemProber{}
+}
+
+// detectICMPNetwork ermittelt die erste Socket-Art, die sich öffnen lässt.
+//
+// Geprüft wird nur das Öffnen, kein Ping: ob das erste Ziel antwortet
+// oder erreichbar ist, sagt nichts über die Socket-Art aus.
+func detectICMPNetwork() string {
+	for _, n := range []string{"udp4", "ip4:icmp"} {
+		conn, err := icmp.ListenPacket(n, "0.0.0.0")
+		if err == nil {
+			conn.Close()
+			return n
+		}
+	}
+	return "exec"
+}
+
+// Ping prüft, ob ein Gerät auf ICMP Echo antwortet.
+func (p *systemProber) Ping(ip string, timeout time.Duration) bool {
+	dst := net.ParseIP(ip).To4()
+	if dst == nil {
+		return false
+	}
+
+	p.icmpOnce.Do(func() {
+		p.icmpNetwork = detectICMPNetwork()
+	})
+
+	if p.icmpNetwork == "exec" {
+		return pingExec(ip, timeout)
+	}
+
+	ok, err := p.pingICMP(p.icmpNetwork, dst, timeout)
+	if err != nil {
+		// Socket konnte diesmal nicht geöffnet werden
+		// (z. B. keine freien Deskriptoren)
+		return pingExec(ip, timeout)
+	}
+
+	return ok
+}
+
+// pingICMP sendet einen einzelnen Echo Request über einen eigenen Socket.
+//
+// err != nil bedeutet: Socket konnte nicht geöffnet werden (z. B. fehlende
+// Rechte), nicht "Gerät antwortet nicht". Sendefehler für das Ziel
+// (z. B. EHOSTUNREACH, ENETUNREACH) gelten als "nicht erreichbar".
+func (p *systemProber) pingICMP(network string, dst net.IP, timeout time.Duration) (bool, error) {
+	conn, err := icmp.ListenPacket(network, "0.0.0.0")
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	seq := int(p.seq.Add(1) & 0xffff)
+	id := os.Getpid() & 0xffff
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("labor-inventar")},
+	}
+
+	wb, err := msg.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Unprivilegierte Sockets erwarten eine UDP-Adresse,
+	// Raw-Sockets eine IP-Adresse.
+	var addr net.Addr = &net.IPAddr{IP: dst}
+	if network == "udp4" {
+		addr = &net.UDPAddr{IP: dst}
+	}
+
+	if _, err := conn.WriteTo(wb, addr); err != nil {
+		return false, nil
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	rb := make([]byte, 1500)
+
+	for {
+		n, peer, err := conn.ReadFrom(rb)
+		if err != nil {
+			// Timeout = keine Antwort, Socket an sich funktioniert
+			return false, nil
+		}
+
+		if peerIP(peer) == nil || !peerIP(peer).Equal(dst) {
+			continue
+		}
+
+		rm, err := icmp.ParseMessage(1, rb[:n]) // 1 = ICMP für IPv4
+		if err != nil || rm.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+
+		// Bei "udp4" setzt der Kernel die ID selbst,
+		// deshalb wird nur die Sequenznummer geprüft.
+		if echo, ok := rm.Body.(*icmp.Echo); ok && echo.Seq == seq {
+			return true, nil
+		}
+	}
+}
+
+// peerIP liefert die IP aus einer UDP- oder IP-Adresse.
+func peerIP(a net.Addr) net.IP {
+	switch v := a.(type) {
+	case *net.UDPAddr:
+		return v.IP
+	case *net.IPAddr:
+		return v.IP
+	}
+	return nil
+}
+
+// pingExec ruft das System-"ping" auf (Fallback ohne ICMP-Socket-Rechte).
+//
+// Windows: "-n 1" = genau ein Ping, "-w <ms>" = Timeout
+// sonst:   "-c 1" = genau ein Ping, "-W <s>"  = Timeout
+func pingExec(ip string, timeout time.Duration) bool {
+	var cmd *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("ping", "-n", "1", "-w", strconv.FormatInt(timeout.Milliseconds(), 10), ip)
+	} else {
+		secs := int(timeout.Round(time.Second).Seconds())
+		if secs < 1 {
+			secs = 1
+		}
+		cmd = exec.Command("ping", "-c", "1", "-W", strconv.Itoa(secs), ip)
+	}
+
+	return cmd.Run() == nil
+}
+
+// MAC liest die MAC-Adresse aus der lokalen ARP-Tabelle.
+// Rückgabeformat wird auf "AA:BB:CC:DD:EE:FF" normalisiert.
+func (p *systemProber) MAC(ip string) string {
+	return normalizeMAC(lookupARP(ip))
+}
+
+// Hostname versucht per Reverse-DNS den Hostnamen zur IP aufzulösen.
+func (p *systemProber) Hostname(ip string) string {
+	names, err := net.LookupAddr(ip)
+	if err == nil && len(names) > 0 {
+		return strings.TrimSuffix(names[0], ".")
+	}
+	return ""
+}
+
+// macPattern findet MAC-Adressen in der Ausgabe von "arp -a".
+var macPattern = regexp.MustCompile(`([0-9a-fA-F]{2}[:-]){5}([0-9a-fA-F]{2})`)
+
+// lookupARPCommand liest per "arp -a <ip>" die MAC-Adresse aus.
+//
+// Wird unter Windows/macOS verwendet und unter Linux als Fallback,
+// falls /proc/net/arp nicht lesbar ist.
+func lookupARPCommand(ip string) string {
+	output, _ := exec.Command("arp", "-a", ip).Output()
+	return macPattern.FindString(string(output))
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// lookupARP liest die MAC-Adresse aus /proc/net/arp.
+//
+// Format (Kopfzeile + eine Zeile pro Eintrag):
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	172.17.76.23     0x1         0x2         00:01:05:12:34:56     *        eth0
+//
+// Flags 0x0 bedeutet "unvollständig" (keine Antwort auf ARP-Request).
+func lookupARP(ip string) string {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return lookupARPCommand(ip)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Scan() // Kopfzeile überspringen
+
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || fields[0] != ip {
+			continue
+		}
+
+		if fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			return ""
+		}
+
+		return fields[3]
+	}
+
+	return ""
+}
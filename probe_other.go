@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// lookupARP liest die MAC-Adresse per "arp -a <ip>" aus
+// (Windows, macOS und andere Systeme ohne /proc/net/arp).
+func lookupARP(ip string) string {
+	return lookupARPCommand(ip)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Einzel-Rescan eines Geräts
+// ------------------------------------------------------------
+//
+// POST /api/devices/{id}/rescan prüft genau ein Gerät sofort:
+//
+//	Ping → ARP (MAC) → Hostname → Unicast-ADS-Discovery → ReadState
+//
+// Der Rescan läuft unabhängig vom Vollscan (runDiscovery):
+// er wartet nicht auf einen laufenden Scan und blockiert ihn nicht.
+// Pro Gerät läuft höchstens ein Rescan gleichzeitig.
+//
+// Überschneidet sich der Rescan mit einem Vollscan, schreibt er keine
+// Historie: der Vollscan vergleicht seinen eigenen Vorher/Nachher-Stand
+// und würde dieselben Änderungen sonst ein zweites Mal protokollieren.
+// Eine dabei neu gefundene MAC meldet der Rescan selbst (new_mac),
+// außer der Vollscan läuft noch und erkennt sie dann.
+
+var (
+	// rescansRunning enthält die IPs, für die gerade ein Rescan läuft.
+	rescansRunning = make(map[string]bool)
+
+	// rescanMutex schützt rescansRunning.
+	rescanMutex sync.Mutex
+)
+
+var errRescanRunning = errors.New("rescan already running")
+
+// rescanDevice führt den Einzel-Rescan für ip aus und liefert
+// den Stand des Geräts danach.
+func rescanDevice(ip string) (IPC, error) {
+	rescanMutex.Lock()
+	if rescansRunning[ip] {
+		rescanMutex.Unlock()
+		return IPC{}, errRescanRunning
+	}
+	rescansRunning[ip] = true
+	rescanMutex.Unlock()
+
+	defer func() {
+		rescanMutex.Lock()
+		delete(rescansRunning, ip)
+		rescanMutex.Unlock()
+	}()
+
+	fmt.Println("Einzel-Rescan:", ip)
+
+	// --------------------------------------------------------
+	// 1) Eintrag anlegen, Stand für die Historie merken
+	// --------------------------------------------------------
+	inventoryMutex.Lock()
+	before := snapshotByMACLocked()
+	duringScan := isScanning
+	if _, exists := inventory[ip]; !exists {
+		inventory[ip] = &IPC{IP: ip, LastUpdate: time.Now()}
+	}
+	inventoryMutex.Unlock()
+
+	// --------------------------------------------------------
+	// 2) Ping / MAC / Hostname
+	// --------------------------------------------------------
+	//
+	// Nur in lokale Variablen: Die Erreichbarkeit wird erst am Ende
+	// zusammen mit dem ADS-Ergebnis einmal geschrieben. So sehen API,
+	// Metriken und ein parallel laufender Vollscan das Gerät während
+	// des Rescans nicht kurzzeitig offline.
+	probe := probeAddress(prober, ip)
+
+	// --------------------------------------------------------
+	// 3) Unicast-ADS-Discovery
+	// --------------------------------------------------------
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.DiscoveryTimeout))
+	info, derr := discoverPlcUnicast(ctx, net.ParseIP(ip).To4(), time.Duration(cfg.DiscoveryListen))
+	cancel()
+
+	if derr == nil {
+		inventoryMutex.Lock()
+		if dev, ok := inventory[ip]; ok {
+			applyDiscoveryLocked(dev, info)
+		}
+		inventoryMutex.Unlock()
+	} else if !errors.Is(derr, errNoDiscoveryResponse) {
+		fmt.Println("Unicast discovery error:", ip, derr)
+	}
+
+	// --------------------------------------------------------
+	// 4) TwinCAT-/ADS-State
+	// --------------------------------------------------------
+	//
+	// Auch ohne Discovery-Antwort, wenn die AMS Net ID schon bekannt ist
+	// (z. B. UDP 48899 gesperrt, ADS über TCP aber erreichbar).
+	inventoryMutex.Lock()
+	var netid, tcVersion string
+	if dev, ok := inventory[ip]; ok {
+		netid, tcVersion = dev.AmsNetID, dev.TwinCATVersion
+	}
+	inventoryMutex.Unlock()
+
+	adsReachable := false
+	if netid != "" {
+		res := queryDeviceAds(prober, ip, netid, tcVersion)
+		if res.Err != "" {
+			fmt.Println("Einzel-Rescan ADS:", ip, res.Err)
+		}
+		adsReachable = res.Err == "" && res.RuntimePort != 0
+	}
+
+	// --------------------------------------------------------
+	// 5) Erreichbarkeit schreiben, Historie, Snapshot, Live-Update
+	// --------------------------------------------------------
+	inventoryMutex.Lock()
+
+	// Neue MAC: weder vor dem Rescan noch jetzt im Inventar. Läuft gerade
+	// ein Vollscan, meldet dieser sie (sein Nachher-Stand kommt später).
+	_, _, macKnown := findIPByMACLocked(normalizeMAC(probe.MAC), "")
+	newMAC := probe.MAC != "" && !macKnown && !isScanning
+
+	if dev, ok := inventory[ip]; ok {
+		// Erreichbarkeit wie beim Vollscan neu bestimmen: Ping oder ADS
+		dev.IsReachable = adsReachable
+	}
+	applyProbeLocked(ip, probe, time.Now())
+	after := snapshotByMACLocked()
+	duringScan = duringScan || isScanning
+	dev, ok := inventory[ip]
+	var result IPC
+	if ok {
+		result = *dev
+	}
+	inventoryMutex.Unlock()
+
+	if ok && result.MACAddress != "" && !duringScan {
+		// Nur Änderungen dieses Geräts protokollieren; alle anderen
+		// gehören zum Vollscan und werden dort erkannt.
+		mac := normalizeMAC(result.MACAddress)
+		events := diffDeviceStates(onlyMAC(before, mac), onlyMAC(after, mac), time.Now())
+		if err := appendDeviceEvents(events); err != nil {
+			fmt.Println("History save error:", err)
+		}
+	}
+
+	if ok && newMAC {
+		evaluateNewMACAlerts(before, result, time.Now())
+	}
+
+	if err := saveSnapshot(); err != nil {
+		fmt.Println("Snapshot save error:", err)
+	}
+
+	publishDevices(ip)
+
+	if !ok {
+		return IPC{}, fmt.Errorf("device %s disappeared during rescan", ip)
+	}
+
+	return result, nil
+}
+
+// onlyMAC liefert aus einem Snapshot nur den Eintrag mit der angegebenen MAC.
+func onlyMAC(snap map[string]IPC, mac string) map[string]IPC {
+	out := make(map[string]IPC, 1)
+	if dev, ok := snap[mac]; ok {
+		out[mac] = dev
+	}
+	return out
+}
+
+// ------------------------------------------------------------
+// HTTP-Handler
+// ------------------------------------------------------------
+
+// handleDeviceRescan verarbeitet POST /api/devices/{id}/rescan.
+//
+// id ist eine IPv4-Adresse (auch für noch unbekannte Geräte in den
+// Scan-Bereichen) oder die MAC-Adresse eines bekannten Geräts.
+//
+// Beispiel:
+//
+//	curl -X POST http://host:18080/api/devices/172.17.76.23/rescan
+//
+// Antwort: das aktualisierte Gerät wie bei GET /api/devices/{id}.
+func handleDeviceRescan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	var ip string
+	if parsed := net.ParseIP(id).To4(); parsed != nil {
+		ip = parsed.String()
+
+		inventoryMutex.Lock()
+		_, known := inventory[ip]
+		inventoryMutex.Unlock()
+
+		if !known && !isInLabSubnetIP(parsed) {
+			http.Error(w, "ip is not in a configured scan range", http.StatusNotFound)
+			return
+		}
+	} else {
+		dev, ok := findDevice(id)
+		if !ok {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+		ip = dev.IP
+	}
+
+	dev, err := rescanDevice(ip)
+	appendAudit(r, auditDeviceRescan, ip, "", err)
+
+	if errors.Is(err, errRescanRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toAPIDevice(&dev))
+}
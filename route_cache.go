@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Routen-Cache
+// ------------------------------------------------------------
+//
+// Früher wurde bei jedem Scan an jedes Gerät ein AddRoute-Paket
+// geschickt. Jetzt gilt:
+//
+//   - immer zuerst direkt ReadState, kein AddRoute
+//     (auch für von Hand angelegte Routen)
+//   - routenbedingter Fehler → Route gilt als verloren, AddRoute
+//   - AddRoute fehlgeschlagen → Backoff, bevor es erneut versucht wird
+//     (der direkte ReadState läuft trotzdem bei jedem Scan)
+//   - Route von einem Admin gelöscht (DELETE /api/devices/{id}/routes)
+//     → kein AddRoute mehr, bis ein Admin sie per POST wieder zulässt
+//
+// Backoff: routeBackoffBase * 2^(Fehlversuche-1), höchstens routeBackoffMax.
+//
+//	Fehlversuch 1 →  5 min
+//	Fehlversuch 2 → 10 min
+//	Fehlversuch 3 → 20 min
+//	...
+//	ab Fehlversuch 8 → 6 h
+const (
+	routeBackoffBase = 5 * time.Minute
+	routeBackoffMax  = 6 * time.Hour
+)
+
+// routeCacheEntry ist der Routen-Zustand eines Geräts.
+// Er wird in IPC gespeichert und bleibt so über Neustarts erhalten.
+type routeCacheEntry struct {
+	KnownGood   bool      // Route funktioniert (letzter Zugriff erfolgreich)
+	LastOK      time.Time // letzter erfolgreicher Zugriff
+	Failures    int       // aufeinanderfolgende Fehlversuche beim Anlegen
+	NextAttempt time.Time // frühester Zeitpunkt für den nächsten AddRoute-Versuch
+	Removed     bool      // von einem Admin gelöscht, kein AddRoute
+}
+
+// routeCacheFromDevice liest den Routen-Zustand aus einem Gerät.
+func routeCacheFromDevice(d *IPC) routeCacheEntry {
+	return routeCacheEntry{
+		KnownGood:   d.RouteKnownGood,
+		LastOK:      d.LastRouteOK,
+		Failures:    d.RouteFailures,
+		NextAttempt: d.NextRouteAttempt,
+		Removed:     d.RouteRemoved,
+	}
+}
+
+// applyToDevice schreibt den Routen-Zustand zurück ins Gerät.
+func (e routeCacheEntry) applyToDevice(d *IPC) {
+	d.RouteKnownGood = e.KnownGood
+	d.LastRouteOK = e.LastOK
+	d.RouteFailures = e.Failures
+	d.NextRouteAttempt = e.NextAttempt
+	d.RouteRemoved = e.Removed
+}
+
+// inBackoff meldet, ob ein neuer AddRoute-Versuch noch warten muss.
+func (e routeCacheEntry) inBackoff(now time.Time) bool {
+	return e.Failures > 0 && now.Before(e.NextAttempt)
+}
+
+// succeeded liefert den Zustand nach einem erfolgreichen Zugriff.
+//
+// Removed bleibt erhalten: eine von Hand wieder angelegte Route
+// hebt die Sperre nicht auf.
+func (e routeCacheEntry) succeeded(now time.Time) routeCacheEntry {
+	return routeCacheEntry{KnownGood: true, LastOK: now, Removed: e.Removed}
+}
+
+// failed liefert den Zustand nach einem fehlgeschlagenen AddRoute.
+func (e routeCacheEntry) failed(now time.Time) routeCacheEntry {
+	e.KnownGood = false
+	e.Failures++
+
+	backoff := routeBackoffMax
+	if e.Failures <= 16 {
+		backoff = min(routeBackoffBase<<(e.Failures-1), routeBackoffMax)
+	}
+
+	e.NextAttempt = now.Add(backoff)
+	return e
+}
+
+// isRouteError meldet, ob ein ADS-Fehler auf eine fehlende Route hindeutet.
+//
+// Mit gültiger Route antwortet das Gerät mit einem ADS-Result
+// (AdsError, z. B. 0x6 "Port nicht gefunden") – das ist kein Routenproblem.
+//
+// Ohne Route verwirft der AMS-Router des Ziels die Anfrage:
+// er meldet einen AMS-Fehler im Header (AmsError) oder setzt die bereits
+// aufgebaute TCP-Verbindung zurück. Nur diese Fälle lösen ein neues
+// AddRoute aus.
+//
+// Schlägt dagegen schon der Verbindungsaufbau fehl oder kommt keine
+// Antwort (Timeout), ist das Gerät nicht erreichbar – eine neue Route
+// würde daran nichts ändern.
+func isRouteError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var amsErr AmsError
+	if errors.As(err, &amsErr) {
+		return amsErr.Code != adsErrTargetPortNotFound
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return false
+	}
+
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// adsReached meldet, ob das Gerät über die Route geantwortet hat:
+// ohne Fehler oder mit einem Fehlercode, der kein Routenproblem ist.
+func adsReached(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	_, ok := adsResultCode(err)
+	return ok && !isRouteError(err)
+}
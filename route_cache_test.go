@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRouteError(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp4", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"ads result", AdsError{Code: 0x6}, false},
+		{"ams port not found", AmsError{Code: adsErrTargetPortNotFound}, false},
+		{"ams header", AmsError{Code: 0x7}, true},
+		{"wrapped ams header", fmt.Errorf("read: %w", AmsError{Code: 0x7}), true},
+		{"connection closed", fmt.Errorf("read ams/tcp header: %w", io.EOF), true},
+		{"connection reset", fmt.Errorf("read ams/tcp header: %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{"dial refused", fmt.Errorf("dial tcp 10.0.0.1:48898: %w", dialErr), false},
+		{"timeout", errors.New("timeout after 2s (port 10000, command 0x0004)"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isRouteError(tt.err); got != tt.want {
+			t.Errorf("%s: isRouteError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAdsReached(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, true},
+		{AdsError{Code: 0x701}, true},
+		{AmsError{Code: adsErrTargetPortNotFound}, true},
+		{AmsError{Code: 0x7}, false},
+		{io.EOF, false},
+		{errors.New("timeout"), false},
+	}
+
+	for _, tt := range tests {
+		if got := adsReached(tt.err); got != tt.want {
+			t.Errorf("adsReached(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// Verbindung wird nach dem Aufbau vom Ziel geschlossen (wie ohne Route)
+func TestIsRouteErrorClosedAfterConnect(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	conn, err := net.DialTimeout("tcp4", addr.String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &AdsClient{conn: conn, timeout: time.Second, pending: make(map[uint32]chan amsResponse)}
+	go c.readLoop()
+	defer c.Close()
+
+	_, err = c.ReadState(routeTcSystemService)
+	if err == nil {
+		t.Fatal("ReadState succeeded on closed connection")
+	}
+	if !isRouteError(err) {
+		t.Errorf("isRouteError(%v) = false, want true", err)
+	}
+}
+
+// Nach DELETE /api/devices/{id}/routes darf der Scan keine Route anlegen
+func TestScanAfterRouteDeleteSkipsAddRoute(t *testing.T) {
+	setupScanTest(t, "127.0.0.0/29")
+
+	// Ziel ohne Route: Verbindung wird nach dem Aufbau geschlossen
+	ln, err := net.Listen("tcp4", fmt.Sprintf("127.0.0.1:%d", adsTCPPort))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var calls int
+	addAdsRoute = func(ctx context.Context, localIP, remoteIP net.IP, routeName, user, pass string, udpPort int, temporary bool) error {
+		calls++
+		return errors.New("no answer")
+	}
+	t.Cleanup(func() { addAdsRoute = EnsureAdsRouteUDP })
+
+	// Stand nach dem Löschen durch handleDeviceRoutes
+	inventoryMutex.Lock()
+	inventory["127.0.0.1"] = &IPC{IP: "127.0.0.1", AmsNetID: "127.0.0.1.1.1", RouteRemoved: true}
+	inventoryMutex.Unlock()
+
+	res := queryDeviceAds(&fakeProber{}, "127.0.0.1", "127.0.0.1.1.1", "")
+	if calls != 0 {
+		t.Errorf("AddRoute called %d times after route delete", calls)
+	}
+	if res.Err != "route: removed by admin" {
+		t.Errorf("Err = %q, want removed by admin", res.Err)
+	}
+
+	inventoryMutex.Lock()
+	dev := *inventory["127.0.0.1"]
+	inventoryMutex.Unlock()
+
+	if _, err := connectDeviceAds(context.Background(), dev); !errors.Is(err, errNoAdsRoute) || calls != 0 {
+		t.Errorf("connectDeviceAds = %v, AddRoute calls %d", err, calls)
+	}
+
+	// Admin lässt die Route wieder zu
+	allowDeviceRoute("127.0.0.1")
+	queryDeviceAds(&fakeProber{}, "127.0.0.1", "127.0.0.1.1.1", "")
+	if calls != 1 {
+		t.Errorf("AddRoute called %d times after allow, want 1", calls)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Scan-Fortschritt
+// ------------------------------------------------------------
+//
+// runDiscovery meldet hier jede Phase mit erledigten/gesamten
+// Einträgen an. Der Zustand wird über /api/scan-status und als
+// "scan"-Event (siehe events.go) ans Dashboard geliefert.
+//
+// Beispiel /api/scan-status während eines Scans:
+//
+//	{
+//	  "isScanning": true,
+//	  "phase": "ping",
+//	  "startedAt": "2026-10-16T09:00:00+02:00",
+//	  "eta": "2026-10-16T09:00:41+02:00",
+//	  "phases": [
+//	    {"name":"udp_discovery","done":14,"total":14,"durationSeconds":3.0},
+//	    {"name":"ads_state","done":14,"total":14,"durationSeconds":21.5},
+//	    {"name":"ping","done":120,"total":254}
+//	  ],
+//	  "lastScan": {...}
+//	}
+
+// Scan-Phasen in der Reihenfolge, in der runDiscovery sie durchläuft
+const (
+	scanPhaseDiscovery = "udp_discovery"
+	scanPhaseAdsState  = "ads_state"
+	scanPhasePing      = "ping"
+)
+
+var scanPhaseOrder = []string{scanPhaseDiscovery, scanPhaseAdsState, scanPhasePing}
+
+// höchstens so viele Fehlermeldungen pro Scan aufheben
+const scanMaxErrorMessages = 20
+
+// ScanPhaseProgress ist der Fortschritt einer Phase.
+type ScanPhaseProgress struct {
+	Name            string     `json:"name"`
+	Done            int        `json:"done"`
+	Total           int        `json:"total"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	DurationSeconds float64    `json:"durationSeconds,omitempty"`
+}
+
+// ScanErrorSummary fasst die Fehler eines Scans zusammen.
+type ScanErrorSummary struct {
+	Discovery     string   `json:"discovery,omitempty"` // Fehler der UDP-Discovery
+	AdsFailed     int      `json:"adsFailed"`           // Geräte ohne gültigen ADS-State
+	RouteFailed   int      `json:"routeFailed"`         // davon: Route konnte nicht angelegt werden
+	SaveErrors    int      `json:"saveErrors"`          // Historie/Snapshot nicht gespeichert
+	Messages      []string `json:"messages,omitempty"`  // die ersten Fehlertexte ("ip: fehler")
+	MoreMessages  int      `json:"moreMessages,omitempty"`
+	messagesTotal int
+}
+
+// Count liefert die Gesamtzahl der Fehler.
+func (e ScanErrorSummary) Count() int {
+	n := e.AdsFailed + e.SaveErrors
+	if e.Discovery != "" {
+		n++
+	}
+	return n
+}
+
+// ScanSummary beschreibt den letzten abgeschlossenen Scan.
+type ScanSummary struct {
+	StartedAt       time.Time           `json:"startedAt"`
+	FinishedAt      time.Time           `json:"finishedAt"`
+	DurationSeconds float64             `json:"durationSeconds"`
+	Phases          []ScanPhaseProgress `json:"phases"`
+	Errors          ScanErrorSummary    `json:"errors"`
+}
+
+// ScanStatus ist die Antwort von /api/scan-status.
+//
+// isScanning bleibt als Feldname erhalten, damit bestehende
+// Skripte, die nur darauf prüfen, weiter funktionieren.
+type ScanStatus struct {
+	IsScanning bool                `json:"isScanning"`
+	Phase      string              `json:"phase,omitempty"`
+	StartedAt  *time.Time          `json:"startedAt,omitempty"`
+	ETA        *time.Time          `json:"eta,omitempty"`
+	Phases     []ScanPhaseProgress `json:"phases,omitempty"`
+	Errors     *ScanErrorSummary   `json:"errors,omitempty"`
+	LastScan   *ScanSummary        `json:"lastScan,omitempty"`
+}
+
+// scanState ist der interne Zustand, geschützt durch scanStateMutex.
+type scanState struct {
+	running   bool
+	phase     string
+	startedAt time.Time
+	phases    map[string]*ScanPhaseProgress
+	errors    ScanErrorSummary
+	last      *ScanSummary
+}
+
+var (
+	currentScan = scanState{phases: make(map[string]*ScanPhaseProgress)}
+
+	// scanStateMutex schützt currentScan.
+	scanStateMutex sync.Mutex
+)
+
+// scanStateBegin setzt den Zustand für einen neuen Scan zurück.
+func scanStateBegin() {
+	scanStateMutex.Lock()
+	currentScan.running = true
+	currentScan.phase = ""
+	currentScan.startedAt = time.Now()
+	currentScan.phases = make(map[string]*ScanPhaseProgress)
+	currentScan.errors = ScanErrorSummary{}
+	scanStateMutex.Unlock()
+
+	publishScanStatus()
+}
+
+// scanStatePhase startet eine Phase mit total Einträgen
+// (0 = unbekannt, z. B. bei der Discovery).
+func scanStatePhase(name string, total int) {
+	now := time.Now()
+
+	scanStateMutex.Lock()
+	currentScan.phase = name
+	currentScan.phases[name] = &ScanPhaseProgress{Name: name, Total: total, StartedAt: &now}
+	scanStateMutex.Unlock()
+
+	publishScanStatus()
+}
+
+// scanStateStep zählt einen erledigten Eintrag der Phase.
+func scanStateStep(name string) {
+	scanStateMutex.Lock()
+	if p, ok := currentScan.phases[name]; ok {
+		p.Done++
+	}
+	scanStateMutex.Unlock()
+
+	publishScanStatus()
+}
+
+// scanStatePhaseDone beendet eine Phase.
+// total setzt die Anzahl nachträglich (z. B. gefundene Geräte der Discovery).
+func scanStatePhaseDone(name string, total int) {
+	now := time.Now()
+
+	scanStateMutex.Lock()
+	p, ok := currentScan.phases[name]
+	if ok {
+		if total >= 0 {
+			p.Total = total
+			p.Done = total
+		}
+		p.FinishedAt = &now
+		if p.StartedAt != nil {
+			d := now.Sub(*p.StartedAt)
+			p.DurationSeconds = d.Seconds()
+			recordScanPhase(name, d)
+		}
+	}
+	scanStateMutex.Unlock()
+
+	publishScanStatus()
+}
+
+// scanStateError vermerkt einen Fehler im laufenden Scan.
+//
+// kind ist "discovery", "ads", "route" oder "save".
+func scanStateError(kind, target, msg string) {
+	scanStateMutex.Lock()
+	defer scanStateMutex.Unlock()
+
+	e := &currentScan.errors
+
+	switch kind {
+	case "discovery":
+		e.Discovery = msg
+	case "ads":
+		e.AdsFailed++
+	case "route":
+		e.AdsFailed++
+		e.RouteFailed++
+	case "save":
+		e.SaveErrors++
+	}
+
+	e.messagesTotal++
+	if len(e.Messages) < scanMaxErrorMessages {
+		if target != "" {
+			msg = target + ": " + msg
+		}
+		e.Messages = append(e.Messages, msg)
+	} else {
+		e.MoreMessages = e.messagesTotal - len(e.Messages)
+	}
+}
+
+// scanStateFinish schließt den Scan ab und merkt ihn als "letzten Scan".
+func scanStateFinish() {
+	now := time.Now()
+
+	scanStateMutex.Lock()
+	summary := &ScanSummary{
+		StartedAt:       currentScan.startedAt,
+		FinishedAt:      now,
+		DurationSeconds: now.Sub(currentScan.startedAt).Seconds(),
+		Phases:          orderedPhasesLocked(),
+		Errors:          currentScan.errors,
+	}
+	currentScan.running = false
+	currentScan.phase = ""
+	currentScan.last = summary
+	scanStateMutex.Unlock()
+
+	recordScanDone(now.Sub(summary.StartedAt))
+	fmt.Printf("Scan-Dauer: %.1f s, Fehler: %d\n", summary.DurationSeconds, summary.Errors.Count())
+
+	publishScanStatus()
+}
+
+// orderedPhasesLocked liefert Kopien der Phasen in Scan-Reihenfolge.
+func orderedPhasesLocked() []ScanPhaseProgress {
+	var out []ScanPhaseProgress
+	for _, name := range scanPhaseOrder {
+		if p, ok := currentScan.phases[name]; ok {
+			out = append(out, *p)
+		}
+	}
+	return out
+}
+
+// estimateFinishLocked schätzt das Scan-Ende.
+//
+// Aktuelle Phase: Hochrechnung aus bisheriger Geschwindigkeit.
+// Folgende Phasen: Dauer aus dem letzten Scan.
+// Ohne letzten Scan und ohne Fortschritt gibt es keine Schätzung.
+func estimateFinishLocked(now time.Time) *time.Time {
+	var remaining time.Duration
+	current := false
+
+	lastPhase := func(name string) (time.Duration, bool) {
+		if currentScan.last == nil {
+			return 0, false
+		}
+		for _, p := range currentScan.last.Phases {
+			if p.Name == name {
+				return time.Duration(p.DurationSeconds * float64(time.Second)), true
+			}
+		}
+		return 0, false
+	}
+
+	for _, name := range scanPhaseOrder {
+		p, started := currentScan.phases[name]
+
+		switch {
+		case started && p.FinishedAt != nil:
+			continue
+
+		case started:
+			current = true
+			elapsed := now.Sub(*p.StartedAt)
+
+			if p.Total > 0 && p.Done > 0 {
+				rate := elapsed / time.Duration(p.Done)
+				remaining += rate * time.Duration(p.Total-p.Done)
+			} else if d, ok := lastPhase(name); ok {
+				remaining += max(d-elapsed, 0)
+			} else {
+				return nil
+			}
+
+		case current || currentScan.phase == "":
+			d, ok := lastPhase(name)
+			if !ok {
+				return nil
+			}
+			remaining += d
+		}
+	}
+
+	eta := now.Add(remaining)
+	return &eta
+}
+
+// getScanStatus liefert eine Kopie des aktuellen Zustands.
+func getScanStatus() ScanStatus {
+	now := time.Now()
+
+	scanStateMutex.Lock()
+	defer scanStateMutex.Unlock()
+
+	st := ScanStatus{
+		IsScanning: currentScan.running,
+		LastScan:   currentScan.last,
+	}
+
+	if currentScan.running {
+		started := currentScan.startedAt
+		errs := currentScan.errors
+		errs.Messages = append([]string(nil), errs.Messages...)
+
+		st.Phase = currentScan.phase
+		st.StartedAt = &started
+		st.Phases = orderedPhasesLocked()
+		st.Errors = &errs
+		st.ETA = estimateFinishLocked(now)
+	}
+
+	return st
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"os/exec"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -14,23 +12,29 @@ import (
 // IPC beschreibt ein einzelnes Gerät im Inventar.
 // Die Struktur wird während des Scans nach und nach mit Informationen gefüllt.
 type IPC struct {
-	IP             string    // IPv4-Adresse im Testnetz
-	IsReachable    bool      // Ergebnis des Ping-Checks
-	Office         string    // Büro-/Standortzuordnung anhand der MAC
-	Comment        string    // Freitext-Kommentar anhand der MAC
-	MACAddress     string    // MAC-Adresse aus ARP
-	Hostname       string    // Reverse-DNS / Hostname
-	OSVersion      string    // OS-Information aus ADS-UDP-Discovery
-	AmsNetID       string    // ADS Net ID des Geräts
-	TwinCATVersion string    // TwinCAT-Version aus ADS-UDP-Discovery
-	RuntimeStatus  string    // aktuell gelesener TwinCAT-/Runtime-Status
-	DeviceType     string    // optional für spätere Typisierung
-	LastUpdate     time.Time // wann ADS-/Discovery-Daten zuletzt aktualisiert wurden
-	LastScan       time.Time // wann zuletzt überhaupt geprüft wurde
-	LastSeenOnline time.Time // wann das Gerät zuletzt als online erkannt wurde
-	RouteKnownGood bool      // ob ADS-Route schon einmal erfolgreich gesetzt wurde
-	LastRouteOK    time.Time // wann die ADS-Route zuletzt erfolgreich war
-	RuntimePort    uint16    // zuletzt erfolgreicher ADS-Port (für spätere Erweiterungen)
+	IP               string       // IPv4-Adresse im Testnetz
+	IsReachable      bool         // Ergebnis des Ping-Checks
+	Office           string       // Büro-/Standortzuordnung anhand der MAC
+	Comment          string       // Freitext-Kommentar anhand der MAC
+	MACAddress       string       // MAC-Adresse aus ARP
+	Hostname         string       // Reverse-DNS / Hostname
+	OSVersion        string       // OS-Information aus ADS-UDP-Discovery
+	AmsNetID         string       // ADS Net ID des Geräts
+	TwinCATVersion   string       // TwinCAT-Version aus ADS-UDP-Discovery
+	RuntimeStatus    string       // aktuell gelesener TwinCAT-/Runtime-Status
+	DeviceName       string       // ADS-Gerätename aus ReadDeviceInfo (z. B. "TwinCAT System")
+	DeviceVersion    string       // ADS-Geräteversion aus ReadDeviceInfo (z. B. "3.1.4024")
+	Runtimes         []PlcRuntime // alle PLC-Runtimes mit eigenem State und Projektinformationen
+	DeviceType       string       // optional für spätere Typisierung
+	LastUpdate       time.Time    // wann ADS-/Discovery-Daten zuletzt aktualisiert wurden
+	LastScan         time.Time    // wann zuletzt überhaupt geprüft wurde
+	LastSeenOnline   time.Time    // wann das Gerät zuletzt als online erkannt wurde
+	RouteKnownGood   bool         // ob die ADS-Route aktuell funktioniert (dann kein AddRoute beim Scan)
+	LastRouteOK      time.Time    // wann die ADS-Route zuletzt erfolgreich war
+	RouteFailures    int          // aufeinanderfolgende fehlgeschlagene AddRoute-Versuche
+	NextRouteAttempt time.Time    // Backoff: frühester nächster AddRoute-Versuch
+	RouteRemoved     bool         // eigene Route von einem Admin gelöscht: kein AddRoute, bis ein Admin sie wieder zulässt
+	RuntimePort      uint16       // zuletzt erfolgreicher ADS-Port (für spätere Erweiterungen)
 }
 
 var (
@@ -48,39 +52,6 @@ var (
 	scanTrigger = make(chan struct{}, 1)
 )
 
-// ------------------------------------------------------------
-// Hilfsfunktionen für Ping / MAC / Hostname
-// ------------------------------------------------------------
-
-// getMACAddress liest per "arp -a <ip>" die MAC-Adresse aus der lokalen ARP-Tabelle.
-// Rückgabeformat wird auf "AA:BB:CC:DD:EE:FF" normalisiert.
-func getMACAddress(ip string) string {
-	cmd := exec.Command("arp", "-a", ip)
-	output, _ := cmd.Output()
-
-	re := regexp.MustCompile(`([0-9a-fA-F]{2}[:-]){5}([0-9a-fA-F]{2})`)
-	mac := re.FindString(string(output))
-
-	return strings.ToUpper(strings.ReplaceAll(mac, "-", ":"))
-}
-
-// getHostname versucht per Reverse-DNS den Hostnamen zur IP aufzulösen.
-func getHostname(ip string) string {
-	names, err := net.LookupAddr(ip)
-	if err == nil && len(names) > 0 {
-		return strings.TrimSuffix(names[0], ".")
-	}
-	return ""
-}
-
-// pingDevice prüft per Windows-Ping, ob ein Gerät erreichbar ist.
-// "-n 1" = genau ein Ping
-// "-w 800" = Timeout 800 ms
-func pingDevice(ip string) bool {
-	cmd := exec.Command("ping", "-n", "1", "-w", "800", ip)
-	return cmd.Run() == nil
-}
-
 // ------------------------------------------------------------
 // Haupt-Scanroutine
 // ------------------------------------------------------------
@@ -97,8 +68,8 @@ func runDiscovery() {
 	default:
 	}
 
-	// Automatischer Wiederholungsscan alle 10 Minuten.
-	ticker := time.NewTicker(10 * time.Minute)
+	// Automatischer Wiederholungsscan im konfigurierten Intervall (Standard 10 Minuten).
+	ticker := time.NewTicker(time.Duration(cfg.ScanInterval))
 	defer ticker.Stop()
 
 	for {
@@ -119,260 +90,428 @@ func runDiscovery() {
 		isScanning = true
 		inventoryMutex.Unlock()
 
-		fmt.Println("Starte Netzwerk-Scan...", time.Now().Format("15:04:05"))
+		runScanPass(prober, discoverPlcsUDP)
+	}
+}
 
-		// --------------------------------------------------------
-		// 1) Grundgerüst für alle IPs im Testnetz anlegen
-		// --------------------------------------------------------
+// discoverFunc sucht Geräte per ADS-UDP-Discovery
+// (produktiv discoverPlcsUDP, in Tests ersetzbar).
+type discoverFunc func(ctx context.Context, listen time.Duration) ([]RemotePlcInfo, error)
+
+// runScanPass führt einen vollständigen Scan-Durchlauf aus:
+// Grundgerüst, Discovery, ADS-State, Ping/MAC/Hostname, Historie, Snapshot.
+//
+// Der Aufrufer setzt vorher isScanning; am Ende wird es zurückgesetzt.
+// Prober und Discovery werden übergeben, damit Tests den Ablauf
+// mit festen Antworten durchspielen können.
+func runScanPass(p Prober, discover discoverFunc) {
+	fmt.Println("Starte Netzwerk-Scan...", time.Now().Format("15:04:05"))
+	scanStateBegin()
+
+	// Alle Adressen der konfigurierten Scan-Bereiche
+	hostIPs := scanHostIPs()
+
+	// --------------------------------------------------------
+	// 1) Grundgerüst für alle IPs im Testnetz anlegen
+	// --------------------------------------------------------
+
+	// So existiert für jede IP der Scan-Bereiche
+	// ein Eintrag im Inventory, auch wenn noch keine Details bekannt sind.
+	inventoryMutex.Lock()
+
+	// Stand vor dem Scan merken, um danach Änderungen
+	// für die Geräte-Historie zu erkennen.
+	before := snapshotByMACLocked()
+
+	for _, ip := range hostIPs {
+		if _, exists := inventory[ip]; !exists {
+			inventory[ip] = &IPC{IP: ip, LastUpdate: time.Now()}
+		}
 
-		// So existiert für jede IP 172.17.76.1 bis 172.17.76.254
-		// ein Eintrag im Inventory, auch wenn noch keine Details bekannt sind.
-		for i := 1; i <= 254; i++ {
-			ip := fmt.Sprintf("172.17.76.%d", i)
-			if _, exists := inventory[ip]; !exists {
-				inventory[ip] = &IPC{IP: ip, LastUpdate: time.Now()}
-			}
+		// Flüchtige Erreichbarkeit pro neuem Scan zurücksetzen
+		inventory[ip].IsReachable = false
+	}
+	inventoryMutex.Unlock()
+
+	// --------------------------------------------------------
+	// 2) ADS UDP Discovery
+	// --------------------------------------------------------
+
+	// Hier werden Beckhoff-/TwinCAT-Geräte per UDP-Broadcast gefunden.
+	// Diese Phase liefert u. a.:
+	// - AMS Net ID
+	// - OS-Version
+	// - TwinCAT-Version
+	// - teilweise grobe Runtime-Hinweise
+	scanStatePhase(scanPhaseDiscovery, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryBudget())
+	plcs, derr := discover(ctx, time.Duration(cfg.DiscoveryListen))
+	cancel()
+	scanStatePhaseDone(scanPhaseDiscovery, len(plcs))
+
+	if derr != nil {
+		fmt.Println("ADS UDP discovery error:", derr)
+		scanStateError("discovery", "", derr.Error())
+	} else {
+		fmt.Printf("ADS UDP discovery found: %d devices\n", len(plcs))
+	}
 
-			// Flüchtige Erreichbarkeit pro neuem Scan zurücksetzen
-			inventory[ip].IsReachable = false
+	//
+	// --------------------------------------------------------
+	// 3) UDP-Discovery-Daten ins Inventory übernehmen
+	// --------------------------------------------------------
+	//
+	// Hier wird der aktuelle Kenntnisstand aus dem UDP-Fund
+	// in die vorhandenen Inventory-Einträge gemerged.
+	inventoryMutex.Lock()
+	for _, d := range plcs {
+		if dev, ok := inventory[d.Address.String()]; ok {
+			applyDiscoveryLocked(dev, d)
 		}
+	}
+	inventoryMutex.Unlock()
 
-		// --------------------------------------------------------
-		// 2) ADS UDP Discovery
-		// --------------------------------------------------------
-
-		// Hier werden Beckhoff-/TwinCAT-Geräte per UDP-Broadcast gefunden.
-		// Diese Phase liefert u. a.:
-		// - AMS Net ID
-		// - OS-Version
-		// - TwinCAT-Version
-		// - teilweise grobe Runtime-Hinweise
-		ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
-		plcs, derr := discoverPlcsUDP(ctx, 2500*time.Millisecond)
-		cancel()
-
-		if derr != nil {
-			fmt.Println("ADS UDP discovery error:", derr)
-		} else {
-			fmt.Printf("ADS UDP discovery found: %d devices\n", len(plcs))
+	found := make([]string, 0, len(plcs))
+	for _, d := range plcs {
+		found = append(found, d.Address.String())
+	}
+	publishDevices(found...)
+
+	// --------------------------------------------------------
+	// 4) TwinCAT-/ADS-State aktiv abfragen
+	// --------------------------------------------------------
+
+	// Für alle per UDP gefundenen Geräte wird versucht:
+	// - eine ADS-Route anzulegen
+	// - anschließend per ADS den TwinCAT-/Runtime-State zu lesen
+
+	// Hinweis:
+	// Diese Phase läuft parallel mit Worker-Goroutines.
+	// Die lokale IP wird pro Ziel passend zum jeweiligen Subnetz gewählt.
+	{
+		// job beschreibt ein einzelnes Ziel für die ADS-State-Abfrage.
+		type job struct {
+			ip        string
+			netid     string
+			tcVersion string
 		}
 
-		//
-		// --------------------------------------------------------
-		// 3) UDP-Discovery-Daten ins Inventory übernehmen
-		// --------------------------------------------------------
-		//
-		// Hier wird der aktuelle Kenntnisstand aus dem UDP-Fund
-		// in die vorhandenen Inventory-Einträge gemerged.
-		inventoryMutex.Lock()
+		// Alle UDP-gefundenen Ziele mit AMS Net ID einsammeln.
+		targets := make([]job, 0, len(plcs))
 		for _, d := range plcs {
-			ipStr := d.Address.String()
+			if d.AmsNetID == "" {
+				continue
+			}
 
-			if dev, ok := inventory[ipStr]; ok {
-				dev.AmsNetID = d.AmsNetID
-				dev.OSVersion = d.OsVersion
-				dev.TwinCATVersion = d.TcVersion.String()
+			targets = append(targets, job{
+				ip:        d.Address.String(),
+				netid:     d.AmsNetID,
+				tcVersion: d.TcVersion.String(),
+			})
+		}
 
-				// Basiswert aus UDP-Discovery.
-				// Dieser wird später ggf. durch echten ADS ReadState überschrieben.
-				dev.RuntimeStatus = d.IsRuntime
+		workers := cfg.AdsWorkers
 
-				// Hostname aus UDP nur übernehmen, wenn noch keiner bekannt ist.
-				if dev.Hostname == "" && d.Hostname != "" {
-					dev.Hostname = d.Hostname
-				}
+		jobs := make(chan job, len(targets))
+		var wg sync.WaitGroup
 
-				dev.LastUpdate = time.Now()
-			}
+		// Fortschritt für die Live-Anzeige
+		jobDone := func(ip string) {
+			publishDevices(ip)
+			scanStateStep(scanPhaseAdsState)
 		}
-		inventoryMutex.Unlock()
+		scanStatePhase(scanPhaseAdsState, len(targets))
 
-		// --------------------------------------------------------
-		// 4) TwinCAT-/ADS-State aktiv abfragen
-		// --------------------------------------------------------
+		// Workerpool für ADS-State-Abfragen.
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
 
-		// Für alle per UDP gefundenen Geräte wird versucht:
-		// - eine ADS-Route anzulegen
-		// - anschließend per ADS den TwinCAT-/Runtime-State zu lesen
+			go func() {
+				defer wg.Done()
 
-		// Hinweis:
-		// Diese Phase läuft parallel mit Worker-Goroutines.
-		localIP, _, lipErr := getLocalLabIPv4()
-		if lipErr != nil {
-			fmt.Println("Local IP error:", lipErr)
-		} else {
-			// job beschreibt ein einzelnes Ziel für die ADS-State-Abfrage.
-			type job struct {
-				ip    string
-				netid string
-			}
+				for j := range jobs {
+					res := queryDeviceAds(p, j.ip, j.netid, j.tcVersion)
+
+					// Fehler für die Scan-Zusammenfassung
+					if res.Err != "" {
+						kind := "ads"
+						if strings.HasPrefix(res.Err, "route:") {
+							kind = "route"
+						}
+						scanStateError(kind, j.ip, res.Err)
+					}
 
-			// Alle UDP-gefundenen Ziele mit AMS Net ID einsammeln.
-			targets := make([]job, 0, len(plcs))
-			for _, d := range plcs {
-				if d.AmsNetID == "" {
-					continue
+					jobDone(j.ip)
 				}
+			}()
+		}
 
-				targets = append(targets, job{
-					ip:    d.Address.String(),
-					netid: d.AmsNetID,
-				})
-			}
+		// Jobs an die Worker verteilen.
+		for _, t := range targets {
+			jobs <- t
+		}
+		close(jobs)
 
-			const workers = 8
-			const showErrorsInUI = true
+		wg.Wait()
+	}
+	scanStatePhaseDone(scanPhaseAdsState, -1)
 
-			jobs := make(chan job, len(targets))
-			var wg sync.WaitGroup
+	// --------------------------------------------------------
+	// 5) Ping / MAC / Hostname parallel ergänzen
+	// --------------------------------------------------------
 
-			// Workerpool für ADS-State-Abfragen.
-			for w := 0; w < workers; w++ {
-				wg.Add(1)
+	// Diese Phase läuft unabhängig von ADS:
+	// - Ping: ist das Gerät aktuell erreichbar?
+	// - ARP: welche MAC hat es?
+	// - Reverse DNS: welcher Hostname ist bekannt?
+	pingJobs := make(chan string, len(hostIPs))
+	var wgPing sync.WaitGroup
 
-				go func() {
-					defer wg.Done()
+	scanStatePhase(scanPhasePing, len(hostIPs))
 
-					for j := range jobs {
-						remoteIP := net.ParseIP(j.ip).To4()
-						if remoteIP == nil {
-							continue
-						}
+	for w := 1; w <= cfg.PingWorkers; w++ {
+		wgPing.Add(1)
 
-						// Einzelnes Gerät mit Timeout abfragen.
-						cctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
-						res := TryReadTCState(cctx, localIP, remoteIP, j.netid)
-						cancel()
-
-						// Ergebnis ins Inventory zurückschreiben.
-						inventoryMutex.Lock()
-						if dev, ok := inventory[j.ip]; ok {
-							if res.Err == "" && res.Status != "" {
-								// Erfolgreicher ADS-State-Read.
-								dev.RuntimeStatus = res.Status
-
-								// Erfolgreichen Port merken (für spätere Erweiterungen / Optimierungen).
-								if res.RuntimePort != 0 {
-									dev.RuntimePort = res.RuntimePort
-
-									// ADS-Erfolg bedeutet: Gerät ist erreichbar
-									dev.IsReachable = true
-									dev.LastSeenOnline = time.Now()
-								}
-
-								dev.RouteKnownGood = true
-								dev.LastRouteOK = time.Now()
-							} else if showErrorsInUI {
-								// Bekannter Spezialfall:
-								// 0x00000006 bedeutet praktisch meistens:
-								// "der angefragte Runtime-Port existiert dort nicht".
-								if strings.Contains(res.Err, "0x00000006") {
-									dev.RuntimeStatus = "No Runtime"
-								} else {
-									// Alle anderen Fehler zunächst direkt sichtbar machen.
-									dev.RuntimeStatus = fmt.Sprintf("%s (%s)", res.Status, res.Err)
-								}
-							}
-						}
-						inventoryMutex.Unlock()
-					}
-				}()
-			}
+		go func() {
+			defer wgPing.Done()
+
+			for ip := range pingJobs {
+				probeDevice(p, ip)
 
-			// Jobs an die Worker verteilen.
-			for _, t := range targets {
-				jobs <- t
+				publishDevices(ip)
+				scanStateStep(scanPhasePing)
 			}
-			close(jobs)
+		}()
+	}
 
-			wg.Wait()
-		}
+	// Alle IPs der Scan-Bereiche in die Ping-Queue legen.
+	for _, ip := range hostIPs {
+		pingJobs <- ip
+	}
+	close(pingJobs)
+
+	wgPing.Wait()
+	scanStatePhaseDone(scanPhasePing, -1)
+
+	// --------------------------------------------------------
+	// 6) Scan sauber beenden, Historie und Snapshot speichern
+	// --------------------------------------------------------
+
+	inventoryMutex.Lock()
+	after := snapshotByMACLocked()
+	isScanning = false
+	inventoryMutex.Unlock()
+
+	// Änderungen gegenüber dem Stand vor dem Scan
+	// (Online/Offline, RUN/STOP, TwinCAT-Version, IP-Wechsel, ...)
+	// an die Historie der jeweiligen Geräte anhängen.
+	if err := appendDeviceEvents(diffDeviceStates(before, after, time.Now())); err != nil {
+		fmt.Println("History save error:", err)
+		scanStateError("save", "", "history: "+err.Error())
+	}
 
-		// --------------------------------------------------------
-		// 5) Ping / MAC / Hostname parallel ergänzen
-		// --------------------------------------------------------
+	// Alarm-Regeln gegen denselben Vorher/Nachher-Stand prüfen
+	evaluateAlerts(before, after, time.Now())
 
-		// Diese Phase läuft unabhängig von ADS:
-		// - Ping: ist das Gerät aktuell erreichbar?
-		// - ARP: welche MAC hat es?
-		// - Reverse DNS: welcher Hostname ist bekannt?
-		pingJobs := make(chan string, 254)
-		var wgPing sync.WaitGroup
+	if err := saveSnapshot(); err != nil {
+		fmt.Println("Snapshot save error:", err)
+		scanStateError("save", "", "snapshot: "+err.Error())
+	}
 
-		for w := 1; w <= 20; w++ {
-			wgPing.Add(1)
+	// Entfernte Zeilen (MAC-Deduplizierung) nachziehen und Scan-Ende melden
+	publishAllDevices()
+	scanStateFinish()
+	fmt.Println("Scan abgeschlossen.", time.Now().Format("15:04:05"))
+}
 
-			go func() {
-				defer wgPing.Done()
+// ------------------------------------------------------------
+// Einzelschritte pro Gerät
+// ------------------------------------------------------------
+//
+// Werden vom Vollscan (runScanPass) und vom Einzel-Rescan
+// (siehe rescan.go) gleichermaßen verwendet.
+
+// showErrorsInUI zeigt ADS-Fehler direkt in der Spalte "TC State".
+const showErrorsInUI = true
+
+// applyDiscoveryLocked übernimmt die Daten einer UDP-Discovery-Antwort.
+//
+// WICHTIG:
+// Nur unter inventoryMutex.Lock() aufrufen.
+func applyDiscoveryLocked(dev *IPC, d RemotePlcInfo) {
+	dev.AmsNetID = d.AmsNetID
+	dev.OSVersion = d.OsVersion
+	dev.TwinCATVersion = d.TcVersion.String()
+
+	// Basiswert aus UDP-Discovery.
+	// Dieser wird später ggf. durch echten ADS ReadState überschrieben.
+	dev.RuntimeStatus = d.IsRuntime
+
+	// Hostname aus UDP nur übernehmen, wenn noch keiner bekannt ist.
+	if dev.Hostname == "" && d.Hostname != "" {
+		dev.Hostname = d.Hostname
+	}
 
-				for ip := range pingJobs {
-					reachable := pingDevice(ip)
+	dev.LastUpdate = time.Now()
+}
 
-					var mac, host string
-					if reachable {
-						mac = getMACAddress(ip)
-						host = getHostname(ip)
-					}
+// queryDeviceAds legt bei Bedarf die ADS-Route an, liest den
+// TwinCAT-/Runtime-State eines Geräts und schreibt das Ergebnis
+// ins Inventory.
+func queryDeviceAds(p Prober, ip, netid, tcVersion string) PlcStateResult {
+	remoteIP := net.ParseIP(ip).To4()
+	if remoteIP == nil {
+		return PlcStateResult{Status: "no Info", Err: "invalid ip " + ip}
+	}
 
-					now := time.Now()
+	localIP, err := localIPForRemote(remoteIP)
+	if err != nil {
+		fmt.Println("Local IP error:", err)
+		return PlcStateResult{Status: "no Info", Err: "local ip: " + err.Error()}
+	}
 
-					inventoryMutex.Lock()
+	// Zugangsdaten für die Route anhand von MAC/Büro wählen.
+	// Bei neuen Geräten ist die MAC noch nicht im Inventar,
+	// steht nach der UDP-Antwort aber bereits in der ARP-Tabelle.
+	//
+	// Zusätzlich den bisherigen Routen-Zustand mitgeben,
+	// damit bekannte Routen nicht neu angelegt werden.
+	inventoryMutex.Lock()
+	var mac string
+	var route routeCacheEntry
+	if dev, ok := inventory[ip]; ok {
+		mac = dev.MACAddress
+		route = routeCacheFromDevice(dev)
+	}
+	inventoryMutex.Unlock()
 
-					// Wenn das Gerät online ist und wir eine MAC haben,
-					// prüfen wir, ob es bereits unter anderer IP existiert
-					// und ggf. per MAC dedupliziert werden muss.
-					if reachable && mac != "" {
-						dedupByMACIfNeededLocked(ip, mac)
-					}
+	if mac == "" {
+		mac = p.MAC(ip)
+	}
 
-					// Gerätedaten aktualisieren.
-					if dev, ok := inventory[ip]; ok {
-						dev.IsReachable = dev.IsReachable || reachable
-						dev.LastScan = now
+	cred, _ := routeCredentialFor(mac, getOfficeForMAC(mac))
 
-						if reachable {
-							dev.LastSeenOnline = now
+	// Einzelnes Gerät mit Timeout abfragen.
+	cctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.AdsDeviceTimeout))
+	res := TryReadTCState(cctx, localIP, remoteIP, netid, tcVersion, cred, route)
+	cancel()
 
-							if mac != "" {
-								dev.MACAddress = normalizeMAC(mac)
-							}
-							if dev.Hostname == "" && host != "" {
-								dev.Hostname = host
-							}
-						}
+	// Ergebnis ins Inventory zurückschreiben.
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
 
-						// Zusatzinformationen anhand der MAC nachladen.
-						if dev.MACAddress != "" {
-							dev.Office = getOfficeForMAC(dev.MACAddress)
-							dev.Comment = getCommentForMAC(dev.MACAddress)
-						}
-					}
+	dev, ok := inventory[ip]
+	if !ok {
+		return res
+	}
 
-					inventoryMutex.Unlock()
-				}
-			}()
+	res.Route.applyToDevice(dev)
+
+	if res.Err == "" && res.Status != "" {
+		// Erfolgreicher ADS-State-Read.
+		dev.RuntimeStatus = res.Status
+
+		if res.DeviceName != "" {
+			dev.DeviceName = res.DeviceName
+			dev.DeviceVersion = res.DeviceVersion
 		}
 
-		// Alle 254 IPs des Testnetzes in die Ping-Queue legen.
-		for i := 1; i <= 254; i++ {
-			pingJobs <- fmt.Sprintf("172.17.76.%d", i)
+		dev.Runtimes = res.Runtimes
+
+		// Erfolgreichen Port merken (für spätere Erweiterungen / Optimierungen).
+		if res.RuntimePort != 0 {
+			dev.RuntimePort = res.RuntimePort
+
+			// ADS-Erfolg bedeutet: Gerät ist erreichbar
+			dev.IsReachable = true
+			dev.LastSeenOnline = time.Now()
+		}
+	} else if showErrorsInUI {
+		// Bekannter Spezialfall:
+		// 0x00000006 bedeutet praktisch meistens:
+		// "der angefragte Runtime-Port existiert dort nicht".
+		if strings.Contains(res.Err, "0x00000006") {
+			dev.RuntimeStatus = "No Runtime"
+		} else {
+			// Alle anderen Fehler zunächst direkt sichtbar machen.
+			dev.RuntimeStatus = fmt.Sprintf("%s (%s)", res.Status, res.Err)
 		}
-		close(pingJobs)
+	}
 
-		wgPing.Wait()
+	return res
+}
 
-		// --------------------------------------------------------
-		// 6) Scan sauber beenden und Snapshot speichern
-		// --------------------------------------------------------
+// probeResult ist das Ergebnis von Ping, ARP und Hostname-Auflösung.
+type probeResult struct {
+	Reachable bool
+	MAC       string
+	Hostname  string
+}
 
-		inventoryMutex.Lock()
-		isScanning = false
-		inventoryMutex.Unlock()
+// probeAddress ermittelt Erreichbarkeit (Ping), MAC (ARP) und Hostname
+// einer Adresse, ohne das Inventory zu ändern.
+func probeAddress(p Prober, ip string) probeResult {
+	r := probeResult{Reachable: p.Ping(ip, time.Duration(cfg.PingTimeout))}
+	if r.Reachable {
+		r.MAC = p.MAC(ip)
+		r.Hostname = p.Hostname(ip)
+	}
+	return r
+}
+
+// applyProbeLocked schreibt ein Probe-Ergebnis ins Inventory.
+//
+// Die Erreichbarkeit wird mit dem bisherigen Wert ODER-verknüpft,
+// weil z. B. ein erfolgreicher ADS-Zugriff das Gerät schon als
+// erreichbar markiert haben kann (Ping gesperrt).
+//
+// WICHTIG:
+// Nur unter inventoryMutex.Lock() aufrufen.
+func applyProbeLocked(ip string, r probeResult, now time.Time) {
+	// Wenn das Gerät online ist und wir eine MAC haben,
+	// prüfen wir, ob es bereits unter anderer IP existiert
+	// und ggf. per MAC dedupliziert werden muss.
+	if r.Reachable && r.MAC != "" {
+		dedupByMACIfNeededLocked(ip, r.MAC)
+	}
+
+	// Gerätedaten aktualisieren.
+	dev, ok := inventory[ip]
+	if !ok {
+		return
+	}
 
-		if err := saveSnapshot(); err != nil {
-			fmt.Println("Snapshot save error:", err)
+	dev.IsReachable = dev.IsReachable || r.Reachable
+	dev.LastScan = now
+
+	if r.Reachable {
+		dev.LastSeenOnline = now
+
+		if r.MAC != "" {
+			dev.MACAddress = normalizeMAC(r.MAC)
+		}
+		if dev.Hostname == "" && r.Hostname != "" {
+			dev.Hostname = r.Hostname
 		}
+	}
 
-		fmt.Println("Scan abgeschlossen.", time.Now().Format("15:04:05"))
+	// Zusatzinformationen anhand der MAC nachladen.
+	if dev.MACAddress != "" {
+		dev.Office = getOfficeForMAC(dev.MACAddress)
+		dev.Comment = getCommentForMAC(dev.MACAddress)
 	}
 }
+
+// probeDevice ermittelt Erreichbarkeit, MAC und Hostname
+// einer Adresse und schreibt sie ins Inventory.
+//
+// Liefert das Ping-Ergebnis.
+func probeDevice(p Prober, ip string) bool {
+	r := probeAddress(p, ip)
+
+	inventoryMutex.Lock()
+	applyProbeLocked(ip, r, time.Now())
+	inventoryMutex.Unlock()
+
+	return r.Reachable
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProber liefert feste Antworten je IP.
+type fakeProber struct {
+	mu    sync.Mutex
+	up    map[string]bool
+	macs  map[string]string
+	hosts map[string]string
+}
+
+func (f *fakeProber) Ping(ip string, timeout time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.up[ip]
+}
+
+func (f *fakeProber) MAC(ip string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.macs[ip]
+}
+
+func (f *fakeProber) Hostname(ip string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hosts[ip]
+}
+
+// noDiscovery simuliert ein Netz ohne ADS-Geräte.
+func noDiscovery(ctx context.Context, listen time.Duration) ([]RemotePlcInfo, error) {
+	return nil, nil
+}
+
+// setupScanTest setzt Konfiguration, Scan-Bereich und Storage
+// für einen Scan-Durchlauf zurück.
+func setupScanTest(t *testing.T, cidr string) {
+	t.Helper()
+
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = defaultConfig()
+	cfg.DataDir = t.TempDir()
+	scanNets = []*net.IPNet{ipnet}
+	store = newJSONStorage(cfg.DataDir)
+
+	inventoryMutex.Lock()
+	inventory = make(map[string]*IPC)
+	inventoryMutex.Unlock()
+
+	officeMutex.Lock()
+	officeAssignments = make(map[string]string)
+	officeMutex.Unlock()
+
+	commentMutex.Lock()
+	deviceComments = make(map[string]string)
+	commentMutex.Unlock()
+}
+
+func TestRunScanPassSkeletonAndMAC(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29") // 10.99.0.1 .. 10.99.0.6
+
+	officeAssignments["00:01:05:AA:BB:01"] = "T4015"
+
+	p := &fakeProber{
+		up:    map[string]bool{"10.99.0.2": true},
+		macs:  map[string]string{"10.99.0.2": "00:01:05:AA:BB:01"},
+		hosts: map[string]string{"10.99.0.2": "plc1"},
+	}
+
+	runScanPass(p, noDiscovery)
+
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	if len(inventory) != 6 {
+		t.Fatalf("inventory has %d entries, want 6", len(inventory))
+	}
+
+	for ip, dev := range inventory {
+		if ip == "10.99.0.2" {
+			continue
+		}
+		if dev.IsReachable || dev.MACAddress != "" {
+			t.Errorf("%s: reachable=%v mac=%q, want offline without mac", ip, dev.IsReachable, dev.MACAddress)
+		}
+	}
+
+	dev := inventory["10.99.0.2"]
+	if !dev.IsReachable || dev.LastSeenOnline.IsZero() {
+		t.Errorf("10.99.0.2 not marked online")
+	}
+	if dev.MACAddress != "00:01:05:AA:BB:01" || dev.Hostname != "plc1" {
+		t.Errorf("10.99.0.2: mac=%q host=%q", dev.MACAddress, dev.Hostname)
+	}
+	if dev.Office != "T4015" {
+		t.Errorf("10.99.0.2: office=%q, want T4015 from assignment", dev.Office)
+	}
+}
+
+func TestRunScanPassDedupByMAC(t *testing.T) {
+	setupScanTest(t, "10.99.0.0/29")
+
+	// Gerät war unter .5 bekannt und meldet sich jetzt unter .3
+	inventory["10.99.0.5"] = &IPC{
+		IP:             "10.99.0.5",
+		MACAddress:     "00:01:05:AA:BB:02",
+		AmsNetID:       "10.99.0.5.1.1",
+		TwinCATVersion: "3.1.4024",
+		LastSeenOnline: time.Now().Add(-time.Hour),
+	}
+
+	p := &fakeProber{
+		up:   map[string]bool{"10.99.0.3": true},
+		macs: map[string]string{"10.99.0.3": "00-01-05-aa-bb-02"},
+	}
+
+	runScanPass(p, noDiscovery)
+
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	if _, ok := inventory["10.99.0.5"]; ok && inventory["10.99.0.5"].MACAddress != "" {
+		t.Errorf("old entry 10.99.0.5 still carries the MAC")
+	}
+
+	dev := inventory["10.99.0.3"]
+	if dev == nil {
+		t.Fatal("10.99.0.3 missing")
+	}
+	if dev.MACAddress != "00:01:05:AA:BB:02" {
+		t.Errorf("mac=%q, want normalized 00:01:05:AA:BB:02", dev.MACAddress)
+	}
+	if dev.AmsNetID != "10.99.0.5.1.1" || dev.TwinCATVersion != "3.1.4024" {
+		t.Errorf("data not migrated: ams=%q tc=%q", dev.AmsNetID, dev.TwinCATVersion)
+	}
+
+	n := 0
+	for _, d := range inventory {
+		if d.MACAddress == "00:01:05:AA:BB:02" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("%d entries with the MAC, want 1", n)
+	}
+}
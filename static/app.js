@@ -132,6 +132,10 @@ function enableDragDrop() {
   applyFavoriteFilter();
   enableOfficeAssignment();
   enableCommentAssignment();
+  enableStateControl();
+  enableLiveUpdates();
+  loadScanStatus();
+  enableRescan();
 
 });
 
@@ -379,6 +383,50 @@ function enableCommentAssignment() {
   });
 }
 
+function enableStateControl() {
+  document.addEventListener("change", async (e) => {
+    const select = e.target.closest(".state-action[data-id]");
+    if (!select || !select.value) return;
+
+    const [action, port] = select.value.split(":");
+    const label = select.options[select.selectedIndex].textContent;
+    const id = select.dataset.id;
+
+    // Auswahl zurücksetzen, damit dieselbe Aktion erneut gewählt werden kann
+    select.value = "";
+
+    if (!confirm(`${id}: "${label}" wirklich ausführen?`)) return;
+
+    select.disabled = true;
+
+    try {
+      const res = await fetch(`/api/devices/${encodeURIComponent(id)}/state`, {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json"
+        },
+        body: JSON.stringify({
+          action: action,
+          port: Number(port)
+        })
+      });
+
+      if (!res.ok) {
+        alert("Aktion fehlgeschlagen: " + (await res.text()));
+        return;
+      }
+
+      const data = await res.json();
+      alert(`${id}: ${label} ausgeführt` + (data.state ? ` (jetzt ${data.state})` : ""));
+      location.reload();
+    } catch (err) {
+      alert("Fehler beim Ändern des TwinCAT-States.");
+    } finally {
+      select.disabled = false;
+    }
+  });
+}
+
 function toggleFavoriteFilter() {
   const active = isFavoriteFilterActive();
   setFavoriteFilter(!active);
@@ -465,3 +513,211 @@ function enableColumnResize() {
   });
 }
 
+// ------------------------------------------------------------
+// Live-Updates (Server-Sent Events, siehe events.go)
+// ------------------------------------------------------------
+
+const SCAN_PHASE_LABELS = {
+  udp_discovery: "Discovery",
+  ads_state: "ADS",
+  ping: "Ping"
+};
+
+function enableLiveUpdates() {
+  if (!window.EventSource) return;
+
+  const source = new EventSource("/api/events");
+
+  source.addEventListener("open", () => {
+    window.liveUpdatesActive = true;
+  });
+
+  source.addEventListener("error", () => {
+    // EventSource verbindet sich selbst neu; bis dahin Fallback auf Polling
+    window.liveUpdatesActive = false;
+  });
+
+  source.addEventListener("device", (e) => {
+    const data = JSON.parse(e.data);
+    if (data.removed) {
+      removeDeviceRow(data.ip);
+    } else {
+      patchDeviceRow(data.ip, data.html);
+    }
+  });
+
+  source.addEventListener("stats", (e) => {
+    const stats = JSON.parse(e.data);
+    setText("statKnown", stats.known);
+    setText("statOnline", stats.online);
+    setText("statOffline", stats.offline);
+  });
+
+  source.addEventListener("scan", (e) => {
+    updateScanButton(JSON.parse(e.data));
+  });
+}
+
+function setText(id, value) {
+  const el = document.getElementById(id);
+  if (el) el.textContent = value;
+}
+
+function findDeviceRow(ip) {
+  return document.querySelector(`#deviceTable tbody tr[data-ip="${CSS.escape(ip)}"]`);
+}
+
+// Tauscht die Zellen einer Zeile einzeln aus, damit Spaltenreihenfolge
+// erhalten bleibt und ein gerade bearbeitetes Feld (Kommentar, Büro)
+// nicht unter dem Cursor verschwindet.
+function patchDeviceRow(ip, html) {
+  const tbody = document.querySelector("#deviceTable tbody");
+  if (!tbody) return;
+
+  const tmp = document.createElement("tbody");
+  tmp.innerHTML = html.trim();
+  const newRow = tmp.querySelector("tr");
+  if (!newRow) return;
+
+  const row = findDeviceRow(ip);
+
+  if (!row) {
+    tbody.appendChild(newRow);
+    const order = loadOrder();
+    if (order) applyColumnOrder(order);
+  } else {
+    row.dataset.office = newRow.dataset.office || "";
+
+    Array.from(newRow.children).forEach(td => {
+      const old = row.querySelector(`td[data-col="${td.dataset.col}"]`);
+      if (!old) return;
+      if (old.contains(document.activeElement)) return;
+      old.replaceWith(td);
+    });
+  }
+
+  applyFavoritesUI();
+  sortTableWithFavorites();
+  applyFavoriteFilter();
+
+  const search = document.getElementById("searchInput");
+  if (search && search.value) filterTable();
+}
+
+function removeDeviceRow(ip) {
+  const row = findDeviceRow(ip);
+  if (row) row.remove();
+}
+
+// ------------------------------------------------------------
+// Scan-Fortschritt (siehe scan_state.go)
+// ------------------------------------------------------------
+
+function loadScanStatus() {
+  fetch("/api/scan-status")
+    .then(r => r.json())
+    .then(updateScanButton)
+    .catch(() => {});
+}
+
+function formatClock(iso) {
+  if (!iso) return "";
+  return new Date(iso).toLocaleTimeString("de-DE");
+}
+
+function updateScanButton(scan) {
+  const btn = document.getElementById("scanBtn");
+  const box = document.getElementById("scanProgress");
+  const bar = document.getElementById("scanProgressBar");
+  const text = document.getElementById("scanProgressText");
+
+  updateLastScanInfo(scan.lastScan);
+
+  if (!scan.isScanning) {
+    if (btn) {
+      btn.disabled = false;
+      btn.innerText = "Scan";
+    }
+    if (box) box.classList.remove("active");
+    return;
+  }
+
+  const phase = (scan.phases || []).find(p => p.name === scan.phase) || { done: 0, total: 0 };
+  const label = SCAN_PHASE_LABELS[scan.phase] || "Scan";
+
+  if (btn) {
+    btn.disabled = true;
+    btn.innerText = phase.total > 0 ? `${label} ${phase.done}/${phase.total}` : `${label}...`;
+  }
+
+  if (box) box.classList.add("active");
+
+  // Balken: Anteil der abgeschlossenen Phasen + Fortschritt der aktuellen
+  const phaseNames = Object.keys(SCAN_PHASE_LABELS);
+  const index = Math.max(phaseNames.indexOf(scan.phase), 0);
+  const inPhase = phase.total > 0 ? phase.done / phase.total : 0;
+  const percent = Math.round(((index + inPhase) / phaseNames.length) * 100);
+
+  if (bar) bar.style.width = percent + "%";
+
+  if (text) {
+    let t = phase.total > 0 ? `${label}: ${phase.done}/${phase.total}` : `${label} läuft`;
+    if (scan.eta) t += ` · fertig ca. ${formatClock(scan.eta)}`;
+    const errors = scan.errors ? scan.errors.adsFailed + scan.errors.saveErrors + (scan.errors.discovery ? 1 : 0) : 0;
+    if (errors > 0) t += ` · ${errors} Fehler`;
+    text.textContent = t;
+  }
+}
+
+function updateLastScanInfo(last) {
+  const el = document.getElementById("lastScanInfo");
+  if (!el || !last) return;
+
+  const e = last.errors || {};
+  const errors = (e.adsFailed || 0) + (e.saveErrors || 0) + (e.discovery ? 1 : 0);
+
+  el.textContent = `Letzter Scan: ${formatClock(last.finishedAt)}, ${Math.round(last.durationSeconds)} s` +
+    (errors > 0 ? `, ${errors} Fehler` : "");
+
+  const details = [];
+  if (e.discovery) details.push("Discovery: " + e.discovery);
+  if (e.adsFailed) details.push(`ADS fehlgeschlagen: ${e.adsFailed} (davon Route: ${e.routeFailed || 0})`);
+  if (e.saveErrors) details.push(`Speicherfehler: ${e.saveErrors}`);
+  (e.messages || []).forEach(m => details.push(m));
+  if (e.moreMessages) details.push(`... und ${e.moreMessages} weitere`);
+  el.title = details.join("\n");
+}
+
+// ------------------------------------------------------------
+// Einzel-Rescan (siehe rescan.go)
+// ------------------------------------------------------------
+
+function enableRescan() {
+  document.addEventListener("click", async (e) => {
+    const btn = e.target.closest(".rescan-btn[data-id]");
+    if (!btn || btn.classList.contains("running")) return;
+
+    const id = btn.dataset.id;
+    btn.classList.add("running");
+
+    try {
+      const res = await fetch(`/api/devices/${encodeURIComponent(id)}/rescan`, {
+        method: "POST"
+      });
+
+      if (res.status === 409) return;
+
+      if (!res.ok) {
+        alert("Rescan fehlgeschlagen: " + (await res.text()));
+        return;
+      }
+
+      // Mit Live-Updates kommt die neue Zeile per SSE
+      if (!window.liveUpdatesActive) location.reload();
+    } catch (err) {
+      alert("Fehler beim Rescan von " + id);
+    } finally {
+      btn.classList.remove("running");
+    }
+  });
+}
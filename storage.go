@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ------------------------------------------------------------
+// Persistenz-Schnittstelle
+// ------------------------------------------------------------
+//
+// Storage kapselt, wo Inventory, Office-Zuordnungen, Kommentare
+// und weitere Daten dauerhaft abgelegt werden.
+//
+// Implementierungen:
+//
+//	boltStorage  eingebettete Datenbank (bbolt), Standard
+//	jsonStorage  die bisherigen JSON-Dateien im Datenverzeichnis
+//
+// Lesen erfolgt direkt über die Load*-Methoden,
+// Schreiben immer über Update(), damit mehrere Änderungen
+// gemeinsam (transaktional) gespeichert werden können.
+type Storage interface {
+	// Name liefert den Backend-Namen für Konsolenausgaben.
+	Name() string
+
+	// LoadInventory liefert das gespeicherte Inventory und den Speicherzeitpunkt.
+	// Ohne gespeicherte Daten: leere Map, Nullzeit, kein Fehler.
+	LoadInventory() (map[string]*IPC, time.Time, error)
+
+	// LoadOfficeAssignments liefert die Zuordnung MAC -> Office.
+	LoadOfficeAssignments() (map[string]string, error)
+
+	// LoadComments liefert die Zuordnung MAC -> Kommentar.
+	LoadComments() (map[string]string, error)
+
+	// LoadDocument liest ein beliebiges JSON-Dokument unter einem Namen.
+	// found ist false, wenn das Dokument noch nicht existiert.
+	LoadDocument(name string, v any) (found bool, err error)
+
+	// ReadEvents liest alle Events eines Streams zu einem Schlüssel
+	// in Schreibreihenfolge (z. B. stream "history", key = MAC).
+	ReadEvents(stream, key string, fn func(raw []byte) error) error
+
+	// Update führt fn als eine Schreibtransaktion aus.
+	// Liefert fn einen Fehler, wird nichts geschrieben.
+	Update(fn func(tx StorageTx) error) error
+
+	// Close schließt das Backend.
+	Close() error
+}
+
+// StorageTx sind die Schreiboperationen innerhalb von Storage.Update().
+type StorageTx interface {
+	PutInventory(inv map[string]*IPC, savedAt time.Time) error
+	PutOfficeAssignments(m map[string]string) error
+	PutComments(m map[string]string) error
+	PutDocument(name string, v any) error
+
+	// AppendEvent hängt ein Event an einen Stream an (nur Anhängen, nie Überschreiben).
+	AppendEvent(stream, key string, v any) error
+}
+
+// Backend-Namen für die Konfiguration
+const (
+	storageBolt = "bolt"
+	storageJSON = "json"
+)
+
+// store ist das beim Start geöffnete Persistenz-Backend.
+var store Storage
+
+// openStorage öffnet das konfigurierte Backend.
+//
+// Bei "bolt" werden beim ersten Start vorhandene JSON-Dateien
+// automatisch in die Datenbank übernommen.
+func openStorage(c Config) (Storage, error) {
+	switch c.Storage {
+	case storageJSON:
+		return newJSONStorage(c.DataDir), nil
+	case storageBolt:
+		return openBoltStorage(c.DataDir)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", c.Storage)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ------------------------------------------------------------
+// bbolt-Backend
+// ------------------------------------------------------------
+//
+// boltStorage speichert alle Daten in einer einzigen Datei:
+//
+//	<dataDir>/inventar.db
+//
+// Aufbau (Buckets):
+//
+//	meta        Metadaten (saved_at, Migrationsstand)
+//	inventory   IP  -> IPC als JSON
+//	offices     MAC -> Office
+//	comments    MAC -> Kommentar
+//	documents   Name -> beliebiges JSON-Dokument
+//	events      Stream -> Key -> laufende Nummer -> Event als JSON
+//
+// Jedes Update() ist eine echte bbolt-Transaktion:
+// entweder werden alle Änderungen geschrieben oder keine.
+type boltStorage struct {
+	db *bolt.DB
+}
+
+var (
+	boltBucketMeta      = []byte("meta")
+	boltBucketInventory = []byte("inventory")
+	boltBucketOffices   = []byte("offices")
+	boltBucketComments  = []byte("comments")
+	boltBucketDocuments = []byte("documents")
+	boltBucketEvents    = []byte("events")
+
+	boltKeySavedAt      = []byte("saved_at")
+	boltKeyJSONMigrated = []byte("json_migrated")
+)
+
+// openBoltStorage öffnet (oder erstellt) die Datenbank
+// und übernimmt beim ersten Start vorhandene JSON-Dateien.
+func openBoltStorage(dir string) (*boltStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("mkdir data dir: %w", err)
+	}
+
+	// Timeout verhindert ein endloses Warten,
+	// falls eine zweite Instanz die Datei bereits geöffnet hat.
+	db, err := bolt.Open(filepath.Join(dir, "inventar.db"), 0644, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	s := &boltStorage{db: db}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{
+			boltBucketMeta, boltBucketInventory, boltBucketOffices,
+			boltBucketComments, boltBucketDocuments, boltBucketEvents,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init buckets: %w", err)
+	}
+
+	if err := s.migrateFromJSON(dir); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate json files: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *boltStorage) Name() string { return storageBolt }
+
+func (s *boltStorage) Close() error { return s.db.Close() }
+
+// ------------------------------------------------------------
+// Migration der JSON-Dateien
+// ------------------------------------------------------------
+
+// migrateFromJSON übernimmt beim ersten Start die bisherigen Dateien
+// (Snapshot, offices.json, comments.json, history/*.jsonl)
+// in einer einzigen Transaktion.
+//
+// Die JSON-Dateien bleiben unverändert liegen und dienen als Backup.
+// Ob migriert wurde, merkt sich der Schlüssel meta/json_migrated.
+func (s *boltStorage) migrateFromJSON(dir string) error {
+	done := false
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		done = tx.Bucket(boltBucketMeta).Get(boltKeyJSONMigrated) != nil
+		return nil
+	})
+	if done {
+		return nil
+	}
+
+	old := newJSONStorage(dir)
+
+	inv, savedAt, err := old.LoadInventory()
+	if err != nil {
+		return err
+	}
+
+	offices, err := old.LoadOfficeAssignments()
+	if err != nil {
+		return err
+	}
+
+	comments, err := old.LoadComments()
+	if err != nil {
+		return err
+	}
+
+	historyKeys, err := old.eventKeys(historyStream)
+	if err != nil {
+		return err
+	}
+
+	events := 0
+
+	err = s.Update(func(tx StorageTx) error {
+		if len(inv) > 0 {
+			if err := tx.PutInventory(inv, savedAt); err != nil {
+				return err
+			}
+		}
+		if err := tx.PutOfficeAssignments(offices); err != nil {
+			return err
+		}
+		if err := tx.PutComments(comments); err != nil {
+			return err
+		}
+
+		for _, key := range historyKeys {
+			err := old.ReadEvents(historyStream, key, func(raw []byte) error {
+				events++
+				return tx.AppendEvent(historyStream, key, json.RawMessage(raw))
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		btx := tx.(*boltTx)
+		return btx.tx.Bucket(boltBucketMeta).Put(boltKeyJSONMigrated, []byte(time.Now().Format(time.RFC3339)))
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(inv) > 0 || len(offices) > 0 || len(comments) > 0 || events > 0 {
+		fmt.Printf(
+			"Datenbank: JSON-Dateien übernommen (%d Geräte, %d Offices, %d Kommentare, %d Verlaufseinträge)\n",
+			len(inv), len(offices), len(comments), events,
+		)
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------
+// Lesen
+// ------------------------------------------------------------
+
+func (s *boltStorage) LoadInventory() (map[string]*IPC, time.Time, error) {
+	inv := make(map[string]*IPC)
+	var savedAt time.Time
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltBucketMeta).Get(boltKeySavedAt); v != nil {
+			_ = savedAt.UnmarshalText(v)
+		}
+
+		return tx.Bucket(boltBucketInventory).ForEach(func(k, v []byte) error {
+			var dev IPC
+			if err := json.Unmarshal(v, &dev); err != nil {
+				return fmt.Errorf("unmarshal device %s: %w", k, err)
+			}
+			inv[string(k)] = &dev
+			return nil
+		})
+	})
+
+	return inv, savedAt, err
+}
+
+// loadBoltStringMap liest einen Bucket mit String-Werten.
+func (s *boltStorage) loadBoltStringMap(bucket []byte) (map[string]string, error) {
+	out := make(map[string]string)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+			out[string(k)] = string(v)
+			return nil
+		})
+	})
+
+	return out, err
+}
+
+func (s *boltStorage) LoadOfficeAssignments() (map[string]string, error) {
+	return s.loadBoltStringMap(boltBucketOffices)
+}
+
+func (s *boltStorage) LoadComments() (map[string]string, error) {
+	return s.loadBoltStringMap(boltBucketComments)
+}
+
+func (s *boltStorage) LoadDocument(name string, v any) (bool, error) {
+	var data []byte
+
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		if raw := tx.Bucket(boltBucketDocuments).Get([]byte(name)); raw != nil {
+			data = append([]byte(nil), raw...)
+		}
+		return nil
+	})
+
+	if data == nil {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("unmarshal document %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+func (s *boltStorage) ReadEvents(stream, key string, fn func(raw []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		sb := tx.Bucket(boltBucketEvents).Bucket([]byte(stream))
+		if sb == nil {
+			return nil
+		}
+
+		kb := sb.Bucket(boltEventKey(key))
+		if kb == nil {
+			return nil
+		}
+
+		// Schlüssel sind big-endian Sequenznummern → ForEach liefert Schreibreihenfolge
+		return kb.ForEach(func(_, v []byte) error {
+			return fn(append([]byte(nil), v...))
+		})
+	})
+}
+
+// boltEventKey liefert den Bucket-Namen eines Event-Schlüssels.
+//
+// bbolt erlaubt keine leeren Bucket-Namen, Streams ohne
+// Schlüssel (key == "") landen deshalb unter "_".
+func boltEventKey(key string) []byte {
+	if key == "" {
+		return []byte("_")
+	}
+	return []byte(key)
+}
+
+// ------------------------------------------------------------
+// Schreiben
+// ------------------------------------------------------------
+
+// boltTx setzt StorageTx auf einer offenen bbolt-Transaktion um.
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (s *boltStorage) Update(fn func(tx StorageTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+// replaceBucket leert einen Bucket, indem er gelöscht und neu angelegt wird.
+func (t *boltTx) replaceBucket(name []byte) (*bolt.Bucket, error) {
+	if err := t.tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+		return nil, err
+	}
+	return t.tx.CreateBucket(name)
+}
+
+func (t *boltTx) PutInventory(inv map[string]*IPC, savedAt time.Time) error {
+	b, err := t.replaceBucket(boltBucketInventory)
+	if err != nil {
+		return err
+	}
+
+	for ip, dev := range inv {
+		if dev == nil {
+			continue
+		}
+
+		data, err := json.Marshal(dev)
+		if err != nil {
+			return fmt.Errorf("marshal device %s: %w", ip, err)
+		}
+
+		if err := b.Put([]byte(ip), data); err != nil {
+			return err
+		}
+	}
+
+	ts, err := savedAt.MarshalText()
+	if err != nil {
+		return err
+	}
+
+	return t.tx.Bucket(boltBucketMeta).Put(boltKeySavedAt, ts)
+}
+
+func (t *boltTx) putStringMap(bucket []byte, m map[string]string) error {
+	b, err := t.replaceBucket(bucket)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range m {
+		if err := b.Put([]byte(k), []byte(v)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *boltTx) PutOfficeAssignments(m map[string]string) error {
+	return t.putStringMap(boltBucketOffices, m)
+}
+
+func (t *boltTx) PutComments(m map[string]string) error {
+	return t.putStringMap(boltBucketComments, m)
+}
+
+func (t *boltTx) PutDocument(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal document %s: %w", name, err)
+	}
+
+	return t.tx.Bucket(boltBucketDocuments).Put([]byte(name), data)
+}
+
+func (t *boltTx) AppendEvent(stream, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sb, err := t.tx.Bucket(boltBucketEvents).CreateBucketIfNotExists([]byte(stream))
+	if err != nil {
+		return err
+	}
+
+	kb, err := sb.CreateBucketIfNotExists(boltEventKey(key))
+	if err != nil {
+		return err
+	}
+
+	seq, err := kb.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], seq)
+
+	return kb.Put(k[:], data)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------
+// JSON-Datei-Backend
+// ------------------------------------------------------------
+//
+// jsonStorage speichert wie bisher in einzelnen JSON-Dateien:
+//
+//	<dataDir>/inventory_snapshot.json
+//	<dataDir>/offices.json
+//	<dataDir>/comments.json
+//	<dataDir>/<name>.json               (Dokumente)
+//	<dataDir>/<stream>/<key>.jsonl      (Event-Streams, z. B. history)
+//
+// Jede Datei wird atomar geschrieben (temp-Datei + Rename).
+// Eine Transaktion über mehrere Dateien ist hier nur "best effort":
+// fn wird vollständig ausgeführt, bevor überhaupt geschrieben wird.
+type jsonStorage struct {
+	dir string
+	mu  sync.Mutex // serialisiert Update() und Event-Zugriffe
+}
+
+func newJSONStorage(dir string) *jsonStorage {
+	return &jsonStorage{dir: dir}
+}
+
+func (s *jsonStorage) Name() string { return storageJSON }
+
+func (s *jsonStorage) Close() error { return nil }
+
+// ------------------------------------------------------------
+// Dateipfade
+// ------------------------------------------------------------
+
+func (s *jsonStorage) snapshotPath() string {
+	return filepath.Join(s.dir, "inventory_snapshot.json")
+}
+
+func (s *jsonStorage) officePath() string {
+	return filepath.Join(s.dir, "offices.json")
+}
+
+func (s *jsonStorage) commentPath() string {
+	return filepath.Join(s.dir, "comments.json")
+}
+
+func (s *jsonStorage) documentPath(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// eventPath liefert die Datei eines Event-Streams.
+//
+// Doppelpunkte (MAC-Adressen) sind unter Windows in Dateinamen
+// nicht erlaubt und werden durch "-" ersetzt.
+//
+// Schlüssel mit Pfadtrennern oder ".." werden abgelehnt, damit ein
+// Schlüssel nie auf eine Datei außerhalb des Stream-Verzeichnisses zeigt.
+func (s *jsonStorage) eventPath(stream, key string) (string, error) {
+	if key == "" {
+		return filepath.Join(s.dir, stream+".jsonl"), nil
+	}
+
+	if strings.ContainsAny(key, `/\`) || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid %s key %q", stream, key)
+	}
+
+	name := strings.ReplaceAll(key, ":", "-") + ".jsonl"
+	return filepath.Join(s.dir, stream, name), nil
+}
+
+// ------------------------------------------------------------
+// Lesen
+// ------------------------------------------------------------
+
+// readJSONFile liest eine JSON-Datei.
+// Eine fehlende Datei ist kein Fehler (found = false).
+func readJSONFile(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("unmarshal %s: %w", filepath.Base(path), err)
+	}
+
+	return true, nil
+}
+
+func (s *jsonStorage) LoadInventory() (map[string]*IPC, time.Time, error) {
+	var env snapshotEnvelope
+
+	if _, err := readJSONFile(s.snapshotPath(), &env); err != nil {
+		return nil, time.Time{}, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	if env.Inventory == nil {
+		env.Inventory = make(map[string]*IPC)
+	}
+
+	return env.Inventory, env.SavedAt, nil
+}
+
+// loadStringMap liest eine Map-Datei; nil-Maps werden vermieden.
+func loadStringMap(path string) (map[string]string, error) {
+	var m map[string]string
+
+	if _, err := readJSONFile(path, &m); err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		m = make(map[string]string)
+	}
+
+	return m, nil
+}
+
+func (s *jsonStorage) LoadOfficeAssignments() (map[string]string, error) {
+	return loadStringMap(s.officePath())
+}
+
+func (s *jsonStorage) LoadComments() (map[string]string, error) {
+	return loadStringMap(s.commentPath())
+}
+
+func (s *jsonStorage) LoadDocument(name string, v any) (bool, error) {
+	return readJSONFile(s.documentPath(name), v)
+}
+
+func (s *jsonStorage) ReadEvents(stream, key string, fn func(raw []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.eventPath(stream, key)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := fn(append([]byte(nil), line...)); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
+
+// ------------------------------------------------------------
+// Schreiben
+// ------------------------------------------------------------
+
+// jsonTx sammelt alle Schreiboperationen einer Transaktion.
+// Geschrieben wird erst, wenn fn ohne Fehler durchgelaufen ist.
+type jsonTx struct {
+	s      *jsonStorage
+	writes []func() error
+}
+
+func (s *jsonStorage) Update(fn func(tx StorageTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &jsonTx{s: s}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	for _, w := range tx.writes {
+		if err := w(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeJSONFileAtomic schreibt v lesbar formatiert als JSON.
+//
+// Ablauf:
+// 1. Zielverzeichnis sicherstellen
+// 2. temp-Datei schreiben
+// 3. temp-Datei umbenennen
+//
+// Dadurch bleibt die Datei konsistent,
+// selbst wenn während des Schreibens ein Fehler auftritt.
+func writeJSONFileAtomic(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdir data dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", filepath.Base(path), err)
+	}
+
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write tmp %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+func (tx *jsonTx) PutInventory(inv map[string]*IPC, savedAt time.Time) error {
+	env := snapshotEnvelope{SavedAt: savedAt, Inventory: inv}
+	path := tx.s.snapshotPath()
+
+	tx.writes = append(tx.writes, func() error { return writeJSONFileAtomic(path, env) })
+	return nil
+}
+
+func (tx *jsonTx) PutOfficeAssignments(m map[string]string) error {
+	path := tx.s.officePath()
+
+	tx.writes = append(tx.writes, func() error { return writeJSONFileAtomic(path, m) })
+	return nil
+}
+
+func (tx *jsonTx) PutComments(m map[string]string) error {
+	path := tx.s.commentPath()
+
+	tx.writes = append(tx.writes, func() error { return writeJSONFileAtomic(path, m) })
+	return nil
+}
+
+func (tx *jsonTx) PutDocument(name string, v any) error {
+	path := tx.s.documentPath(name)
+
+	tx.writes = append(tx.writes, func() error { return writeJSONFileAtomic(path, v) })
+	return nil
+}
+
+// AppendEvent hängt eine JSON-Zeile an die Stream-Datei an.
+func (tx *jsonTx) AppendEvent(stream, key string, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	path, err := tx.s.eventPath(stream, key)
+	if err != nil {
+		return err
+	}
+
+	tx.writes = append(tx.writes, func() error {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", stream, err)
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", stream, err)
+		}
+
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return fmt.Errorf("write %s: %w", stream, err)
+		}
+
+		return f.Close()
+	})
+
+	return nil
+}
+
+// eventKeys listet alle Schlüssel eines Streams (für die Migration).
+//
+// Die Dateinamen werden wie in eventPath() zurückübersetzt,
+// also "-" -> ":" (die Schlüssel sind MAC-Adressen).
+func (s *jsonStorage) eventKeys(stream string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, stream))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+
+		key := strings.TrimSuffix(name, ".jsonl")
+		keys = append(keys, strings.ReplaceAll(key, "-", ":"))
+	}
+
+	return keys, nil
+}